@@ -0,0 +1,75 @@
+// Code generated by enumgen; DO NOT EDIT.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+var _DriveTrainNames = map[DriveTrain]string{
+	aWD:     "All",
+	fourWD:  "Four wheel drive",
+	rearWD:  "Rear wheel drive",
+	frontWD: "Front wheel drive",
+}
+
+func (v DriveTrain) String() string {
+	if name, ok := _DriveTrainNames[v]; ok {
+		return name
+	}
+	return fmt.Sprintf("DriveTrain(%d)", int(v))
+}
+
+// DriveTrainValues returns every DriveTrain in declaration order
+func DriveTrainValues() []DriveTrain {
+	return []DriveTrain{aWD, fourWD, rearWD, frontWD}
+}
+
+func (v DriveTrain) IsValid() bool {
+	_, ok := _DriveTrainNames[v]
+	return ok
+}
+
+// ParseDriveTrain matches a display name, ignoring case
+func ParseDriveTrain(s string) (DriveTrain, error) {
+	for _, v := range DriveTrainValues() {
+		if strings.EqualFold(s, v.String()) {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("%q is not a valid DriveTrain", s)
+}
+
+func (v DriveTrain) MarshalText() ([]byte, error) {
+	if !v.IsValid() {
+		return nil, fmt.Errorf("cannot marshal invalid DriveTrain %d", int(v))
+	}
+	return []byte(v.String()), nil
+}
+
+func (v *DriveTrain) UnmarshalText(text []byte) error {
+	parsed, err := ParseDriveTrain(string(text))
+	if err != nil {
+		return err
+	}
+	*v = parsed
+	return nil
+}
+
+func (v DriveTrain) MarshalJSON() ([]byte, error) {
+	text, err := v.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(string(text))
+}
+
+func (v *DriveTrain) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("DriveTrain should be a string: %w", err)
+	}
+	return v.UnmarshalText([]byte(s))
+}
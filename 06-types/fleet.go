@@ -0,0 +1,255 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+)
+
+//////////////////////////////////////////////////////////////////////
+//                     Fleet                                        //
+//////////////////////////////////////////////////////////////////////
+
+// A fleet keeps vehicles and trucks side by side. Truck embeds Vehicle, so every entry has a *Vehicle
+// we can work with, and trucks additionally keep the *Truck so we don't lose the drive train
+
+var (
+	ErrNotInFleet = errors.New("not in fleet")
+	ErrDriverBusy = errors.New("driver already assigned to another vehicle")
+	ErrBadMileage = errors.New("mileage can only go up")
+	ErrBadReading = errors.New("odometer reading has to be a finite number of km")
+)
+
+const (
+	// km between services
+	serviceEvery = 15000
+	// From this age the interval halves, old cars need more looking after
+	oldVehicleAge = 10
+)
+
+type fleetEntry struct {
+	id          string
+	vehicle     *Vehicle
+	truck       *Truck // nil for plain vehicles
+	driver      string
+	mileage     float64 // km
+	lastService float64 // mileage at the last service
+}
+
+func (e *fleetEntry) serviceInterval() float64 {
+	if e.vehicle.year.Age() >= oldVehicleAge {
+		return serviceEvery / 2
+	}
+	return serviceEvery
+}
+
+func (e *fleetEntry) serviceDue() bool {
+	return e.mileage-e.lastService >= e.serviceInterval()
+}
+
+// FleetRecord is what callers see of an entry. The types themselves keep their fields unexported
+type FleetRecord struct {
+	ID              string      `json:"id"`
+	Kind            string      `json:"kind"`
+	Model           string      `json:"model"`
+	Year            Year        `json:"year"`
+	TopSpeed        Speed       `json:"topSpeed"`
+	DriveTrain      *DriveTrain `json:"driveTrain,omitempty"`
+	MaxAcceleration Speed       `json:"maxAcceleration,omitempty"`
+	Driver          string      `json:"driver,omitempty"`
+	Mileage         float64     `json:"mileage"`
+	ServiceDue      bool        `json:"serviceDue"`
+}
+
+func (e *fleetEntry) record() FleetRecord {
+	r := FleetRecord{
+		ID:         e.id,
+		Kind:       "vehicle",
+		Model:      e.vehicle.model,
+		Year:       e.vehicle.year,
+		TopSpeed:   e.vehicle.topSpeed,
+		Driver:     e.driver,
+		Mileage:    e.mileage,
+		ServiceDue: e.serviceDue(),
+	}
+	if e.truck != nil {
+		r.Kind = "truck"
+		// a copy, the record outlives the lock
+		driveTrain := e.truck.driveTrain
+		r.DriveTrain = &driveTrain
+		r.MaxAcceleration = e.truck.maxAcceleration
+	}
+	return r
+}
+
+// Fleet is safe for concurrent use, the HTTP API hits it from many goroutines
+type Fleet struct {
+	mu      sync.RWMutex
+	entries map[string]*fleetEntry
+	order   []string // registration order, maps don't keep one
+	nextID  int
+}
+
+func NewFleet() *Fleet {
+	return &Fleet{entries: map[string]*fleetEntry{}}
+}
+
+func (f *Fleet) add(e *fleetEntry, kind string) (string, error) {
+	if err := e.vehicle.year.Validate(); err != nil {
+		return "", err
+	}
+	if e.vehicle.topSpeed < 0 {
+		return "", fmt.Errorf("top speed %s is negative", e.vehicle.topSpeed)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	e.id = fmt.Sprintf("%s-%d", kind, f.nextID)
+	f.entries[e.id] = e
+	f.order = append(f.order, e.id)
+	return e.id, nil
+}
+
+// The fleet keeps its own copy, changing v afterwards doesn't change the fleet
+
+func (f *Fleet) RegisterVehicle(v Vehicle) (string, error) {
+	return f.add(&fleetEntry{vehicle: &v}, "vehicle")
+}
+
+func (f *Fleet) RegisterTruck(t Truck) (string, error) {
+	if !t.driveTrain.IsValid() {
+		return "", fmt.Errorf("truck has an invalid drive train %d", int(t.driveTrain))
+	}
+	if t.maxAcceleration < 0 {
+		return "", fmt.Errorf("max acceleration %s is negative", t.maxAcceleration)
+	}
+	return f.add(&fleetEntry{vehicle: &t.Vehicle, truck: &t}, "truck")
+}
+
+func (f *Fleet) Get(id string) (FleetRecord, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	e, ok := f.entries[id]
+	if !ok {
+		return FleetRecord{}, fmt.Errorf("%s: %w", id, ErrNotInFleet)
+	}
+	return e.record(), nil
+}
+
+// List returns every record that passes all the filters, in registration order
+func (f *Fleet) List(filters ...func(FleetRecord) bool) []FleetRecord {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	out := []FleetRecord{}
+	for _, id := range f.order {
+		r := f.entries[id].record()
+		if !slices.ContainsFunc(filters, func(keep func(FleetRecord) bool) bool { return !keep(r) }) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Only trucks have a drive train, so this never matches a plain vehicle
+func WithDriveTrain(d DriveTrain) func(FleetRecord) bool {
+	return func(r FleetRecord) bool {
+		return r.DriveTrain != nil && *r.DriveTrain == d
+	}
+}
+
+func (f *Fleet) ByDriveTrain(d DriveTrain) []FleetRecord {
+	return f.List(WithDriveTrain(d))
+}
+
+// AssignDriver hands a vehicle to a driver. A driver has one vehicle at a time, an empty name takes
+// the vehicle off whoever had it
+func (f *Fleet) AssignDriver(id, driver string) error {
+	driver = strings.TrimSpace(driver)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	e, ok := f.entries[id]
+	if !ok {
+		return fmt.Errorf("%s: %w", id, ErrNotInFleet)
+	}
+	if driver != "" {
+		for _, other := range f.entries {
+			if other != e && strings.EqualFold(other.driver, driver) {
+				return fmt.Errorf("%s drives %s: %w", driver, other.id, ErrDriverBusy)
+			}
+		}
+	}
+	e.driver = driver
+	return nil
+}
+
+// LogMileage records an odometer reading (km). NaN would slip past the "only goes up" check, every
+// comparison with it is false, and +Inf would leave no way to log anything after it
+func (f *Fleet) LogMileage(id string, odometer float64) error {
+	if math.IsNaN(odometer) || math.IsInf(odometer, 0) {
+		return fmt.Errorf("%s got %v: %w", id, odometer, ErrBadReading)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	e, ok := f.entries[id]
+	if !ok {
+		return fmt.Errorf("%s: %w", id, ErrNotInFleet)
+	}
+	if odometer < e.mileage {
+		return fmt.Errorf("%s at %.0f km, got %.0f km: %w", id, e.mileage, odometer, ErrBadMileage)
+	}
+	e.mileage = odometer
+	return nil
+}
+
+func (f *Fleet) RecordService(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	e, ok := f.entries[id]
+	if !ok {
+		return fmt.Errorf("%s: %w", id, ErrNotInFleet)
+	}
+	e.lastService = e.mileage
+	return nil
+}
+
+// MaintenanceItem is one line of the maintenance schedule
+type MaintenanceItem struct {
+	ID      string  `json:"id"`
+	Model   string  `json:"model"`
+	DueAt   float64 `json:"dueAt"` // odometer reading, km
+	Overdue float64 `json:"overdue"`
+	Reason  string  `json:"reason"`
+}
+
+// MaintenanceSchedule lists every vehicle due within the next `within` km, most overdue first
+func (f *Fleet) MaintenanceSchedule(within float64) []MaintenanceItem {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	items := []MaintenanceItem{}
+	for _, id := range f.order {
+		e := f.entries[id]
+		dueAt := e.lastService + e.serviceInterval()
+		if e.mileage+within < dueAt {
+			continue
+		}
+		reason := fmt.Sprintf("every %.0f km", e.serviceInterval())
+		if age := e.vehicle.year.Age(); age >= oldVehicleAge {
+			reason += fmt.Sprintf(", halved for a %d year old vehicle", age)
+		}
+		items = append(items, MaintenanceItem{
+			ID:      e.id,
+			Model:   e.vehicle.model,
+			DueAt:   dueAt,
+			Overdue: max(e.mileage-dueAt, 0),
+			Reason:  reason,
+		})
+	}
+	slices.SortStableFunc(items, func(a, b MaintenanceItem) int {
+		return cmp.Compare(b.Overdue, a.Overdue)
+	})
+	return items
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// The fleet over HTTP, JSON in and out
+//
+//	GET  /vehicles?driveTrain=All     list, optionally only trucks with a drive train
+//	POST /vehicles                    register a vehicle or truck
+//	GET  /vehicles/{id}
+//	PUT  /vehicles/{id}/driver        {"driver": "Leo"}, an empty driver unassigns
+//	PUT  /vehicles/{id}/mileage       {"odometer": 12000}
+//	POST /vehicles/{id}/service
+//	GET  /maintenance?within=1000     everything due in the next 1000 km
+
+type registerRequest struct {
+	Kind            string      `json:"kind"` // "vehicle" or "truck"
+	Model           string      `json:"model"`
+	Year            Year        `json:"year"`
+	TopSpeed        Speed       `json:"topSpeed"`
+	DriveTrain      *DriveTrain `json:"driveTrain"`
+	MaxAcceleration Speed       `json:"maxAcceleration"`
+}
+
+func (f *Fleet) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /vehicles", func(w http.ResponseWriter, r *http.Request) {
+		raw := r.URL.Query().Get("driveTrain")
+		if raw == "" {
+			writeJSON(w, http.StatusOK, f.List())
+			return
+		}
+		d, err := ParseDriveTrain(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, f.ByDriveTrain(d))
+	})
+
+	mux.HandleFunc("POST /vehicles", func(w http.ResponseWriter, r *http.Request) {
+		var req registerRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		v := Vehicle{model: req.Model, year: req.Year, topSpeed: req.TopSpeed}
+		var id string
+		var err error
+		switch req.Kind {
+		case "vehicle", "":
+			id, err = f.RegisterVehicle(v)
+		case "truck":
+			if req.DriveTrain == nil {
+				err = errors.New("trucks need a driveTrain")
+				break
+			}
+			id, err = f.RegisterTruck(Truck{Vehicle: v, driveTrain: *req.DriveTrain, maxAcceleration: req.MaxAcceleration})
+		default:
+			err = fmt.Errorf("unknown kind %q, expected vehicle or truck", req.Kind)
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		record, _ := f.Get(id)
+		writeJSON(w, http.StatusCreated, record)
+	})
+
+	mux.HandleFunc("GET /vehicles/{id}", func(w http.ResponseWriter, r *http.Request) {
+		record, err := f.Get(r.PathValue("id"))
+		if err != nil {
+			writeFleetError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, record)
+	})
+
+	mux.HandleFunc("PUT /vehicles/{id}/driver", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Driver string `json:"driver"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		f.respond(w, r.PathValue("id"), f.AssignDriver(r.PathValue("id"), req.Driver))
+	})
+
+	mux.HandleFunc("PUT /vehicles/{id}/mileage", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Odometer float64 `json:"odometer"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		f.respond(w, r.PathValue("id"), f.LogMileage(r.PathValue("id"), req.Odometer))
+	})
+
+	mux.HandleFunc("POST /vehicles/{id}/service", func(w http.ResponseWriter, r *http.Request) {
+		f.respond(w, r.PathValue("id"), f.RecordService(r.PathValue("id")))
+	})
+
+	mux.HandleFunc("GET /maintenance", func(w http.ResponseWriter, r *http.Request) {
+		within := 0.0
+		if raw := r.URL.Query().Get("within"); raw != "" {
+			var err error
+			if within, err = strconv.ParseFloat(raw, 64); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+		writeJSON(w, http.StatusOK, f.MaintenanceSchedule(within))
+	})
+
+	return mux
+}
+
+// respond sends back the updated record, or whatever went wrong updating it
+func (f *Fleet) respond(w http.ResponseWriter, id string, err error) {
+	if err != nil {
+		writeFleetError(w, err)
+		return
+	}
+	record, err := f.Get(id)
+	if err != nil {
+		writeFleetError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, record)
+}
+
+func writeFleetError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotInFleet):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, ErrDriverBusy), errors.Is(err, ErrBadMileage):
+		writeError(w, http.StatusConflict, err)
+	case errors.Is(err, ErrBadReading):
+		writeError(w, http.StatusBadRequest, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// do sends one request to the fleet API and decodes the JSON that comes back into out
+func do(t *testing.T, h http.Handler, method, path, body string, out any) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("%s %s answered with Content-Type %q", method, path, ct)
+	}
+	if err := json.NewDecoder(rec.Body).Decode(out); err != nil {
+		t.Fatalf("%s %s answered with a body that isn't JSON: %v", method, path, err)
+	}
+	return rec.Code
+}
+
+// register adds one vehicle through the API and returns its record
+func register(t *testing.T, h http.Handler, body string) FleetRecord {
+	t.Helper()
+	var record FleetRecord
+	if code := do(t, h, "POST", "/vehicles", body, &record); code != http.StatusCreated {
+		t.Fatalf("POST /vehicles %s = %d, want 201", body, code)
+	}
+	return record
+}
+
+func TestFleetRegister(t *testing.T) {
+	h := NewFleet().Handler()
+
+	polo := register(t, h, `{"model": "Polo", "year": 2020, "topSpeed": 180}`)
+	want := FleetRecord{ID: "vehicle-1", Kind: "vehicle", Model: "Polo", Year: 2020, TopSpeed: 180}
+	if polo != want {
+		t.Errorf("registered %+v, want %+v", polo, want)
+	}
+
+	actros := register(t, h, `{"kind": "truck", "model": "Actros", "year": 2019, "topSpeed": 90,
+		"driveTrain": "Rear wheel drive", "maxAcceleration": 15}`)
+	if actros.Kind != "truck" || actros.DriveTrain == nil || *actros.DriveTrain != rearWD || actros.MaxAcceleration != 15 {
+		t.Errorf("registered truck %+v", actros)
+	}
+
+	for _, tc := range []struct {
+		name string
+		body string
+	}{
+		{"not JSON", `{"model": `},
+		{"unknown kind", `{"kind": "boat", "model": "Riva", "year": 2020}`},
+		{"truck without a drive train", `{"kind": "truck", "model": "Actros", "year": 2019}`},
+		{"unknown drive train", `{"kind": "truck", "model": "Actros", "year": 2019, "driveTrain": "Six"}`},
+		{"year before the first car", `{"model": "Cart", "year": 1800}`},
+		{"negative top speed", `{"model": "Polo", "year": 2020, "topSpeed": -180}`},
+		{"negative acceleration", `{"kind": "truck", "model": "Actros", "year": 2019, "driveTrain": "All",
+			"maxAcceleration": -15}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var body map[string]string
+			if code := do(t, h, "POST", "/vehicles", tc.body, &body); code != http.StatusBadRequest {
+				t.Errorf("status %d, want 400", code)
+			}
+			if body["error"] == "" {
+				t.Errorf("body %v has no error", body)
+			}
+		})
+	}
+
+	var all []FleetRecord
+	if code := do(t, h, "GET", "/vehicles", "", &all); code != http.StatusOK || len(all) != 2 {
+		t.Errorf("GET /vehicles = %d with %d vehicles, want 200 with the 2 that registered", code, len(all))
+	}
+}
+
+func TestFleetGetAndFilter(t *testing.T) {
+	h := NewFleet().Handler()
+	polo := register(t, h, `{"model": "Polo", "year": 2020, "topSpeed": 180}`)
+	actros := register(t, h, `{"kind": "truck", "model": "Actros", "year": 2019, "driveTrain": "All"}`)
+
+	var got FleetRecord
+	if code := do(t, h, "GET", "/vehicles/"+polo.ID, "", &got); code != http.StatusOK || got != polo {
+		t.Errorf("GET %s = %d %+v, want 200 %+v", polo.ID, code, got, polo)
+	}
+	var missing map[string]string
+	if code := do(t, h, "GET", "/vehicles/vehicle-99", "", &missing); code != http.StatusNotFound {
+		t.Errorf("GET an unknown id = %d, want 404", code)
+	}
+
+	var trucks []FleetRecord
+	if code := do(t, h, "GET", "/vehicles?driveTrain=all", "", &trucks); code != http.StatusOK ||
+		len(trucks) != 1 || trucks[0].ID != actros.ID {
+		t.Errorf("GET ?driveTrain=all = %d %+v, want 200 with only %s", code, trucks, actros.ID)
+	}
+	if code := do(t, h, "GET", "/vehicles?driveTrain=Six", "", &missing); code != http.StatusBadRequest {
+		t.Errorf("an unknown drive train filter = %d, want 400", code)
+	}
+}
+
+func TestFleetDrivers(t *testing.T) {
+	h := NewFleet().Handler()
+	polo := register(t, h, `{"model": "Polo", "year": 2020}`)
+	golf := register(t, h, `{"model": "Golf", "year": 2021}`)
+
+	var got FleetRecord
+	if code := do(t, h, "PUT", "/vehicles/"+polo.ID+"/driver", `{"driver": " Leo "}`, &got); code != http.StatusOK ||
+		got.Driver != "Leo" {
+		t.Errorf("assigning Leo = %d %+v", code, got)
+	}
+
+	var body map[string]string
+	if code := do(t, h, "PUT", "/vehicles/"+golf.ID+"/driver", `{"driver": "leo"}`, &body); code != http.StatusConflict {
+		t.Errorf("giving Leo a second vehicle = %d %v, want 409", code, body)
+	}
+	if code := do(t, h, "PUT", "/vehicles/vehicle-99/driver", `{"driver": "Ada"}`, &body); code != http.StatusNotFound {
+		t.Errorf("assigning to an unknown id = %d, want 404", code)
+	}
+
+	// once the Polo is unassigned Leo is free again. driver is omitted when empty, so decode into a fresh record
+	var unassigned FleetRecord
+	if code := do(t, h, "PUT", "/vehicles/"+polo.ID+"/driver", `{"driver": ""}`, &unassigned); code != http.StatusOK ||
+		unassigned.Driver != "" {
+		t.Errorf("unassigning = %d %+v", code, unassigned)
+	}
+	if code := do(t, h, "PUT", "/vehicles/"+golf.ID+"/driver", `{"driver": "Leo"}`, &got); code != http.StatusOK {
+		t.Errorf("Leo taking the Golf = %d, want 200", code)
+	}
+}
+
+func TestFleetMileageAndService(t *testing.T) {
+	h := NewFleet().Handler()
+	polo := register(t, h, `{"model": "Polo", "year": 2020}`)
+	path := "/vehicles/" + polo.ID
+
+	var got FleetRecord
+	if code := do(t, h, "PUT", path+"/mileage", `{"odometer": 16000}`, &got); code != http.StatusOK ||
+		got.Mileage != 16000 || !got.ServiceDue {
+		t.Errorf("logging 16000 km = %d %+v, want 200 and a service due", code, got)
+	}
+
+	var body map[string]string
+	for _, tc := range []struct {
+		name string
+		body string
+		want int
+	}{
+		{"going backwards", `{"odometer": 100}`, http.StatusConflict},
+		{"not a number", `{"odometer": "lots"}`, http.StatusBadRequest},
+	} {
+		if code := do(t, h, "PUT", path+"/mileage", tc.body, &body); code != tc.want {
+			t.Errorf("%s = %d %v, want %d", tc.name, code, body, tc.want)
+		}
+	}
+
+	var schedule []MaintenanceItem
+	if code := do(t, h, "GET", "/maintenance", "", &schedule); code != http.StatusOK ||
+		len(schedule) != 1 || schedule[0].Overdue != 1000 {
+		t.Errorf("GET /maintenance = %d %+v, want the Polo 1000 km overdue", code, schedule)
+	}
+	if code := do(t, h, "GET", "/maintenance?within=lots", "", &body); code != http.StatusBadRequest {
+		t.Errorf("a within that isn't a number = %d, want 400", code)
+	}
+
+	if code := do(t, h, "POST", path+"/service", "", &got); code != http.StatusOK || got.ServiceDue {
+		t.Errorf("servicing = %d %+v, want 200 and nothing due", code, got)
+	}
+	if code := do(t, h, "GET", "/maintenance?within=1000", "", &schedule); code != http.StatusOK || len(schedule) != 0 {
+		t.Errorf("after the service GET /maintenance = %d %+v, want nothing", code, schedule)
+	}
+	if code := do(t, h, "POST", "/vehicles/vehicle-99/service", "", &body); code != http.StatusNotFound {
+		t.Errorf("servicing an unknown id = %d, want 404", code)
+	}
+}
+
+// JSON has no way to spell NaN or Inf, so these only come in through the Go API
+func TestLogMileageRejectsNonFinite(t *testing.T) {
+	f := NewFleet()
+	id, err := f.RegisterVehicle(Vehicle{model: "Polo", year: 2020})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, odometer := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if err := f.LogMileage(id, odometer); err == nil {
+			t.Errorf("LogMileage(%v) was accepted", odometer)
+		}
+	}
+	if err := f.LogMileage(id, 100); err != nil {
+		t.Errorf("a normal reading after the bad ones: %v", err)
+	}
+}
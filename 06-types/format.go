@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"text/tabwriter"
+)
+
+//////////////////////////////////////////////////////////////////////
+//                     Formatting                                   //
+//////////////////////////////////////////////////////////////////////
+
+// fmt checks for a Formatter before it checks for a Stringer. Once Vehicle has Format, String is only used
+// because Format calls it, and we get to decide what %v, %+v and %#v each mean
+//
+//	%v, %s  the String sentence
+//	%+v     every field worth knowing, on one line
+//	%#v     Go syntax, handy when writing a test case from a log line
+//
+// Truck embeds Vehicle, so it would get Vehicle's Format promoted and print like a plain Vehicle.
+// That's why Truck declares every one of these methods again
+
+func (v Vehicle) Format(f fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		switch {
+		case f.Flag('#'):
+			fmt.Fprintf(f, "main.Vehicle{model:%q, year:%d, topSpeed:%g, speed:%g}",
+				v.model, v.year, float64(v.topSpeed), float64(v.speed))
+		case f.Flag('+'):
+			fmt.Fprintf(f, "%s (%d) top speed %s, moving at %s, %d speed records",
+				v.model, v.year, v.topSpeed, v.speed, len(v.speedHistory))
+		default:
+			io.WriteString(f, v.String())
+		}
+	case 's':
+		io.WriteString(f, v.String())
+	case 'q':
+		io.WriteString(f, strconv.Quote(v.String()))
+	default:
+		fmt.Fprintf(f, "%%!%c(main.Vehicle=%s)", verb, v.String())
+	}
+}
+
+func (t Truck) String() string {
+	return fmt.Sprintf("%s, a truck with %s drive", t.Vehicle, t.driveTrain)
+}
+
+func (t Truck) Format(f fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		switch {
+		case f.Flag('#'):
+			fmt.Fprintf(f, "main.Truck{Vehicle:%#v, driveTrain:%d, maxAcceleration:%g}",
+				t.Vehicle, int(t.driveTrain), float64(t.maxAcceleration))
+		case f.Flag('+'):
+			fmt.Fprintf(f, "%+v, %s drive, max acceleration %s", t.Vehicle, t.driveTrain, t.maxAcceleration)
+		default:
+			io.WriteString(f, t.String())
+		}
+	case 's':
+		io.WriteString(f, t.String())
+	case 'q':
+		io.WriteString(f, strconv.Quote(t.String()))
+	default:
+		fmt.Fprintf(f, "%%!%c(main.Truck=%s)", verb, t.String())
+	}
+}
+
+// LogValue lets slog log a vehicle as a group of fields instead of one string
+// Speeds go out as plain numbers so whatever reads the logs can do maths on them
+
+func (v Vehicle) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("model", v.model),
+		slog.Int("year", int(v.year)),
+		slog.Float64("topSpeedKph", float64(v.topSpeed)),
+		slog.Float64("speedKph", float64(v.speed)),
+	)
+}
+
+func (t Truck) LogValue() slog.Value {
+	return slog.GroupValue(append(t.Vehicle.LogValue().Group(),
+		slog.String("driveTrain", t.driveTrain.String()),
+		slog.Float64("maxAccelerationKph", float64(t.maxAcceleration)),
+	)...)
+}
+
+func (t Truck) Describe() string {
+	return fmt.Sprint("A truck with ", t.driveTrain)
+}
+
+func (t Truck) DescribeTo(w io.Writer) error {
+	_, err := fmt.Fprintln(w, t.Describe())
+	return err
+}
+
+// Same story as Format, without these a Truck would marshal as its Vehicle
+
+type vehicleJSON struct {
+	Model    string `json:"model"`
+	Year     Year   `json:"year"`
+	TopSpeed Speed  `json:"topSpeed"`
+	Speed    Speed  `json:"speed"`
+}
+
+func (v Vehicle) json() vehicleJSON {
+	return vehicleJSON{Model: v.model, Year: v.year, TopSpeed: v.topSpeed, Speed: v.speed}
+}
+
+func (v Vehicle) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.json())
+}
+
+func (t Truck) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		vehicleJSON
+		DriveTrain      DriveTrain `json:"driveTrain"`
+		MaxAcceleration Speed      `json:"maxAcceleration"`
+	}{t.Vehicle.json(), t.driveTrain, t.maxAcceleration})
+}
+
+// tabular is anything RenderTable can lay out, one row per item
+type tabular interface {
+	columns() []string
+	row() []string
+}
+
+func (v Vehicle) columns() []string {
+	return []string{"MODEL", "YEAR", "TOP SPEED", "SPEED"}
+}
+
+func (v Vehicle) row() []string {
+	return []string{v.model, strconv.Itoa(int(v.year)), v.topSpeed.String(), v.speed.String()}
+}
+
+func (t Truck) columns() []string {
+	return append(t.Vehicle.columns(), "DRIVE TRAIN", "MAX ACCELERATION")
+}
+
+func (t Truck) row() []string {
+	return append(t.Vehicle.row(), t.driveTrain.String(), t.maxAcceleration.String())
+}
+
+func RenderTable[T tabular](w io.Writer, items []T) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	var zero T
+	writeRow(tw, zero.columns())
+	for _, item := range items {
+		writeRow(tw, item.row())
+	}
+	return tw.Flush()
+}
+
+func writeRow(w io.Writer, cells []string) {
+	for i, cell := range cells {
+		if i > 0 {
+			io.WriteString(w, "\t")
+		}
+		io.WriteString(w, cell)
+	}
+	io.WriteString(w, "\n")
+}
+
+func RenderJSON[T any](w io.Writer, items []T) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(items)
+}
@@ -0,0 +1,128 @@
+package main
+
+import "testing"
+
+//////////////////////////////////////////////////////////////////////
+//                     Conformance                                  //
+//////////////////////////////////////////////////////////////////////
+
+// An interface only promises method signatures, not behavior. Anything with Speed, Accelerate and Decelerate
+// is a Locomotor, even one that ignores every call. testLocomotor drives a fresh Locomotor through the rules
+// every implementation should follow
+//
+//  1. speed never goes below zero
+//  2. accelerating never slows us down and decelerating never speeds us up, even with negative amounts
+//  3. accelerating eventually stops at a top speed and stays there
+//  4. decelerating eventually brings us to a standstill and stays there
+
+// Big enough to blow through any top speed in a handful of calls
+const conformanceStep Speed = 10
+const conformanceMaxSteps = 1000
+
+func TestLocomotorConformance(t *testing.T) {
+	t.Run("Vehicle", func(t *testing.T) {
+		testLocomotor(t, func() Locomotor { return &Vehicle{topSpeed: 220} })
+	})
+	t.Run("Truck", func(t *testing.T) {
+		testLocomotor(t, func() Locomotor { return &Truck{Vehicle: Vehicle{topSpeed: 120}, maxAcceleration: 20} })
+	})
+}
+
+// testLocomotor is the conformance suite. A new Locomotor gets checked by calling it with a constructor
+// for a fresh one, nothing here knows about any particular implementation
+func testLocomotor(t *testing.T, newLocomotor func() Locomotor) {
+	t.Helper()
+	l := newLocomotor()
+	if l.Speed() < 0 {
+		t.Fatalf("starts at a negative speed %s", l.Speed())
+	}
+
+	before := l.Speed()
+	l.Accelerate(0)
+	l.Decelerate(0)
+	if l.Speed() != before {
+		t.Fatalf("changing speed by 0 moved it from %s to %s", before, l.Speed())
+	}
+
+	l.Accelerate(-conformanceStep)
+	if l.Speed() < before {
+		t.Fatalf("accelerating by %s slowed down from %s to %s", -conformanceStep, before, l.Speed())
+	}
+
+	top := accelerateToTop(t, l)
+	if top == 0 {
+		t.Fatal("never moved from standstill")
+	}
+	l.Accelerate(top * 10)
+	if l.Speed() != top {
+		t.Fatalf("went past top speed %s to %s", top, l.Speed())
+	}
+
+	l.Decelerate(-conformanceStep)
+	if l.Speed() > top {
+		t.Fatalf("decelerating by %s sped up from %s to %s", -conformanceStep, top, l.Speed())
+	}
+
+	decelerateToStop(t, l)
+	l.Decelerate(top * 10)
+	if l.Speed() != 0 {
+		t.Fatalf("decelerating from standstill left speed at %s", l.Speed())
+	}
+}
+
+func accelerateToTop(t *testing.T, l Locomotor) Speed {
+	t.Helper()
+	prev := l.Speed()
+	for range conformanceMaxSteps {
+		l.Accelerate(conformanceStep)
+		cur := l.Speed()
+		if cur < prev {
+			t.Fatalf("accelerating went from %s down to %s", prev, cur)
+		}
+		if cur == prev {
+			return cur
+		}
+		prev = cur
+	}
+	t.Fatalf("still accelerating at %s after %d steps, no top speed", prev, conformanceMaxSteps)
+	return 0
+}
+
+func decelerateToStop(t *testing.T, l Locomotor) {
+	t.Helper()
+	prev := l.Speed()
+	for range conformanceMaxSteps {
+		l.Decelerate(conformanceStep)
+		cur := l.Speed()
+		if cur > prev {
+			t.Fatalf("decelerating went from %s up to %s", prev, cur)
+		}
+		if cur < 0 {
+			t.Fatalf("decelerating went below zero to %s", cur)
+		}
+		if cur == 0 {
+			return
+		}
+		prev = cur
+	}
+	t.Fatalf("still moving at %s after %d steps", prev, conformanceMaxSteps)
+}
+
+// The history is for changes of speed. Calls that leave the speed where it was, a change of 0 or pushing
+// against top speed or standstill, shouldn't show up in it
+func TestSpeedHistorySkipsNoChange(t *testing.T) {
+	v := &Vehicle{topSpeed: 100}
+	v.Accelerate(0)
+	v.Decelerate(0)
+	v.Decelerate(10)
+	if len(v.speedHistory) != 0 {
+		t.Fatalf("history has %d records without the speed changing: %v", len(v.speedHistory), v.speedHistory)
+	}
+
+	v.Accelerate(150)
+	v.Accelerate(10)
+	v.Accelerate(0)
+	if len(v.speedHistory) != 1 || v.speedHistory[0].Speed != 100 {
+		t.Fatalf("history should be the one move to top speed, got %v", v.speedHistory)
+	}
+}
@@ -1,11 +1,23 @@
 package main
 
-import "fmt"
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"learninggo/types/telemetry"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
 
 // types are executable documentation. This is a common pattern in Go
 // Use types to describe data and what we expect
 type Year int
-type Speed int
+
+// Speed is in km/h. See units.go for getting speeds in and out of other units
+type Speed float64
 
 // This is a bad example but imagine this was something else with more complex behavior
 // and edge cases, or could be computed using a complex formula
@@ -14,7 +26,7 @@ func (s Speed) compare(s2 Speed) bool {
 }
 
 func (s Speed) Float32() float32 {
-	return float32(3)
+	return float32(s)
 }
 
 // By declaring speed, we tie together the concept of speed for (topSpeed, speed, speedHistory) Vehicle
@@ -23,26 +35,67 @@ type Vehicle struct {
 	year         Year
 	topSpeed     Speed
 	speed        Speed
-	speedHistory []Speed
+	speedHistory []SpeedRecord
+	physics      Physics
 }
 
+// SpeedRecord is one entry in a vehicle's speed history
+type SpeedRecord struct {
+	At    time.Time
+	Speed Speed
+}
+
+func (r SpeedRecord) String() string {
+	return fmt.Sprintf("%s at %s", r.Speed, r.At.Format(time.TimeOnly))
+}
+
+// Where history timestamps come from. A variable rather than a direct time.Now call
+// so a replay or a demo can swap in its own clock
+var clock = time.Now
+
 // Below is a method (not function because we've bound it to a type)
 // Go allows you to declare methods only at the package level
 // It also restricts method declaration within the same file as the type declaration
 // Meaning you can only bind types that you own
 // (v Vehicle) is a receiver specification. This is the type that will be bound to a function
 func (v Vehicle) String() string {
-	return fmt.Sprintf("Model %s of year %d with a top speed of %d", v.model, v.year, v.topSpeed)
+	return fmt.Sprintf("Model %s of year %d with a top speed of %s", v.model, v.year, v.topSpeed)
 }
 
 // You can also define using pointer type receivers
 // In this case though, it is recommended to have consistency to avoid confusion around your types
 // If you pass a value specification, use value specification all through and the vice-versa
+// A zero top speed means we were never told one, so there's nothing to clamp to
+// Negative amounts are ignored, accelerating should never slow us down
 func (v *Vehicle) Accelerate(by Speed) {
-	if !(v.speed + by).compare(v.topSpeed) {
-		v.speed += by
-		v.speedHistory = append(v.speedHistory, v.speed)
+	if by < 0 {
+		return
+	}
+	next := v.speed + by
+	if v.topSpeed > 0 && !next.compare(v.topSpeed) {
+		next = v.topSpeed
+	}
+	v.record(next)
+}
+
+func (v *Vehicle) Decelerate(by Speed) {
+	if by < 0 {
+		return
 	}
+	v.record(max(v.speed-by, 0))
+}
+
+func (v *Vehicle) Speed() Speed {
+	return v.speed
+}
+
+// A change of 0, or a clamp that leaves us where we were, isn't news and stays out of the history
+func (v *Vehicle) record(s Speed) {
+	if s == v.speed {
+		return
+	}
+	v.speed = s
+	v.speedHistory = append(v.speedHistory, SpeedRecord{At: clock(), Speed: s})
 }
 
 func (v *Vehicle) fakeHistory() {
@@ -50,48 +103,55 @@ func (v *Vehicle) fakeHistory() {
 
 	// Best pattern is to code for nil values
 	if v == nil {
-		v = &Vehicle{speedHistory: []Speed{}}
+		v = &Vehicle{speedHistory: []SpeedRecord{}}
 	}
 
-	v.speedHistory = append(v.speedHistory, fake...)
+	// one reading a second, ending now, or carrying on after the last real reading if that's later
+	// so the history stays in time order
+	start := clock().Add(-time.Duration(len(fake)-1) * time.Second)
+	if n := len(v.speedHistory); n > 0 && !v.speedHistory[n-1].At.Before(start) {
+		start = v.speedHistory[n-1].At.Add(time.Second)
+	}
+	for i, s := range fake {
+		v.speedHistory = append(v.speedHistory, SpeedRecord{At: start.Add(time.Duration(i) * time.Second), Speed: s})
+	}
 }
 
 func readFakeSpeedHistory(v Vehicle) {
 	// we call a pointer receiver method on a value function argument
 	v.fakeHistory()
-	for i, v := range v.speedHistory {
-		fmt.Printf("[record %d] %d km/h\n", i+1, v)
+	for i, r := range v.speedHistory {
+		fmt.Printf("[record %d] %s\n", i+1, r)
 	}
 }
 
+// SpeedSamples hands the history over to the telemetry package, which only knows about plain numbers
+func (v *Vehicle) SpeedSamples() []telemetry.Sample {
+	samples := make([]telemetry.Sample, len(v.speedHistory))
+	for i, r := range v.speedHistory {
+		samples[i] = telemetry.Sample{At: r.At, Value: float64(r.Speed)}
+	}
+	return samples
+}
+
 //////////////////////////////////////////////////////////////////////
 //                     IOTA                                         //
 //////////////////////////////////////////////////////////////////////
 
+// The trailing comments are not just for us. enumgen reads them as display names and writes
+// String, ParseDriveTrain, DriveTrainValues and the JSON/text methods into drivetrain_enum.go
+// After changing the constants run `go generate`, never edit the generated file
+
+//go:generate go run -C ../enumgen . -type=DriveTrain -dir=$PWD
 type DriveTrain int
 
 const (
-	aWD DriveTrain = iota
-	fourWD
-	rearWD
-	frontWD
+	aWD     DriveTrain = iota // All
+	fourWD                    // Four wheel drive
+	rearWD                    // Rear wheel drive
+	frontWD                   // Front wheel drive
 )
 
-func (d DriveTrain) String() string {
-	var repr string
-	switch d {
-	case aWD:
-		repr = "All"
-	case fourWD:
-		repr = "Four wheel drive"
-	case rearWD:
-		repr = "Rear wheel drive"
-	case frontWD:
-		repr = "Front wheel drive"
-	}
-	return repr
-}
-
 //////////////////////////////////////////////////////////////////////
 //                     Embeddings                                   //
 //////////////////////////////////////////////////////////////////////
@@ -108,34 +168,18 @@ type Truck struct {
 	maxAcceleration Speed
 }
 
-func (t Truck) Describe() {
-	fmt.Println("A truck with", t.driveTrain)
-}
-
 // If we create a method in the outer type that shadows one in the embedding, no voodoo is done
 // To call this on an instance truck, call as truck.Accelerate(Speed(30)). To call the Accelerate method on the
-func (t Truck) Accelerate(by Speed) {
-	acc := 0
-	if by > t.maxAcceleration {
-		acc = int(t.maxAcceleration)
-	} else {
-		acc = int(by)
-	}
-	t.speed = Speed(int(t.speed) + acc)
+// embedded Vehicle, call truck.Vehicle.Accelerate(Speed(30))
+// Truck has to use a pointer receiver here. With a value receiver we would clamp and add to a copy of the truck
+// and the caller's truck would never move
+func (t *Truck) Accelerate(by Speed) {
+	t.Vehicle.Accelerate(min(by, t.maxAcceleration))
 }
 
-func (t Truck) Decelerate(by Speed) {
-	minSpeed := 0
-	t.speed -= by
-
-	if t.speed < Speed(minSpeed) {
-		t.speed = Speed(minSpeed)
-	}
-}
+// Decelerate and Speed are not declared on Truck at all. They are promoted from the embedded Vehicle value,
+// and as they have pointer receivers only *Truck gets them, so *Truck satisfies Locomotor below and Truck doesn't
 
-func (t Truck) Speed () Speed {
-	return t.speed
-}
 //////////////////////////////////////////////////////////////////////
 //                     Interfaces                                   //
 //////////////////////////////////////////////////////////////////////
@@ -146,13 +190,43 @@ type Locomotor interface {
 	Decelerate(Speed)
 }
 
-
 const pitLaneLimit = 30
+
+// Locomotors hold their own speed, so this only works when we're handed a pointer.
+// A slower car than the limit is left alone since Decelerate ignores negative amounts
 func pitLaneOverride(l Locomotor) {
 	l.Decelerate(l.Speed() - pitLaneLimit)
 }
 
+// A few vehicles to play with, for the demo below and for -serve
+func demoFleet() *Fleet {
+	fleet := NewFleet()
+	golf, _ := fleet.RegisterVehicle(Vehicle{model: "Golf", year: 2017, topSpeed: 220})
+	fleet.RegisterVehicle(Vehicle{model: "Polo", year: 2012, topSpeed: 190})
+	rover, _ := fleet.RegisterTruck(Truck{
+		Vehicle:         Vehicle{model: "Range Rover", year: 2010, topSpeed: 190},
+		driveTrain:      aWD,
+		maxAcceleration: 20,
+	})
+	fleet.RegisterTruck(Truck{
+		Vehicle:         Vehicle{model: "Hilux", year: 2021, topSpeed: 175},
+		driveTrain:      fourWD,
+		maxAcceleration: 15,
+	})
+	fleet.AssignDriver(golf, "Leo")
+	fleet.LogMileage(golf, 14200)
+	fleet.LogMileage(rover, 8100)
+	return fleet
+}
+
 func main() {
+	serve := flag.String("serve", "", "serve the fleet API on this address instead of running the examples, e.g. :8080")
+	flag.Parse()
+	if *serve != "" {
+		log.Println("Fleet API listening on", *serve)
+		log.Fatal(http.ListenAndServe(*serve, demoFleet().Handler()))
+	}
+
 	v := Vehicle{
 		model:    "Golf",
 		year:     2017,
@@ -168,6 +242,20 @@ func main() {
 	v.Accelerate(10)
 	fmt.Println("Moving at", v.speed)
 
+	// Speeds from different sources, all comparable once they're a Speed
+	for _, raw := range []string{"120km/h", "75 mph", "33.3 m/s", "90"} {
+		s, err := ParseSpeed(raw)
+		if err != nil {
+			fmt.Println("Couldn't read speed:", err)
+			continue
+		}
+		fmt.Printf("%q is %s or %s or %s\n", raw, s, s.StringIn(MilesPerHour), s.StringIn(MetersPerSecond))
+	}
+	if err := v.year.Validate(); err == nil {
+		fmt.Printf("The %s is %d years old\n", v.model, v.year.Age())
+	}
+	fmt.Println("Is 1850 a real car year?", Year(1850).Validate())
+
 	//////////////////////// [WARNING] ///////////////////////////////////
 	//                                                                  //
 	// If you call a value receiver method on a nil pointer instance,   //
@@ -188,10 +276,29 @@ func main() {
 	polo.fakeHistory()
 	fmt.Println("Polo history", polo.speedHistory)
 
+	// 0.3g is roughly 10 km/h gained or lost every second, that's where driver scoring starts to care
+	const hardAcceleration = 10
+	history := polo.SpeedSamples()
+	if summary, err := telemetry.Summarize(history); err == nil {
+		fmt.Printf("Polo speeds min %.0f max %.0f mean %.1f km/h\n", summary.Min, summary.Max, summary.Mean)
+	}
+	p90, _ := telemetry.Percentile(history, 90)
+	fmt.Printf("Polo 90th percentile %.1f km/h\n", p90)
+	for _, ev := range telemetry.HardAccelerations(history, hardAcceleration) {
+		fmt.Printf("Hard acceleration %.0f -> %.0f km/h at %.1f km/h/s\n", ev.From.Value, ev.To.Value, ev.Rate)
+	}
+	for _, s := range telemetry.MovingAverage(history, 2*time.Second) {
+		fmt.Printf("Smoothed %.1f km/h at %s\n", s.Value, s.At.Format(time.TimeOnly))
+	}
+	for _, s := range telemetry.Downsample(history, 2) {
+		fmt.Printf("Downsampled %.1f km/h at %s\n", s.Value, s.At.Format(time.TimeOnly))
+	}
+
 	truck := Truck{
 		Vehicle: Vehicle{
-			model: "Range Rover",
-			year:  2010,
+			model:    "Range Rover",
+			year:     2010,
+			topSpeed: 190,
 		},
 		driveTrain:      aWD,
 		maxAcceleration: 20,
@@ -199,8 +306,106 @@ func main() {
 
 	// because of embeddings we can say
 	truck.Accelerate(40)
-	fmt.Printf("The %s is moving at %d km/h\n", truck.model, truck.speed)
+	fmt.Printf("The %s is moving at %s\n", truck.model, truck.speed)
 	fmt.Println("Checking vehicle ", truck.Vehicle.model)
+	truck.DescribeTo(os.Stdout)
+
+	// DriveTrain round trips through JSON by name, and rejects names it doesn't know
+	encoded, _ := json.Marshal(truck.driveTrain)
+	fmt.Println("Drive train as JSON", string(encoded))
+	var decoded DriveTrain
+	if err := json.Unmarshal([]byte(`"rear wheel drive"`), &decoded); err == nil {
+		fmt.Println("Decoded drive train", decoded)
+	}
+	if _, err := ParseDriveTrain("hover"); err != nil {
+		fmt.Println(err)
+	}
+	fmt.Println("Drive trains on offer", DriveTrainValues())
+
+	// Truck only satisfies Locomotor as a pointer, passing the value would not compile
+	pitLaneOverride(&truck)
+	fmt.Printf("The %s is in the pit lane at %s\n", truck.model, truck.speed)
+
+	golf := Vehicle{
+		model:    "Golf GTI",
+		year:     2017,
+		topSpeed: 246,
+		physics: Physics{
+			Mass:         1400,
+			DragArea:     0.7,
+			RollingCoeff: 0.012,
+			Power:        169000,
+			MaxTraction:  9000,
+			BrakeForce:   12000,
+		},
+	}
+	launch := ThrottleProfile{
+		{Throttle: 1, For: 6 * time.Second},
+		{Throttle: 0, For: 2 * time.Second},
+		{Throttle: -1, For: 2 * time.Second},
+	}
+	samples, err := Simulate(golf, launch, time.Second)
+	if err != nil {
+		fmt.Println("Simulation failed", err)
+		return
+	}
+	for _, s := range samples {
+		fmt.Printf("[%v] %s at %.1f m\n", s.At, s.Speed, s.Position)
+	}
+	if stopping, err := BrakingDistance(golf, 100); err != nil {
+		fmt.Println("Braking failed", err)
+	} else {
+		fmt.Printf("Stopping from 100 km/h takes %.1f m\n", stopping)
+	}
+	if eta, err := ETA(golf, 5000, 80); err != nil {
+		fmt.Println("No ETA", err)
+	} else {
+		fmt.Println("5 km at 80 km/h should take", eta)
+	}
+
+	race := RaceControl{
+		Track: Track{
+			Name: "Kasarani",
+			Segments: []Segment{
+				{Name: "Pit straight", Length: 250},
+				{Name: "Main straight", Length: 550},
+				{Name: "Hairpin", Length: 150, SpeedLimit: 60},
+				{Name: "Back straight", Length: 600},
+				{Name: "Chicane", Length: 200, SpeedLimit: 90},
+			},
+			PitSegment: 0,
+		},
+		Laps:      3,
+		Tick:      100 * time.Millisecond,
+		Penalty:   5 * time.Second,
+		TimeLimit: 10 * time.Minute,
+	}
+	standings, err := race.Run([]*Racer{
+		{Name: "Golf", Car: &Vehicle{topSpeed: 246}, PitLaps: []int{2}},
+		{Name: "Range Rover", Car: &Truck{Vehicle: Vehicle{topSpeed: 190}, maxAcceleration: 5}},
+		{Name: "Polo", Car: &Vehicle{topSpeed: 200}, PitLaps: []int{2}, Reckless: true},
+	})
+	if err != nil {
+		fmt.Println("Race abandoned", err)
+		return
+	}
+	printLeaderboard(os.Stdout, standings)
+
+	fleet := demoFleet()
+	for _, r := range fleet.ByDriveTrain(aWD) {
+		fmt.Printf("%s %s drive train %s\n", r.ID, r.Model, r.DriveTrain)
+	}
+	if err := fleet.AssignDriver("vehicle-2", "leo"); err != nil {
+		fmt.Println("Couldn't assign driver:", err)
+	}
+	for _, item := range fleet.MaintenanceSchedule(1000) {
+		fmt.Printf("%s (%s) due at %.0f km, service %s\n", item.ID, item.Model, item.DueAt, item.Reason)
+	}
 
-	pitLaneOverride(truck)
+	fmt.Printf("%v\n%+v\n%#v\n", truck, truck, truck)
+	fmt.Printf("%q\n", v)
+	slog.Info("vehicle checked in", "vehicle", v, "truck", truck)
+	RenderTable(os.Stdout, []Vehicle{v, golf})
+	RenderTable(os.Stdout, []Truck{truck})
+	RenderJSON(os.Stdout, []Truck{truck})
 }
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+)
+
+//////////////////////////////////////////////////////////////////////
+//                     Race control                                 //
+//////////////////////////////////////////////////////////////////////
+
+// Race control doesn't care whether it's a Vehicle, a Truck or something we haven't written yet.
+// Anything that is a Locomotor can line up on the grid
+
+// Segment is a stretch of track. A zero SpeedLimit means flat out
+type Segment struct {
+	Name       string
+	Length     float64 // m
+	SpeedLimit Speed
+}
+
+// Track is a loop of segments. The pit lane runs alongside the segment at PitSegment,
+// racers that pit on a lap take it instead and are held to pitLaneLimit
+type Track struct {
+	Name       string
+	Segments   []Segment
+	PitSegment int
+}
+
+func (t Track) Length() float64 {
+	total := 0.0
+	for _, s := range t.Segments {
+		total += s.Length
+	}
+	return total
+}
+
+// segmentAt finds the segment under a distance into the lap
+func (t Track) segmentAt(distance float64) (int, Segment) {
+	for i, s := range t.Segments {
+		if distance < s.Length {
+			return i, s
+		}
+		distance -= s.Length
+	}
+	last := len(t.Segments) - 1
+	return last, t.Segments[last]
+}
+
+// How much a driver asks for each tick when there's room to speed up
+const throttleStep Speed = 10
+
+// Racer puts a driver behind a Locomotor
+type Racer struct {
+	Name string
+	Car  Locomotor
+	// Laps on which the racer comes into the pits, counting from 1
+	PitLaps []int
+	// Reckless drivers only brake half as hard as they should, pit lane included
+	Reckless bool
+
+	// everything below belongs to the racer's goroutine while a tick runs
+	lap      int
+	distance float64 // into the current lap
+	lapStart time.Duration
+	laps     []time.Duration
+	inPits   bool
+	finished bool
+}
+
+// reset puts the racer back on the grid, so the same racers can run one race after another
+// The car is a Locomotor we can only slow down, so a standing start is as far back as it goes
+func (r *Racer) reset() {
+	r.lap, r.distance, r.lapStart = 0, 0, 0
+	r.laps = nil
+	r.inPits, r.finished = false, false
+	r.Car.Decelerate(r.Car.Speed())
+}
+
+func (r *Racer) pitting() bool {
+	return slices.Contains(r.PitLaps, r.lap+1)
+}
+
+// targetSpeed is what the driver aims for on the current segment
+func (r *Racer) targetSpeed(track Track) (Speed, bool) {
+	i, seg := track.segmentAt(r.distance)
+	if i == track.PitSegment && r.pitting() {
+		return pitLaneLimit, true
+	}
+	return seg.SpeedLimit, false
+}
+
+// raceEvent is what a racer reports back to race control after each tick
+type raceEvent struct {
+	racer    *Racer
+	lapTime  time.Duration
+	lapDone  bool
+	speeding bool
+}
+
+func (r *Racer) tick(track Track, laps int, now, dt time.Duration) raceEvent {
+	ev := raceEvent{racer: r}
+	target, pitLane := r.targetSpeed(track)
+	entering := pitLane && !r.inPits
+	r.inPits = pitLane
+
+	switch {
+	case entering && !r.Reckless:
+		pitLaneOverride(r.Car)
+	case target > 0 && r.Car.Speed() > target:
+		over := r.Car.Speed() - target
+		if r.Reckless {
+			over /= 2
+		}
+		r.Car.Decelerate(over)
+	default:
+		// Locomotors clamp at their own top speed, so asking for too much is fine
+		r.Car.Accelerate(throttleStep)
+		if target > 0 && r.Car.Speed() > target {
+			r.Car.Decelerate(r.Car.Speed() - target)
+		}
+	}
+	ev.speeding = pitLane && r.Car.Speed() > pitLaneLimit
+
+	r.distance += r.Car.Speed().In(MetersPerSecond) * dt.Seconds()
+	if lapLength := track.Length(); r.distance >= lapLength {
+		r.distance -= lapLength
+		r.lap++
+		ev.lapDone = true
+		ev.lapTime = now + dt - r.lapStart
+		r.lapStart = now + dt
+		r.finished = r.lap >= laps
+	}
+	return ev
+}
+
+// Standing is one line of the leaderboard
+type Standing struct {
+	Name      string
+	Laps      []time.Duration
+	Penalties int
+	Total     time.Duration // race time plus penalties
+	Finished  bool
+}
+
+func (s Standing) BestLap() time.Duration {
+	if len(s.Laps) == 0 {
+		return 0
+	}
+	return slices.Min(s.Laps)
+}
+
+// RaceControl runs the race on a tick clock. Every tick each racer advances in its own goroutine
+// and reports back on a channel, the controller waits for all of them before the clock moves on
+type RaceControl struct {
+	Track   Track
+	Laps    int
+	Tick    time.Duration
+	Penalty time.Duration // added for every pit lane visit spent speeding
+	// Give up on anyone still out there after this long
+	TimeLimit time.Duration
+}
+
+func (rc RaceControl) Run(racers []*Racer) ([]Standing, error) {
+	if len(rc.Track.Segments) == 0 || rc.Track.Length() <= 0 {
+		return nil, errors.New("track has no length")
+	}
+	if rc.Tick <= 0 || rc.TimeLimit <= 0 {
+		return nil, errors.New("tick and time limit must be positive")
+	}
+	if rc.Laps <= 0 {
+		return nil, fmt.Errorf("a race needs at least one lap, got %d", rc.Laps)
+	}
+	if rc.Track.PitSegment < 0 || rc.Track.PitSegment >= len(rc.Track.Segments) {
+		return nil, fmt.Errorf("pit lane is alongside segment %d, the track only has %d", rc.Track.PitSegment, len(rc.Track.Segments))
+	}
+	for _, r := range racers {
+		r.reset()
+	}
+
+	penalties := map[*Racer]int{}
+	flagged := map[*Racer]bool{} // at most one penalty per pit lane visit
+	finishedAt := map[*Racer]time.Duration{}
+
+	for now := time.Duration(0); len(finishedAt) < len(racers) && now < rc.TimeLimit; now += rc.Tick {
+		events := make(chan raceEvent, len(racers))
+		var wg sync.WaitGroup
+		for _, r := range racers {
+			if r.finished {
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				events <- r.tick(rc.Track, rc.Laps, now, rc.Tick)
+			}()
+		}
+		// close once everyone has reported so the range below knows when the tick is over
+		go func() {
+			wg.Wait()
+			close(events)
+		}()
+
+		for ev := range events {
+			r := ev.racer
+			if !r.inPits {
+				flagged[r] = false
+			}
+			if ev.speeding && !flagged[r] {
+				flagged[r] = true
+				penalties[r]++
+			}
+			if ev.lapDone {
+				r.laps = append(r.laps, ev.lapTime)
+			}
+			if r.finished {
+				finishedAt[r] = now + rc.Tick
+			}
+		}
+	}
+
+	standings := make([]Standing, 0, len(racers))
+	for _, r := range racers {
+		total, finished := finishedAt[r]
+		standings = append(standings, Standing{
+			Name:      r.Name,
+			Laps:      r.laps,
+			Penalties: penalties[r],
+			Total:     total + time.Duration(penalties[r])*rc.Penalty,
+			Finished:  finished,
+		})
+	}
+	// finishers by time, then everyone else by how far they got
+	slices.SortStableFunc(standings, func(a, b Standing) int {
+		switch {
+		case a.Finished != b.Finished:
+			if a.Finished {
+				return -1
+			}
+			return 1
+		case !a.Finished:
+			return len(b.Laps) - len(a.Laps)
+		}
+		return cmp.Compare(a.Total, b.Total)
+	})
+	return standings, nil
+}
+
+func printLeaderboard(w io.Writer, standings []Standing) {
+	for i, s := range standings {
+		result := s.Total.Round(time.Millisecond).String()
+		if !s.Finished {
+			result = fmt.Sprintf("DNF (%d laps)", len(s.Laps))
+		}
+		fmt.Fprintf(w, "P%d %-12s %-14s best lap %-8v penalties %d\n",
+			i+1, s.Name, result, s.BestLap().Round(time.Millisecond), s.Penalties)
+	}
+}
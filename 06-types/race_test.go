@@ -0,0 +1,116 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func testRace() RaceControl {
+	return RaceControl{
+		Track: Track{
+			Name: "Test",
+			Segments: []Segment{
+				{Name: "Pit straight", Length: 300},
+				{Name: "Hairpin", Length: 100, SpeedLimit: 60},
+				{Name: "Back straight", Length: 400},
+			},
+		},
+		Laps:      3,
+		Tick:      100 * time.Millisecond,
+		Penalty:   5 * time.Second,
+		TimeLimit: 10 * time.Minute,
+	}
+}
+
+func testRacers() []*Racer {
+	return []*Racer{
+		{Name: "Golf", Car: &Vehicle{topSpeed: 246}, PitLaps: []int{2}},
+		{Name: "Range Rover", Car: &Truck{Vehicle: Vehicle{topSpeed: 190}, maxAcceleration: 5}},
+		{Name: "Polo", Car: &Vehicle{topSpeed: 200}, PitLaps: []int{2}, Reckless: true},
+	}
+}
+
+// Every racer ticks in its own goroutine, run with -race to have the race detector check the hand offs
+func TestRaceRun(t *testing.T) {
+	rc := testRace()
+	standings, err := rc.Run(testRacers())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(standings) != 3 {
+		t.Fatalf("got %d standings, want 3", len(standings))
+	}
+	for _, s := range standings {
+		if !s.Finished || len(s.Laps) != rc.Laps {
+			t.Errorf("%s finished %v after %d laps, want all %d", s.Name, s.Finished, len(s.Laps), rc.Laps)
+		}
+	}
+	if !slices.IsSortedFunc(standings, func(a, b Standing) int { return int(a.Total - b.Total) }) {
+		t.Errorf("standings aren't in finishing order: %+v", standings)
+	}
+	for _, s := range standings {
+		switch s.Name {
+		case "Polo":
+			if s.Penalties != 1 {
+				t.Errorf("the reckless Polo speeding through the pits once got %d penalties, want 1", s.Penalties)
+			}
+		default:
+			if s.Penalties != 0 {
+				t.Errorf("%s got %d penalties for driving by the rules", s.Name, s.Penalties)
+			}
+		}
+	}
+}
+
+// Running the same racers again starts them from the grid, not from where the last race left them
+func TestRaceRunTwice(t *testing.T) {
+	rc := testRace()
+	racers := testRacers()
+	first, err := rc.Run(racers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := rc.Run(racers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.EqualFunc(first, second, func(a, b Standing) bool {
+		return a.Name == b.Name && a.Total == b.Total && slices.Equal(a.Laps, b.Laps)
+	}) {
+		t.Errorf("a rerun came out different\nfirst  %+v\nsecond %+v", first, second)
+	}
+}
+
+func TestRaceRunRefusesBadSetup(t *testing.T) {
+	for name, change := range map[string]func(*RaceControl){
+		"no laps":              func(rc *RaceControl) { rc.Laps = 0 },
+		"negative laps":        func(rc *RaceControl) { rc.Laps = -1 },
+		"pit segment too high": func(rc *RaceControl) { rc.Track.PitSegment = 3 },
+		"negative pit segment": func(rc *RaceControl) { rc.Track.PitSegment = -1 },
+		"no track":             func(rc *RaceControl) { rc.Track.Segments = nil },
+		"no tick":              func(rc *RaceControl) { rc.Tick = 0 },
+	} {
+		t.Run(name, func(t *testing.T) {
+			rc := testRace()
+			change(&rc)
+			if _, err := rc.Run(testRacers()); err == nil {
+				t.Error("Run accepted it")
+			}
+		})
+	}
+}
+
+func TestRaceTimeLimit(t *testing.T) {
+	rc := testRace()
+	rc.TimeLimit = 10 * time.Second
+	standings, err := rc.Run(testRacers())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range standings {
+		if s.Finished {
+			t.Errorf("%s finished 3 laps of 800 m inside 10 s", s.Name)
+		}
+	}
+}
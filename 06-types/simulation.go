@@ -0,0 +1,271 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+//////////////////////////////////////////////////////////////////////
+//                     Simulation                                   //
+//////////////////////////////////////////////////////////////////////
+
+// Vehicle.Accelerate just bumps a number. Real cars fight drag, rolling resistance and their own mass,
+// so here we step through time and let the forces decide how fast we get going
+
+const (
+	airDensity = 1.225 // kg/m³ at sea level
+	gravity    = 9.81  // m/s²
+)
+
+// AccelerationCurve returns the maximum tractive force (N) available at a given velocity (m/s)
+type AccelerationCurve func(velocity float64) float64
+
+// Physics describes the forces acting on a vehicle
+type Physics struct {
+	Mass         float64 // kg
+	DragArea     float64 // drag coefficient times frontal area, m²
+	RollingCoeff float64 // rolling resistance coefficient
+	Power        float64 // peak engine power, W
+	MaxTraction  float64 // grip limited force, N
+	BrakeForce   float64 // force at full brake, N
+	// Curve overrides the default power curve if set
+	Curve AccelerationCurve
+}
+
+// Below top speed the engine is either grip limited (low speeds) or power limited (high speeds)
+// P = F * v, so the force we can push falls off as we go faster
+func (p Physics) tractiveForce(velocity float64) float64 {
+	if p.Curve != nil {
+		return p.Curve(velocity)
+	}
+	if velocity <= 0 {
+		return p.MaxTraction
+	}
+	return math.Min(p.MaxTraction, p.Power/velocity)
+}
+
+// step advances velocity by dt seconds. Throttle goes from -1 (full brake) to 1 (full throttle)
+func (p Physics) step(velocity, throttle, dt float64) float64 {
+	var drive, resist float64
+	if throttle > 0 {
+		drive = math.Min(throttle, 1) * p.tractiveForce(velocity)
+	} else {
+		resist += math.Min(-throttle, 1) * p.BrakeForce
+	}
+	if velocity > 0 {
+		resist += 0.5 * airDensity * p.DragArea * velocity * velocity
+		resist += p.RollingCoeff * p.Mass * gravity
+	}
+
+	velocity += (drive - resist) / p.Mass * dt
+	// brakes and drag stop a car, they don't throw it into reverse
+	return math.Max(velocity, 0)
+}
+
+func (p Physics) validate() error {
+	if p.Mass <= 0 {
+		return errors.New("vehicle has no mass")
+	}
+	if p.BrakeForce <= 0 {
+		return errors.New("vehicle has no brakes")
+	}
+	return nil
+}
+
+// ThrottleStep holds a throttle position for a period of time
+type ThrottleStep struct {
+	Throttle float64
+	For      time.Duration
+}
+
+type ThrottleProfile []ThrottleStep
+
+func (tp ThrottleProfile) Duration() time.Duration {
+	var total time.Duration
+	for _, step := range tp {
+		total += step.For
+	}
+	return total
+}
+
+// throttleAt returns the throttle position at elapsed time t. Past the end of the profile we coast
+func (tp ThrottleProfile) throttleAt(t time.Duration) float64 {
+	for _, step := range tp {
+		if t < step.For {
+			return step.Throttle
+		}
+		t -= step.For
+	}
+	return 0
+}
+
+// Sample is a single point in the simulated time series
+type Sample struct {
+	At       time.Duration
+	Speed    Speed
+	Velocity float64 // m/s
+	Position float64 // m
+}
+
+// The vehicle's top speed is a hard ceiling. A zero top speed means we were never told one
+func (v Vehicle) maxVelocity() float64 {
+	if v.topSpeed <= 0 {
+		return math.Inf(1)
+	}
+	return v.topSpeed.In(MetersPerSecond)
+}
+
+// Simulate runs the vehicle through a throttle profile, sampling every dt
+// The vehicle is a value, so simulating never changes the real one
+func Simulate(v Vehicle, profile ThrottleProfile, dt time.Duration) ([]Sample, error) {
+	if dt <= 0 {
+		return nil, errors.New("time step must be positive")
+	}
+	if err := v.physics.validate(); err != nil {
+		return nil, err
+	}
+
+	velocity := v.speed.In(MetersPerSecond)
+	position := 0.0
+	top := v.maxVelocity()
+	end := profile.Duration()
+	samples := make([]Sample, 0, int(end/dt)+1)
+
+	for t := time.Duration(0); ; t += dt {
+		samples = append(samples, Sample{
+			At:       t,
+			Speed:    NewSpeed(velocity, MetersPerSecond),
+			Velocity: velocity,
+			Position: position,
+		})
+		if t >= end {
+			return samples, nil
+		}
+		next := math.Min(v.physics.step(velocity, profile.throttleAt(t), dt.Seconds()), top)
+		// trapezoidal rule, the average of both ends is closer than either end alone
+		position += (velocity + next) / 2 * dt.Seconds()
+		velocity = next
+	}
+}
+
+const brakingStep = 10 * time.Millisecond
+
+// brakingCurve is full braking from some speed down to standstill, sampled every brakingStep
+// Braking only depends on the current velocity, not on how we got to it, so stopping from any slower
+// speed is just the tail of the same curve. One curve answers every "how far to stop from here"
+type brakingCurve struct {
+	velocity  []float64 // falling
+	remaining []float64 // distance still to go before standstill
+}
+
+func (p Physics) brakingCurve(from float64) brakingCurve {
+	dt := brakingStep.Seconds()
+	var c brakingCurve
+	var travelled []float64
+	distance := 0.0
+	for velocity := from; velocity > 0; {
+		c.velocity = append(c.velocity, velocity)
+		travelled = append(travelled, distance)
+		next := p.step(velocity, -1, dt)
+		distance += (velocity + next) / 2 * dt
+		velocity = next
+	}
+	c.remaining = make([]float64, len(travelled))
+	for i, d := range travelled {
+		c.remaining[i] = distance - d
+	}
+	return c
+}
+
+// stoppingFrom is the distance to stop from velocity, rounded up to the sample at or above it
+func (c brakingCurve) stoppingFrom(velocity float64) float64 {
+	// velocity falls along the curve, so the samples below ours come after it
+	i := sort.Search(len(c.velocity), func(i int) bool { return c.velocity[i] < velocity })
+	if i == 0 {
+		if len(c.remaining) == 0 {
+			return 0
+		}
+		return c.remaining[0]
+	}
+	return c.remaining[i-1]
+}
+
+// BrakingDistance is how far (m) the vehicle travels under full braking from a given speed until it stops
+// It reads it off the same braking curve ETA uses, so the two can never disagree
+func BrakingDistance(v Vehicle, from Speed) (float64, error) {
+	if err := v.physics.validate(); err != nil {
+		return 0, err
+	}
+	if from < 0 {
+		return 0, errors.New("speed can't be negative")
+	}
+	velocity := from.In(MetersPerSecond)
+	return v.physics.brakingCurve(velocity).stoppingFrom(velocity), nil
+}
+
+var ErrCannotReachCruise = errors.New("vehicle cannot reach cruise speed")
+
+// maxETASteps bounds the simulation. Cruising is worked out in one go, so only speeding up and slowing
+// down are stepped through, and no car takes this many steps to do either
+const maxETASteps = 1_000_000
+
+// ETA estimates how long it takes to cover distance (m) from standstill, cruising at the given speed,
+// and stopping at the end. We floor it up to cruise, hold, and brake when the stopping distance runs out
+// If the forces balance out below cruise, the car never gets there and we say so
+func ETA(v Vehicle, distance float64, cruise Speed) (time.Duration, error) {
+	if err := v.physics.validate(); err != nil {
+		return 0, err
+	}
+	if cruise <= 0 {
+		return 0, errors.New("cruise speed must be positive")
+	}
+	if v.physics.tractiveForce(0) <= 0 {
+		return 0, errors.New("vehicle cannot pull away")
+	}
+	dt := brakingStep.Seconds()
+	target := math.Min(cruise.In(MetersPerSecond), v.maxVelocity())
+	if v.physics.step(target, 1, dt) <= target {
+		// flat out at cruise and still not speeding up, so the car tops out below it
+		return 0, fmt.Errorf("%w: drag and rolling resistance win below %v", ErrCannotReachCruise, NewSpeed(target, MetersPerSecond))
+	}
+	stopping := v.physics.brakingCurve(target)
+
+	var elapsed time.Duration
+	velocity, position := 0.0, 0.0
+	braking := false
+	for steps := 0; position < distance; steps++ {
+		if steps >= maxETASteps {
+			return 0, fmt.Errorf("%w: still at %v after %v", ErrCannotReachCruise, NewSpeed(velocity, MetersPerSecond), elapsed)
+		}
+		if !braking && distance-position <= stopping.stoppingFrom(velocity) {
+			braking = true
+		}
+		if !braking && velocity >= target {
+			// at cruise there's nothing to simulate, hold it right up to the braking point
+			cruising := distance - position - stopping.stoppingFrom(velocity)
+			elapsed += time.Duration(cruising / velocity * float64(time.Second))
+			position += cruising
+			braking = true
+		}
+		throttle := 1.0
+		if braking {
+			throttle = -1
+		}
+
+		next := math.Min(v.physics.step(velocity, throttle, dt), target)
+		if !braking && next <= velocity {
+			return 0, fmt.Errorf("%w: drag and rolling resistance win at %v", ErrCannotReachCruise, NewSpeed(velocity, MetersPerSecond))
+		}
+		position += (velocity + next) / 2 * dt
+		velocity = next
+		elapsed += brakingStep
+		if braking && velocity == 0 {
+			// we've stopped, whatever is left over is rounding from the time step
+			break
+		}
+	}
+	return elapsed, nil
+}
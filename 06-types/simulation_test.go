@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func testCar() Vehicle {
+	return Vehicle{
+		model:    "Golf GTI",
+		topSpeed: 246,
+		physics: Physics{
+			Mass:         1400,
+			DragArea:     0.7,
+			RollingCoeff: 0.012,
+			Power:        169000,
+			MaxTraction:  9000,
+			BrakeForce:   12000,
+		},
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	car := testCar()
+	car.topSpeed = 50
+	profile := ThrottleProfile{
+		{Throttle: 1, For: 10 * time.Second},
+		{Throttle: -1, For: 10 * time.Second},
+	}
+	samples, err := Simulate(car, profile, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(samples), 201; got != want {
+		t.Fatalf("got %d samples, want %d, one every step and both ends", got, want)
+	}
+	for i, s := range samples {
+		if s.Speed > car.topSpeed+1e-9 || s.Velocity < 0 {
+			t.Fatalf("sample %d at %s is outside 0 to top speed", i, s.Speed)
+		}
+		if i > 0 && s.Position < samples[i-1].Position {
+			t.Fatalf("sample %d went backwards", i)
+		}
+	}
+	if mid := samples[100]; math.Abs(float64(mid.Speed-car.topSpeed)) > 1e-9 {
+		t.Errorf("10 s flat out should hit the %s top speed, got %s", car.topSpeed, mid.Speed)
+	}
+	if last := samples[len(samples)-1]; last.Velocity != 0 {
+		t.Errorf("10 s of full braking should stop the car, still at %s", last.Speed)
+	}
+
+	if _, err := Simulate(car, profile, 0); err == nil {
+		t.Error("a zero time step should be refused")
+	}
+	if _, err := Simulate(Vehicle{}, profile, time.Second); err == nil {
+		t.Error("a vehicle without physics should be refused")
+	}
+}
+
+func TestBrakingDistance(t *testing.T) {
+	// no drag or rolling resistance, so it's the textbook v²/2a
+	car := Vehicle{physics: Physics{Mass: 1000, BrakeForce: 10000}}
+	got, err := BrakingDistance(car, 72) // 20 m/s at 10 m/s² is 20 m
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(got-20) > 0.1 {
+		t.Errorf("BrakingDistance from 72 km/h = %.2f m, want 20", got)
+	}
+
+	if d, err := BrakingDistance(car, 0); err != nil || d != 0 {
+		t.Errorf("BrakingDistance from standstill = %v, %v, want 0", d, err)
+	}
+	if _, err := BrakingDistance(car, -10); err == nil {
+		t.Error("a negative speed should be refused")
+	}
+	if _, err := BrakingDistance(Vehicle{physics: Physics{Mass: 1000}}, 50); err == nil {
+		t.Error("a vehicle without brakes should be refused")
+	}
+
+	// ETA brakes along the same curve, so a stop from cruise takes exactly what BrakingDistance says
+	golf := testCar()
+	slow, _ := BrakingDistance(golf, 50)
+	fast, _ := BrakingDistance(golf, 100)
+	if !(0 < slow && slow < fast) {
+		t.Errorf("stopping from 50 km/h takes %.1f m and from 100 km/h %.1f m", slow, fast)
+	}
+}
+
+func TestETA(t *testing.T) {
+	golf := testCar()
+	long, err := ETA(golf, 50_000, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 50 km at 100 km/h is 30 minutes, plus a little for speeding up and slowing down
+	if long < 30*time.Minute || long > 31*time.Minute {
+		t.Errorf("50 km at 100 km/h took %v, want just over 30m", long)
+	}
+
+	short, err := ETA(golf, 1, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if short <= 0 || short > 2*time.Second {
+		t.Errorf("1 m took %v, should be a second or so without ever reaching cruise", short)
+	}
+	if none, err := ETA(golf, 0, 100); err != nil || none != 0 {
+		t.Errorf("going nowhere took %v, %v", none, err)
+	}
+
+	weak := Vehicle{physics: Physics{Mass: 1000, DragArea: 0.7, RollingCoeff: 0.3, Power: 5000, MaxTraction: 4000, BrakeForce: 8000}}
+	if _, err := ETA(weak, 5000, 80); !errors.Is(err, ErrCannotReachCruise) {
+		t.Errorf("a car that tops out below cruise: got %v, want ErrCannotReachCruise", err)
+	}
+	if _, err := ETA(golf, 5000, 0); err == nil {
+		t.Error("a cruise speed of 0 should be refused")
+	}
+}
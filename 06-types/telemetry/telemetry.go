@@ -0,0 +1,130 @@
+package telemetry
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"time"
+)
+
+// Sample is a single reading taken at a point in time
+// Value is whatever we're recording, for a vehicle it's the speed in km/h
+type Sample struct {
+	At    time.Time
+	Value float64
+}
+
+var ErrNoSamples = errors.New("no samples")
+
+type Summary struct {
+	Count          int
+	Min, Max, Mean float64
+	From, To       time.Time
+}
+
+func Summarize(samples []Sample) (Summary, error) {
+	if len(samples) == 0 {
+		return Summary{}, ErrNoSamples
+	}
+	s := Summary{
+		Count: len(samples),
+		Min:   math.Inf(1),
+		Max:   math.Inf(-1),
+		From:  samples[0].At,
+		To:    samples[len(samples)-1].At,
+	}
+	total := 0.0
+	for _, sample := range samples {
+		s.Min = math.Min(s.Min, sample.Value)
+		s.Max = math.Max(s.Max, sample.Value)
+		total += sample.Value
+	}
+	s.Mean = total / float64(len(samples))
+	return s, nil
+}
+
+// Percentile returns the p-th percentile (0-100), interpolating between the two closest ranks
+func Percentile(samples []Sample, p float64) (float64, error) {
+	if len(samples) == 0 {
+		return 0, ErrNoSamples
+	}
+	if p < 0 || p > 100 {
+		return 0, errors.New("percentile must be between 0 and 100")
+	}
+	values := make([]float64, len(samples))
+	for i, s := range samples {
+		values[i] = s.Value
+	}
+	slices.Sort(values)
+
+	rank := p / 100 * float64(len(values)-1)
+	lo, hi := int(math.Floor(rank)), int(math.Ceil(rank))
+	return values[lo] + (values[hi]-values[lo])*(rank-float64(lo)), nil
+}
+
+// MovingAverage replaces each sample with the mean of every sample within window before it (itself included)
+// Samples are expected in time order. A window of 0 or less holds nothing but the sample itself,
+// so the samples come back unchanged
+func MovingAverage(samples []Sample, window time.Duration) []Sample {
+	if window <= 0 {
+		return slices.Clone(samples)
+	}
+	out := make([]Sample, len(samples))
+	start, total := 0, 0.0
+	for i, s := range samples {
+		total += s.Value
+		for s.At.Sub(samples[start].At) > window {
+			total -= samples[start].Value
+			start++
+		}
+		out[i] = Sample{At: s.At, Value: total / float64(i-start+1)}
+	}
+	return out
+}
+
+// Event marks a change between two consecutive samples that crossed the threshold
+// Rate is in units per second. Positive for acceleration, negative for braking
+type Event struct {
+	From, To Sample
+	Rate     float64
+}
+
+// HardAccelerations finds every pair of consecutive samples whose rate of change is at least threshold
+// units per second, either way. Pass threshold in the samples' unit, km/h per second for speeds
+func HardAccelerations(samples []Sample, threshold float64) []Event {
+	var events []Event
+	for i := 1; i < len(samples); i++ {
+		from, to := samples[i-1], samples[i]
+		elapsed := to.At.Sub(from.At).Seconds()
+		if elapsed <= 0 {
+			// two readings at the same instant tell us nothing about rate
+			continue
+		}
+		rate := (to.Value - from.Value) / elapsed
+		if math.Abs(rate) >= threshold {
+			events = append(events, Event{From: from, To: to, Rate: rate})
+		}
+	}
+	return events
+}
+
+// Downsample shrinks a long history down to at most n samples by averaging equal sized buckets
+// Each bucket is stamped with the time of its first sample
+func Downsample(samples []Sample, n int) []Sample {
+	if n <= 0 {
+		return nil
+	}
+	if len(samples) <= n {
+		return slices.Clone(samples)
+	}
+	out := make([]Sample, 0, n)
+	for b := range n {
+		lo, hi := b*len(samples)/n, (b+1)*len(samples)/n
+		total := 0.0
+		for _, s := range samples[lo:hi] {
+			total += s.Value
+		}
+		out = append(out, Sample{At: samples[lo].At, Value: total / float64(hi-lo)})
+	}
+	return out
+}
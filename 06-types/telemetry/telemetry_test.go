@@ -0,0 +1,140 @@
+package telemetry
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// series makes one sample a second with the given values
+func series(values ...float64) []Sample {
+	samples := make([]Sample, len(values))
+	for i, v := range values {
+		samples[i] = Sample{At: start.Add(time.Duration(i) * time.Second), Value: v}
+	}
+	return samples
+}
+
+func valuesOf(samples []Sample) []float64 {
+	values := make([]float64, len(samples))
+	for i, s := range samples {
+		values[i] = s.Value
+	}
+	return values
+}
+
+func TestSummarize(t *testing.T) {
+	if _, err := Summarize(nil); !errors.Is(err, ErrNoSamples) {
+		t.Errorf("Summarize(nil) error = %v, want ErrNoSamples", err)
+	}
+	got, err := Summarize(series(30, 10, 20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Summary{Count: 3, Min: 10, Max: 30, Mean: 20, From: start, To: start.Add(2 * time.Second)}
+	if got != want {
+		t.Errorf("Summarize = %+v, want %+v", got, want)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	samples := series(50, 10, 40, 20, 30)
+	for _, tc := range []struct {
+		p    float64
+		want float64
+	}{
+		{0, 10},
+		{100, 50},
+		{50, 30},
+		{90, 46}, // 90% of the way from rank 0 to 4 is 3.6, between 40 and 50
+	} {
+		got, err := Percentile(samples, tc.p)
+		if err != nil || got != tc.want {
+			t.Errorf("Percentile(%v) = %v, %v, want %v", tc.p, got, err, tc.want)
+		}
+	}
+	for _, p := range []float64{-1, 100.5} {
+		if _, err := Percentile(samples, p); err == nil {
+			t.Errorf("Percentile(%v) should be refused", p)
+		}
+	}
+	if _, err := Percentile(nil, 50); !errors.Is(err, ErrNoSamples) {
+		t.Errorf("Percentile(nil) error = %v, want ErrNoSamples", err)
+	}
+	if got, _ := Percentile(series(7), 90); got != 7 {
+		t.Errorf("Percentile of a single sample = %v, want 7", got)
+	}
+}
+
+func TestHardAccelerations(t *testing.T) {
+	samples := series(0, 5, 15, 15, 3)
+	samples = append(samples, Sample{At: samples[4].At, Value: 100}) // same instant, no rate to speak of
+
+	var got []float64
+	for _, ev := range HardAccelerations(samples, 10) {
+		got = append(got, ev.Rate)
+	}
+	// 0 -> 5 is under the threshold, exactly 10 counts, braking counts too
+	if want := []float64{10, -12}; !slices.Equal(got, want) {
+		t.Errorf("rates over 10/s = %v, want %v", got, want)
+	}
+	if evs := HardAccelerations(samples, 100); len(evs) != 0 {
+		t.Errorf("nothing reaches 100/s, got %v", evs)
+	}
+}
+
+func TestDownsample(t *testing.T) {
+	samples := series(1, 2, 3, 4, 5, 6, 7)
+	for _, tc := range []struct {
+		n    int
+		want []float64
+	}{
+		{0, nil},
+		{-1, nil},
+		{10, []float64{1, 2, 3, 4, 5, 6, 7}},
+		{7, []float64{1, 2, 3, 4, 5, 6, 7}},
+		{3, []float64{1.5, 3.5, 6}},
+		{1, []float64{4}},
+	} {
+		got := Downsample(samples, tc.n)
+		if !slices.Equal(valuesOf(got), tc.want) && !(len(got) == 0 && len(tc.want) == 0) {
+			t.Errorf("Downsample(%d) = %v, want %v", tc.n, valuesOf(got), tc.want)
+		}
+	}
+	// each bucket carries the time of its first sample
+	if got := Downsample(samples, 3); !got[1].At.Equal(samples[2].At) {
+		t.Errorf("second bucket stamped %v, want %v", got[1].At, samples[2].At)
+	}
+}
+
+func TestMovingAverage(t *testing.T) {
+	samples := series(10, 20, 30, 40, 50)
+
+	for _, tc := range []struct {
+		name   string
+		window time.Duration
+		want   []float64
+	}{
+		{"negative window", -time.Second, []float64{10, 20, 30, 40, 50}},
+		{"zero window", 0, []float64{10, 20, 30, 40, 50}},
+		{"one second", time.Second, []float64{10, 15, 25, 35, 45}},
+		{"wider than the history", time.Minute, []float64{10, 15, 20, 25, 30}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := MovingAverage(samples, tc.window)
+			values := make([]float64, len(got))
+			for i, s := range got {
+				values[i] = s.Value
+				if !s.At.Equal(samples[i].At) {
+					t.Errorf("sample %d moved from %v to %v", i, samples[i].At, s.At)
+				}
+			}
+			if !slices.Equal(values, tc.want) {
+				t.Errorf("MovingAverage(%v) = %v, want %v", tc.window, values, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//////////////////////////////////////////////////////////////////////
+//                     Units                                        //
+//////////////////////////////////////////////////////////////////////
+
+// A Speed is always km/h on the inside. Other units only exist at the edges, when a speed comes in
+// from somewhere (NewSpeed, ParseSpeed) or goes out to someone (In, StringIn).
+// As long as nobody does Speed(someMph) we can never mix them up
+
+type SpeedUnit int
+
+const (
+	KilometersPerHour SpeedUnit = iota
+	MilesPerHour
+	MetersPerSecond
+)
+
+// how many km/h one of each unit is worth
+var kphPer = map[SpeedUnit]float64{
+	KilometersPerHour: 1,
+	MilesPerHour:      1.609344,
+	MetersPerSecond:   3.6,
+}
+
+func (u SpeedUnit) String() string {
+	switch u {
+	case KilometersPerHour:
+		return "km/h"
+	case MilesPerHour:
+		return "mph"
+	case MetersPerSecond:
+		return "m/s"
+	}
+	return fmt.Sprintf("SpeedUnit(%d)", int(u))
+}
+
+// Different data sources spell the same unit differently
+var speedUnitNames = map[string]SpeedUnit{
+	"km/h": KilometersPerHour,
+	"kmh":  KilometersPerHour,
+	"kph":  KilometersPerHour,
+	"mph":  MilesPerHour,
+	"mi/h": MilesPerHour,
+	"m/s":  MetersPerSecond,
+	"mps":  MetersPerSecond,
+}
+
+// kph is how many km/h one of the unit is worth. A missing unit would read as 0 and quietly turn every
+// speed into 0 or ±Inf. The only way to get one is converting some random int with SpeedUnit(n),
+// which is a bug, so it panics like indexing past the end of a slice would
+func (u SpeedUnit) kph() float64 {
+	factor, ok := kphPer[u]
+	if !ok {
+		panic(fmt.Sprintf("unknown speed unit %s", u))
+	}
+	return factor
+}
+
+func NewSpeed(value float64, unit SpeedUnit) Speed {
+	return Speed(value * unit.kph())
+}
+
+func (s Speed) In(unit SpeedUnit) float64 {
+	return float64(s) / unit.kph()
+}
+
+func (s Speed) String() string {
+	return s.StringIn(KilometersPerHour)
+}
+
+// StringIn formats the speed to one decimal place, dropping it when it's a whole number
+func (s Speed) StringIn(unit SpeedUnit) string {
+	value := strconv.FormatFloat(s.In(unit), 'f', 1, 64)
+	return strings.TrimSuffix(value, ".0") + " " + unit.String()
+}
+
+// ParseSpeed reads speeds like "120km/h", "75 mph" or "33.3 m/s"
+// A bare number is rejected, guessing the unit is exactly how they get mixed up. So is a negative one,
+// a speed says how fast, the direction is somebody else's business
+func ParseSpeed(s string) (Speed, error) {
+	s = strings.TrimSpace(s)
+	split := strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= '0' && r <= '9' || r == '.' || r == '-' || r == '+')
+	})
+	if split <= 0 {
+		return 0, fmt.Errorf("speed %q needs a number followed by a unit", s)
+	}
+
+	value, err := strconv.ParseFloat(s[:split], 64)
+	if err != nil {
+		return 0, fmt.Errorf("speed %q: %w", s, err)
+	}
+	if value < 0 {
+		return 0, fmt.Errorf("speed %q is negative", s)
+	}
+	unit, ok := speedUnitNames[strings.ToLower(strings.TrimSpace(s[split:]))]
+	if !ok {
+		return 0, fmt.Errorf("speed %q has an unknown unit", s)
+	}
+	return NewSpeed(value, unit), nil
+}
+
+// The Benz Patent-Motorwagen, nothing we track can be older than that
+const firstCarYear Year = 1886
+
+// Validate checks the year could belong to a real car. Manufacturers sell next year's model
+// before the calendar gets there, so one year ahead of the clock is still fine
+func (y Year) Validate() error {
+	latest := Year(clock().Year() + 1)
+	if y < firstCarYear {
+		return fmt.Errorf("year %d is before the first car was built in %d", y, firstCarYear)
+	}
+	if y > latest {
+		return fmt.Errorf("year %d is after %d", y, latest)
+	}
+	return nil
+}
+
+// Age is how many years old a model is against the package clock
+func (y Year) Age() int {
+	return y.AgeAt(clock())
+}
+
+// AgeAt is how many years old a model is at a given time. Next year's model is brand new, not -1 years old
+func (y Year) AgeAt(t time.Time) int {
+	return max(t.Year()-int(y), 0)
+}
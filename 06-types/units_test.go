@@ -0,0 +1,56 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseSpeed(t *testing.T) {
+	for _, tc := range []struct {
+		in      string
+		want    Speed
+		wantErr bool
+	}{
+		{in: "120km/h", want: 120},
+		{in: " 75 MPH ", want: NewSpeed(75, MilesPerHour)},
+		{in: "10 m/s", want: 36},
+		{in: "0 kph", want: 0},
+		{in: "90", wantErr: true},
+		{in: "90 knots", wantErr: true},
+		{in: "-20 km/h", wantErr: true},
+		{in: "km/h", wantErr: true},
+		{in: "1.2.3 km/h", wantErr: true},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := ParseSpeed(tc.in)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("ParseSpeed(%q) = %s, want an error", tc.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSpeed(%q): %v", tc.in, err)
+			}
+			if math.Abs(float64(got-tc.want)) > 1e-9 {
+				t.Errorf("ParseSpeed(%q) = %s, want %s", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUnknownSpeedUnitPanics(t *testing.T) {
+	for name, convert := range map[string]func(){
+		"NewSpeed": func() { NewSpeed(10, SpeedUnit(42)) },
+		"In":       func() { Speed(10).In(SpeedUnit(42)) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("converting with an unknown unit didn't panic")
+				}
+			}()
+			convert()
+		})
+	}
+}
@@ -0,0 +1,336 @@
+package algo
+
+import (
+	"iter"
+	"maps"
+	"slices"
+	"testing"
+)
+
+// Every slice helper has a Seq twin. The tests run both on the same input and expect the same answer
+
+func TestMapFilterReduce(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		in      []int
+		doubled []int
+		evens   []int
+		sum     int
+	}{
+		{"empty", nil, []int{}, nil, 0},
+		{"one", []int{3}, []int{6}, nil, 3},
+		{"mixed", []int{1, 2, 3, 4, -6}, []int{2, 4, 6, 8, -12}, []int{2, 4, -6}, 4},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Map(tc.in, double); !slices.Equal(got, tc.doubled) {
+				t.Errorf("Map = %v, want %v", got, tc.doubled)
+			}
+			if got := slices.Collect(MapSeq(slices.Values(tc.in), double)); !slices.Equal(got, tc.doubled) {
+				t.Errorf("MapSeq = %v, want %v", got, tc.doubled)
+			}
+			if got := Filter(tc.in, isEven); !slices.Equal(got, tc.evens) {
+				t.Errorf("Filter = %v, want %v", got, tc.evens)
+			}
+			if got := slices.Collect(FilterSeq(slices.Values(tc.in), isEven)); !slices.Equal(got, tc.evens) {
+				t.Errorf("FilterSeq = %v, want %v", got, tc.evens)
+			}
+			if got := Reduce(tc.in, 0, add); got != tc.sum {
+				t.Errorf("Reduce = %d, want %d", got, tc.sum)
+			}
+			if got := ReduceSeq(slices.Values(tc.in), 0, add); got != tc.sum {
+				t.Errorf("ReduceSeq = %d, want %d", got, tc.sum)
+			}
+		})
+	}
+
+	// the accumulator doesn't have to be the element type
+	joined := Reduce([]int{1, 2, 3}, "", func(acc string, v int) string { return acc + string(rune('a'+v-1)) })
+	if joined != "abc" {
+		t.Errorf("Reduce into a string = %q, want abc", joined)
+	}
+}
+
+func TestGroupByKeepsOrder(t *testing.T) {
+	words := []string{"kiwi", "apple", "fig", "avocado", "banana", "kale", "blueberry"}
+	first := func(s string) byte { return s[0] }
+	want := map[byte][]string{
+		'k': {"kiwi", "kale"},
+		'a': {"apple", "avocado"},
+		'f': {"fig"},
+		'b': {"banana", "blueberry"},
+	}
+	for name, got := range map[string]map[byte][]string{
+		"GroupBy":    GroupBy(words, first),
+		"GroupBySeq": GroupBySeq(slices.Values(words), first),
+	} {
+		if !maps.EqualFunc(got, want, slices.Equal) {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestZip(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		as   []int
+		bs   []string
+		want []Pair[int, string]
+	}{
+		{"same length", []int{1, 2}, []string{"a", "b"}, []Pair[int, string]{{1, "a"}, {2, "b"}}},
+		{"first shorter", []int{1}, []string{"a", "b", "c"}, []Pair[int, string]{{1, "a"}}},
+		{"second shorter", []int{1, 2, 3}, []string{"a"}, []Pair[int, string]{{1, "a"}}},
+		{"one empty", nil, []string{"a"}, []Pair[int, string]{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Zip(tc.as, tc.bs); !slices.Equal(got, tc.want) {
+				t.Errorf("Zip = %v, want %v", got, tc.want)
+			}
+			got := []Pair[int, string]{}
+			for a, b := range ZipSeq(slices.Values(tc.as), slices.Values(tc.bs)) {
+				got = append(got, Pair[int, string]{a, b})
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("ZipSeq = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	nested := [][]int{{1, 2}, nil, {3}, {}, {4, 5}}
+	want := []int{1, 2, 3, 4, 5}
+	if got := Flatten(nested); !slices.Equal(got, want) {
+		t.Errorf("Flatten = %v, want %v", got, want)
+	}
+	inner := MapSeq(slices.Values(nested), func(s []int) iter.Seq[int] { return slices.Values(s) })
+	if got := slices.Collect(FlattenSeq(inner)); !slices.Equal(got, want) {
+		t.Errorf("FlattenSeq = %v, want %v", got, want)
+	}
+	if got := Flatten[int](nil); len(got) != 0 {
+		t.Errorf("Flatten(nil) = %v, want nothing", got)
+	}
+}
+
+func TestSortByIsStable(t *testing.T) {
+	words := []string{"pear", "fig", "plum", "kiwi", "yam", "lime", "date"}
+	got := SortBy(words, func(s string) int { return len(s) })
+	// same length words stay in the order they came in
+	want := []string{"fig", "yam", "pear", "plum", "kiwi", "lime", "date"}
+	if !slices.Equal(got, want) {
+		t.Errorf("SortBy length = %v, want %v", got, want)
+	}
+	if words[0] != "pear" {
+		t.Error("SortBy changed its input")
+	}
+}
+
+func TestMinByMaxBy(t *testing.T) {
+	words := []string{"fig", "banana", "kiwi", "yam", "cherry"}
+	length := func(s string) int { return len(s) }
+
+	for _, tc := range []struct {
+		name string
+		got  func(items []string) (string, bool)
+		want string
+	}{
+		// ties go to the first one in
+		{"MinBy", func(items []string) (string, bool) { return MinBy(items, length) }, "fig"},
+		{"MaxBy", func(items []string) (string, bool) { return MaxBy(items, length) }, "banana"},
+		{"MinBySeq", func(items []string) (string, bool) { return MinBySeq(slices.Values(items), length) }, "fig"},
+		{"MaxBySeq", func(items []string) (string, bool) { return MaxBySeq(slices.Values(items), length) }, "banana"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, ok := tc.got(words); !ok || got != tc.want {
+				t.Errorf("= %q %v, want %q", got, ok, tc.want)
+			}
+			if got, ok := tc.got(nil); ok || got != "" {
+				t.Errorf("on no items = %q %v, want the zero value and false", got, ok)
+			}
+		})
+	}
+}
+
+// naturals counts up forever and keeps track of how many values anything has pulled from it
+func naturals(pulled *int) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		for i := 0; ; i++ {
+			*pulled++
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+func TestTakeStopsAnEndlessSource(t *testing.T) {
+	for _, n := range []int{-1, 0, 1, 5} {
+		pulled := 0
+		got := slices.Collect(Take(naturals(&pulled), n))
+		if len(got) != max(n, 0) {
+			t.Errorf("Take(%d) gave %v", n, got)
+		}
+		if pulled != max(n, 0) {
+			t.Errorf("Take(%d) pulled %d values from the source, want %d", n, pulled, max(n, 0))
+		}
+	}
+
+	// the whole lazy chain stops too, even though the filter throws half the values away
+	pulled := 0
+	chain := Take(MapSeq(FilterSeq(naturals(&pulled), isEven), double), 3)
+	if got, want := slices.Collect(chain), []int{0, 4, 8}; !slices.Equal(got, want) {
+		t.Errorf("chain = %v, want %v", got, want)
+	}
+	if pulled != 5 {
+		t.Errorf("chain pulled %d values, want the 5 it needed to find 3 evens", pulled)
+	}
+
+	// breaking out early stops it as well
+	pulled = 0
+	for v := range Take(naturals(&pulled), 100) {
+		if v == 2 {
+			break
+		}
+	}
+	if pulled != 3 {
+		t.Errorf("breaking at 2 pulled %d values, want 3", pulled)
+	}
+}
+
+// Each helper is benchmarked against the loop it replaces, written out by hand. The gap between
+// the two is what the function value call costs, the rest is the same work either way
+// Compare them with go test -bench . -benchmem ./algo
+
+var (
+	benchInts = func() []int {
+		out := make([]int, 10_000)
+		for i := range out {
+			out[i] = i
+		}
+		return out
+	}()
+	double = func(v int) int { return v * 2 }
+	isEven = func(v int) bool { return v%2 == 0 }
+	add    = func(a, b int) int { return a + b }
+	mod10  = func(v int) int { return v % 10 }
+
+	// results go here so the compiler can't decide the loops are pointless and drop them
+	sinkInts  []int
+	sinkInt   int
+	sinkGroup map[int][]int
+)
+
+func BenchmarkMap(b *testing.B) {
+	for b.Loop() {
+		sinkInts = Map(benchInts, double)
+	}
+}
+
+func BenchmarkMapLoop(b *testing.B) {
+	for b.Loop() {
+		out := make([]int, len(benchInts))
+		for i, v := range benchInts {
+			out[i] = v * 2
+		}
+		sinkInts = out
+	}
+}
+
+func BenchmarkFilter(b *testing.B) {
+	for b.Loop() {
+		sinkInts = Filter(benchInts, isEven)
+	}
+}
+
+func BenchmarkFilterLoop(b *testing.B) {
+	for b.Loop() {
+		var out []int
+		for _, v := range benchInts {
+			if v%2 == 0 {
+				out = append(out, v)
+			}
+		}
+		sinkInts = out
+	}
+}
+
+func BenchmarkReduce(b *testing.B) {
+	for b.Loop() {
+		sinkInt = Reduce(benchInts, 0, add)
+	}
+}
+
+func BenchmarkReduceLoop(b *testing.B) {
+	for b.Loop() {
+		sum := 0
+		for _, v := range benchInts {
+			sum += v
+		}
+		sinkInt = sum
+	}
+}
+
+func BenchmarkGroupBy(b *testing.B) {
+	for b.Loop() {
+		sinkGroup = GroupBy(benchInts, mod10)
+	}
+}
+
+func BenchmarkGroupByLoop(b *testing.B) {
+	for b.Loop() {
+		groups := map[int][]int{}
+		for _, v := range benchInts {
+			groups[v%10] = append(groups[v%10], v)
+		}
+		sinkGroup = groups
+	}
+}
+
+// The lazy chain walks the input once and never builds the in between slices
+
+func BenchmarkMapFilterSeq(b *testing.B) {
+	for b.Loop() {
+		sinkInts = slices.Collect(MapSeq(FilterSeq(slices.Values(benchInts), isEven), double))
+	}
+}
+
+func BenchmarkMapFilter(b *testing.B) {
+	for b.Loop() {
+		sinkInts = Map(Filter(benchInts, isEven), double)
+	}
+}
+
+func BenchmarkMapFilterLoop(b *testing.B) {
+	for b.Loop() {
+		var out []int
+		for _, v := range benchInts {
+			if v%2 == 0 {
+				out = append(out, v*2)
+			}
+		}
+		sinkInts = out
+	}
+}
+
+func BenchmarkReduceSeq(b *testing.B) {
+	for b.Loop() {
+		sinkInt = ReduceSeq(slices.Values(benchInts), 0, add)
+	}
+}
+
+func BenchmarkSortBy(b *testing.B) {
+	reversed := slices.Clone(benchInts)
+	slices.Reverse(reversed)
+	for b.Loop() {
+		sinkInts = SortBy(reversed, mod10)
+	}
+}
+
+func BenchmarkSortByLoop(b *testing.B) {
+	reversed := slices.Clone(benchInts)
+	slices.Reverse(reversed)
+	for b.Loop() {
+		out := slices.Clone(reversed)
+		slices.SortStableFunc(out, func(a, b int) int { return a%10 - b%10 })
+		sinkInts = out
+	}
+}
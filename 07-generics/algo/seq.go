@@ -0,0 +1,113 @@
+package algo
+
+import (
+	"cmp"
+	"iter"
+)
+
+// The Seq versions are lazy. Nothing runs until something ranges over the result, and then only as far
+// as it ranges. MapSeq(FilterSeq(...)) walks the input once, and breaking out of the loop early stops
+// the whole chain. Every function passes a false from yield back up, so that stop actually happens
+
+func MapSeq[T, U any](seq iter.Seq[T], f func(T) U) iter.Seq[U] {
+	return func(yield func(U) bool) {
+		for v := range seq {
+			if !yield(f(v)) {
+				return
+			}
+		}
+	}
+}
+
+func FilterSeq[T any](seq iter.Seq[T], keep func(T) bool) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for v := range seq {
+			if keep(v) && !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// ReduceSeq has to see every value, so it's the one place a Seq gets consumed eagerly
+func ReduceSeq[T, A any](seq iter.Seq[T], initial A, f func(A, T) A) A {
+	acc := initial
+	for v := range seq {
+		acc = f(acc, v)
+	}
+	return acc
+}
+
+func GroupBySeq[T any, K comparable](seq iter.Seq[T], key func(T) K) map[K][]T {
+	groups := map[K][]T{}
+	for v := range seq {
+		k := key(v)
+		groups[k] = append(groups[k], v)
+	}
+	return groups
+}
+
+// ZipSeq pairs two sequences up as a Seq2, stopping when either runs out
+// Ranging over two sequences in step needs iter.Pull, which turns a push iterator into a next() function
+func ZipSeq[A, B any](as iter.Seq[A], bs iter.Seq[B]) iter.Seq2[A, B] {
+	return func(yield func(A, B) bool) {
+		nextB, stop := iter.Pull(bs)
+		defer stop()
+		for a := range as {
+			b, ok := nextB()
+			if !ok || !yield(a, b) {
+				return
+			}
+		}
+	}
+}
+
+func FlattenSeq[T any](nested iter.Seq[iter.Seq[T]]) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for inner := range nested {
+			for v := range inner {
+				if !yield(v) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func MinBySeq[T any, K cmp.Ordered](seq iter.Seq[T], key func(T) K) (T, bool) {
+	return bestBySeq(seq, key, func(a, b K) bool { return a < b })
+}
+
+func MaxBySeq[T any, K cmp.Ordered](seq iter.Seq[T], key func(T) K) (T, bool) {
+	return bestBySeq(seq, key, func(a, b K) bool { return a > b })
+}
+
+func bestBySeq[T any, K cmp.Ordered](seq iter.Seq[T], key func(T) K, better func(a, b K) bool) (T, bool) {
+	var best T
+	var bestKey K
+	found := false
+	for v := range seq {
+		if k := key(v); !found || better(k, bestKey) {
+			best, bestKey, found = v, k, true
+		}
+	}
+	return best, found
+}
+
+// Take stops a sequence after n values, handy for endless ones
+func Take[T any](seq iter.Seq[T], n int) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		if n <= 0 {
+			return
+		}
+		i := 0
+		for v := range seq {
+			if !yield(v) {
+				return
+			}
+			if i++; i >= n {
+				return
+			}
+		}
+	}
+}
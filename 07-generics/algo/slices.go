@@ -0,0 +1,105 @@
+package algo
+
+import (
+	"cmp"
+	"slices"
+)
+
+// The slice versions do their work straight away and hand back a new slice. The input is never changed.
+// For long or endless inputs, or chains where you only want the first few results, see the Seq versions
+
+func Map[T, U any](items []T, f func(T) U) []U {
+	out := make([]U, len(items))
+	for i, v := range items {
+		out[i] = f(v)
+	}
+	return out
+}
+
+func Filter[T any](items []T, keep func(T) bool) []T {
+	var out []T
+	for _, v := range items {
+		if keep(v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// Reduce folds items into one value, starting from initial. Sum is Reduce(nums, 0, func(a, b int) int { return a + b })
+func Reduce[T, A any](items []T, initial A, f func(A, T) A) A {
+	acc := initial
+	for _, v := range items {
+		acc = f(acc, v)
+	}
+	return acc
+}
+
+// GroupBy buckets items by key. Each bucket keeps the items in their original order
+func GroupBy[T any, K comparable](items []T, key func(T) K) map[K][]T {
+	groups := map[K][]T{}
+	for _, v := range items {
+		k := key(v)
+		groups[k] = append(groups[k], v)
+	}
+	return groups
+}
+
+type Pair[A, B any] struct {
+	First  A
+	Second B
+}
+
+// Zip pairs items up by index. It stops at the end of the shorter slice
+func Zip[A, B any](as []A, bs []B) []Pair[A, B] {
+	out := make([]Pair[A, B], min(len(as), len(bs)))
+	for i := range out {
+		out[i] = Pair[A, B]{as[i], bs[i]}
+	}
+	return out
+}
+
+func Flatten[T any](nested [][]T) []T {
+	total := 0
+	for _, inner := range nested {
+		total += len(inner)
+	}
+	out := make([]T, 0, total)
+	for _, inner := range nested {
+		out = append(out, inner...)
+	}
+	return out
+}
+
+// SortBy returns a sorted copy, ordered by key. It's stable, items with equal keys keep their order
+func SortBy[T any, K cmp.Ordered](items []T, key func(T) K) []T {
+	out := slices.Clone(items)
+	slices.SortStableFunc(out, func(a, b T) int {
+		return cmp.Compare(key(a), key(b))
+	})
+	return out
+}
+
+// MinBy returns the item with the smallest key, the first one if there's a tie
+// False means there were no items to choose from
+func MinBy[T any, K cmp.Ordered](items []T, key func(T) K) (T, bool) {
+	return bestBy(items, key, func(a, b K) bool { return a < b })
+}
+
+func MaxBy[T any, K cmp.Ordered](items []T, key func(T) K) (T, bool) {
+	return bestBy(items, key, func(a, b K) bool { return a > b })
+}
+
+func bestBy[T any, K cmp.Ordered](items []T, key func(T) K, better func(a, b K) bool) (T, bool) {
+	if len(items) == 0 {
+		var zero T
+		return zero, false
+	}
+	best, bestKey := items[0], key(items[0])
+	for _, v := range items[1:] {
+		if k := key(v); better(k, bestKey) {
+			best, bestKey = v, k
+		}
+	}
+	return best, true
+}
@@ -0,0 +1,19 @@
+package collections
+
+import "iter"
+
+// Collection is what every container in this package has in common: a size, and a way to walk it
+// with range. Anything written against Collection works on all of them, and on main's Stack too
+type Collection[T any] interface {
+	Len() int
+	All() iter.Seq[T]
+}
+
+// Collect copies a collection out into a slice, in the collection's iteration order
+func Collect[T any](c Collection[T]) []T {
+	out := make([]T, 0, c.Len())
+	for v := range c.All() {
+		out = append(out, v)
+	}
+	return out
+}
@@ -0,0 +1,37 @@
+package collections
+
+import (
+	"slices"
+	"testing"
+)
+
+// Every container here has to satisfy Collection, the LRU cache through its adapter
+var (
+	_ Collection[int] = (*Deque[int])(nil)
+	_ Collection[int] = (*Queue[int])(nil)
+	_ Collection[int] = (*Set[int])(nil)
+	_ Collection[int] = (*PriorityQueue[int])(nil)
+	_ Collection[int] = NewLRUCache[string, int](1).AsCollection()
+)
+
+func TestCollectLRUValues(t *testing.T) {
+	cache := NewLRUCache[string, int](2)
+	cache.Put("a", 1)
+	cache.Put("b", 2)
+	cache.Put("c", 3) // pushes a out
+	cache.Get("b")
+
+	if got, want := Collect(cache.AsCollection()), []int{2, 3}; !slices.Equal(got, want) {
+		t.Errorf("Collect(cache.AsCollection()) = %v, want %v, most recent first", got, want)
+	}
+}
+
+func BenchmarkCollect(b *testing.B) {
+	var d Deque[int]
+	for i := range 1000 {
+		d.PushBack(i)
+	}
+	for b.Loop() {
+		Collect(&d)
+	}
+}
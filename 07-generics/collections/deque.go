@@ -0,0 +1,121 @@
+package collections
+
+import "iter"
+
+// Deque is a double ended queue on a ring buffer. Both ends are O(1) and popping never shifts the
+// other elements along, we just move head around the ring
+type Deque[T any] struct {
+	buf  []T
+	head int // index of the front element
+	size int
+}
+
+func (d *Deque[T]) Len() int {
+	return d.size
+}
+
+// index maps a position from the front onto the ring
+func (d *Deque[T]) index(i int) int {
+	return (d.head + i) % len(d.buf)
+}
+
+func (d *Deque[T]) grow() {
+	if d.size < len(d.buf) {
+		return
+	}
+	buf := make([]T, max(2*len(d.buf), 4))
+	// unroll the ring so the front lands at 0 again
+	for i := range d.size {
+		buf[i] = d.buf[d.index(i)]
+	}
+	d.buf, d.head = buf, 0
+}
+
+func (d *Deque[T]) PushBack(v T) {
+	d.grow()
+	d.buf[d.index(d.size)] = v
+	d.size++
+}
+
+func (d *Deque[T]) PushFront(v T) {
+	d.grow()
+	d.head = (d.head - 1 + len(d.buf)) % len(d.buf)
+	d.buf[d.head] = v
+	d.size++
+}
+
+func (d *Deque[T]) PopFront() (T, bool) {
+	var zero T
+	if d.size == 0 {
+		return zero, false
+	}
+	v := d.buf[d.head]
+	d.buf[d.head] = zero
+	d.head = d.index(1)
+	d.size--
+	return v, true
+}
+
+func (d *Deque[T]) PopBack() (T, bool) {
+	var zero T
+	if d.size == 0 {
+		return zero, false
+	}
+	i := d.index(d.size - 1)
+	v := d.buf[i]
+	d.buf[i] = zero
+	d.size--
+	return v, true
+}
+
+func (d *Deque[T]) Front() (T, bool) {
+	if d.size == 0 {
+		var zero T
+		return zero, false
+	}
+	return d.buf[d.head], true
+}
+
+func (d *Deque[T]) Back() (T, bool) {
+	if d.size == 0 {
+		var zero T
+		return zero, false
+	}
+	return d.buf[d.index(d.size-1)], true
+}
+
+// All walks front to back
+func (d *Deque[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for i := range d.size {
+			if !yield(d.buf[d.index(i)]) {
+				return
+			}
+		}
+	}
+}
+
+// Queue is first in first out. It's a Deque with the methods you shouldn't be calling taken away
+type Queue[T any] struct {
+	d Deque[T]
+}
+
+func (q *Queue[T]) Enqueue(v T) {
+	q.d.PushBack(v)
+}
+
+func (q *Queue[T]) Dequeue() (T, bool) {
+	return q.d.PopFront()
+}
+
+func (q *Queue[T]) Peek() (T, bool) {
+	return q.d.Front()
+}
+
+func (q *Queue[T]) Len() int {
+	return q.d.Len()
+}
+
+func (q *Queue[T]) All() iter.Seq[T] {
+	return q.d.All()
+}
@@ -0,0 +1,113 @@
+package collections
+
+import (
+	"slices"
+	"testing"
+)
+
+// The ring starts with room for 4, so a dozen mixed pushes and pops from both ends wrap head around
+// the ring and grow it twice. A plain slice doing the same thing the slow way says what should be in it
+func TestDequeAgainstSlice(t *testing.T) {
+	var d Deque[int]
+	var want []int
+	check := func(step string) {
+		t.Helper()
+		if got := slices.Collect(d.All()); !slices.Equal(got, want) || d.Len() != len(want) {
+			t.Fatalf("after %s deque holds %v (Len %d), want %v", step, got, d.Len(), want)
+		}
+		front, okFront := d.Front()
+		back, okBack := d.Back()
+		if len(want) == 0 {
+			if okFront || okBack {
+				t.Fatalf("after %s an empty deque has a front or back", step)
+			}
+			return
+		}
+		if front != want[0] || back != want[len(want)-1] {
+			t.Fatalf("after %s Front, Back = %d, %d, want %d, %d", step, front, back, want[0], want[len(want)-1])
+		}
+	}
+
+	for i := range 12 {
+		switch i % 3 {
+		case 0, 1:
+			d.PushFront(i)
+			want = slices.Insert(want, 0, i)
+			check("PushFront")
+		case 2:
+			d.PushBack(i)
+			want = append(want, i)
+			check("PushBack")
+		}
+		if i%4 == 3 {
+			got, _ := d.PopBack()
+			if got != want[len(want)-1] {
+				t.Fatalf("PopBack = %d, want %d", got, want[len(want)-1])
+			}
+			want = want[:len(want)-1]
+			check("PopBack")
+		}
+	}
+	for len(want) > 0 {
+		got, _ := d.PopFront()
+		if got != want[0] {
+			t.Fatalf("PopFront = %d, want %d", got, want[0])
+		}
+		want = want[1:]
+		check("PopFront")
+	}
+	if _, ok := d.PopBack(); ok {
+		t.Error("PopBack on an empty deque reported a value")
+	}
+}
+
+func TestQueueIsFIFO(t *testing.T) {
+	var q Queue[string]
+	for _, s := range []string{"a", "b", "c"} {
+		q.Enqueue(s)
+	}
+	for _, want := range []string{"a", "b", "c"} {
+		if got, ok := q.Dequeue(); !ok || got != want {
+			t.Fatalf("Dequeue = %q %v, want %q", got, ok, want)
+		}
+	}
+}
+
+// BenchmarkQueueSlice is a plain slice used as a queue, for comparison. Popping reslices the front away,
+// so the space it leaves is only reclaimed when append copies everything into a new array
+
+func BenchmarkQueue(b *testing.B) {
+	var q Queue[int]
+	for b.Loop() {
+		for i := range 1000 {
+			q.Enqueue(i)
+		}
+		for range 1000 {
+			q.Dequeue()
+		}
+	}
+}
+
+func BenchmarkQueueSlice(b *testing.B) {
+	var q []int
+	for b.Loop() {
+		for i := range 1000 {
+			q = append(q, i)
+		}
+		for range 1000 {
+			q = q[1:]
+		}
+	}
+}
+
+func BenchmarkDequePushFront(b *testing.B) {
+	var d Deque[int]
+	for b.Loop() {
+		for i := range 1000 {
+			d.PushFront(i)
+		}
+		for range 1000 {
+			d.PopBack()
+		}
+	}
+}
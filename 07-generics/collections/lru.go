@@ -0,0 +1,127 @@
+package collections
+
+import "iter"
+
+// LRUCache holds up to capacity entries and throws out the least recently used one to make room.
+// The map finds an entry in O(1), the linked list keeps them in order of use so the oldest is always
+// at the back. Touching an entry moves it to the front
+type LRUCache[K comparable, V any] struct {
+	capacity int
+	entries  map[K]*lruNode[K, V]
+	// root is a sentinel, root.next is the most recent entry and root.prev the least recent
+	// With it in place an empty list needs no special cases
+	root lruNode[K, V]
+}
+
+type lruNode[K comparable, V any] struct {
+	key        K
+	value      V
+	prev, next *lruNode[K, V]
+}
+
+func NewLRUCache[K comparable, V any](capacity int) *LRUCache[K, V] {
+	c := &LRUCache[K, V]{capacity: max(capacity, 1), entries: make(map[K]*lruNode[K, V], capacity)}
+	c.root.next, c.root.prev = &c.root, &c.root
+	return c
+}
+
+func (c *LRUCache[K, V]) unlink(n *lruNode[K, V]) {
+	n.prev.next, n.next.prev = n.next, n.prev
+}
+
+func (c *LRUCache[K, V]) pushFront(n *lruNode[K, V]) {
+	n.prev, n.next = &c.root, c.root.next
+	c.root.next.prev = n
+	c.root.next = n
+}
+
+func (c *LRUCache[K, V]) Get(key K) (V, bool) {
+	n, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	c.unlink(n)
+	c.pushFront(n)
+	return n.value, true
+}
+
+// Put adds or replaces an entry. If that pushed another entry out, it's returned with evicted set
+func (c *LRUCache[K, V]) Put(key K, value V) (evictedKey K, evictedValue V, evicted bool) {
+	if n, ok := c.entries[key]; ok {
+		n.value = value
+		c.unlink(n)
+		c.pushFront(n)
+		return evictedKey, evictedValue, false
+	}
+	if len(c.entries) >= c.capacity {
+		oldest := c.root.prev
+		c.unlink(oldest)
+		delete(c.entries, oldest.key)
+		evictedKey, evictedValue, evicted = oldest.key, oldest.value, true
+	}
+	n := &lruNode[K, V]{key: key, value: value}
+	c.entries[key] = n
+	c.pushFront(n)
+	return evictedKey, evictedValue, evicted
+}
+
+func (c *LRUCache[K, V]) Remove(key K) bool {
+	n, ok := c.entries[key]
+	if !ok {
+		return false
+	}
+	c.unlink(n)
+	delete(c.entries, key)
+	return true
+}
+
+func (c *LRUCache[K, V]) Len() int {
+	return len(c.entries)
+}
+
+// All walks the entries from most to least recently used without touching them
+func (c *LRUCache[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for n := c.root.next; n != &c.root; n = n.next {
+			if !yield(n.key, n.value) {
+				return
+			}
+		}
+	}
+}
+
+// Keys walks just the keys, most recent first
+func (c *LRUCache[K, V]) Keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for k := range c.All() {
+			if !yield(k) {
+				return
+			}
+		}
+	}
+}
+
+// Values walks just the values, most recent first
+func (c *LRUCache[K, V]) Values() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for _, v := range c.All() {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// All has to yield keys and values to be any use for a cache, like maps.All does, which means LRUCache
+// isn't a Collection itself. AsCollection is the cache seen as a Collection of its values
+func (c *LRUCache[K, V]) AsCollection() Collection[V] {
+	return lruValues[K, V]{c}
+}
+
+type lruValues[K comparable, V any] struct {
+	c *LRUCache[K, V]
+}
+
+func (v lruValues[K, V]) Len() int         { return v.c.Len() }
+func (v lruValues[K, V]) All() iter.Seq[V] { return v.c.Values() }
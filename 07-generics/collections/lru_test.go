@@ -0,0 +1,95 @@
+package collections
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func benchKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	return keys
+}
+
+// Get counts as a use, so it saves an entry from being the next to go
+func TestLRUEvictionOrder(t *testing.T) {
+	cache := NewLRUCache[string, int](3)
+	for i, k := range []string{"a", "b", "c"} {
+		if _, _, evicted := cache.Put(k, i); evicted {
+			t.Fatalf("Put(%q) evicted with room to spare", k)
+		}
+	}
+	cache.Get("a") // b is now the least recent
+
+	for _, tc := range []struct {
+		put       string
+		wantEvict string
+		wantKeys  []string
+	}{
+		{"d", "b", []string{"d", "a", "c"}},
+		{"e", "c", []string{"e", "d", "a"}},
+		{"a", "", []string{"a", "e", "d"}}, // replacing doesn't evict, it just moves a to the front
+		{"f", "d", []string{"f", "a", "e"}},
+	} {
+		key, _, evicted := cache.Put(tc.put, 0)
+		if evicted != (tc.wantEvict != "") || key != tc.wantEvict {
+			t.Errorf("Put(%q) evicted %q %v, want %q", tc.put, key, evicted, tc.wantEvict)
+		}
+		if got := slices.Collect(cache.Keys()); !slices.Equal(got, tc.wantKeys) {
+			t.Errorf("after Put(%q) keys are %v, want %v", tc.put, got, tc.wantKeys)
+		}
+	}
+
+	if _, ok := cache.Get("b"); ok {
+		t.Error("Get found an evicted key")
+	}
+	if !cache.Remove("e") || cache.Remove("e") || cache.Len() != 2 {
+		t.Error("Remove should take e out once")
+	}
+	// with e removed there's room again
+	if _, _, evicted := cache.Put("g", 0); evicted {
+		t.Error("Put after a Remove evicted")
+	}
+}
+
+// Every key fits, so every Get is a hit and moves an entry to the front
+func BenchmarkLRUGet(b *testing.B) {
+	keys := benchKeys(1000)
+	cache := NewLRUCache[string, int](len(keys))
+	for i, k := range keys {
+		cache.Put(k, i)
+	}
+	for b.Loop() {
+		for _, k := range keys {
+			cache.Get(k)
+		}
+	}
+}
+
+// Twice as many keys as room, so every Put past the first thousand evicts
+func BenchmarkLRUPutEvict(b *testing.B) {
+	keys := benchKeys(2000)
+	cache := NewLRUCache[string, int](len(keys) / 2)
+	for b.Loop() {
+		for i, k := range keys {
+			cache.Put(k, i)
+		}
+	}
+}
+
+// A plain map with no eviction, what the bookkeeping costs on top of it
+func BenchmarkLRUGetMap(b *testing.B) {
+	keys := benchKeys(1000)
+	m := make(map[string]int, len(keys))
+	for i, k := range keys {
+		m[k] = i
+	}
+	for b.Loop() {
+		for _, k := range keys {
+			_ = m[k]
+		}
+	}
+}
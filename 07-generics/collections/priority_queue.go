@@ -0,0 +1,89 @@
+package collections
+
+import "iter"
+
+// PriorityQueue is a binary heap. less decides what comes out first: with a < b you get a min-heap,
+// with a > b a max-heap, and with anything else whatever order you need
+//
+// The heap lives in a slice. The children of i sit at 2i+1 and 2i+2, and every parent comes out
+// before its children. That's all the order there is, which is why Pop is O(log n) and not O(n)
+type PriorityQueue[T any] struct {
+	items []T
+	less  func(a, b T) bool
+}
+
+func NewPriorityQueue[T any](less func(a, b T) bool) *PriorityQueue[T] {
+	return &PriorityQueue[T]{less: less}
+}
+
+func (pq *PriorityQueue[T]) Len() int {
+	return len(pq.items)
+}
+
+func (pq *PriorityQueue[T]) Push(v T) {
+	pq.items = append(pq.items, v)
+	pq.up(len(pq.items) - 1)
+}
+
+func (pq *PriorityQueue[T]) Pop() (T, bool) {
+	var zero T
+	if len(pq.items) == 0 {
+		return zero, false
+	}
+	top := pq.items[0]
+	last := len(pq.items) - 1
+	pq.items[0] = pq.items[last]
+	pq.items[last] = zero
+	pq.items = pq.items[:last]
+	pq.down(0)
+	return top, true
+}
+
+func (pq *PriorityQueue[T]) Peek() (T, bool) {
+	if len(pq.items) == 0 {
+		var zero T
+		return zero, false
+	}
+	return pq.items[0], true
+}
+
+// All walks the heap in its slice order. The first value is the next to pop, the rest are not sorted
+func (pq *PriorityQueue[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for _, v := range pq.items {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// up swaps a new value with its parent until the parent comes first
+func (pq *PriorityQueue[T]) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !pq.less(pq.items[i], pq.items[parent]) {
+			return
+		}
+		pq.items[i], pq.items[parent] = pq.items[parent], pq.items[i]
+		i = parent
+	}
+}
+
+// down swaps a value with its first child until it comes before both of them
+func (pq *PriorityQueue[T]) down(i int) {
+	for {
+		first, left, right := i, 2*i+1, 2*i+2
+		if left < len(pq.items) && pq.less(pq.items[left], pq.items[first]) {
+			first = left
+		}
+		if right < len(pq.items) && pq.less(pq.items[right], pq.items[first]) {
+			first = right
+		}
+		if first == i {
+			return
+		}
+		pq.items[i], pq.items[first] = pq.items[first], pq.items[i]
+		i = first
+	}
+}
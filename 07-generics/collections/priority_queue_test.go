@@ -0,0 +1,87 @@
+package collections
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+type job struct {
+	priority int
+	name     string
+}
+
+// A heap doesn't keep ties in the order they came in, so ties only have to come out next to each other
+// with nothing lost or duplicated
+func TestPriorityQueueOrder(t *testing.T) {
+	pq := NewPriorityQueue(func(a, b job) bool { return a.priority < b.priority })
+	jobs := []job{{3, "c"}, {1, "a1"}, {2, "b1"}, {1, "a2"}, {5, "e"}, {2, "b2"}, {1, "a3"}}
+	for _, j := range jobs {
+		pq.Push(j)
+	}
+	if top, _ := pq.Peek(); top.priority != 1 || pq.Len() != len(jobs) {
+		t.Fatalf("Peek = %v with %d queued, want priority 1 and nothing taken", top, pq.Len())
+	}
+
+	var priorities []int
+	var names []string
+	for pq.Len() > 0 {
+		j, _ := pq.Pop()
+		priorities = append(priorities, j.priority)
+		names = append(names, j.name)
+	}
+	if want := []int{1, 1, 1, 2, 2, 3, 5}; !slices.Equal(priorities, want) {
+		t.Errorf("popped priorities %v, want %v", priorities, want)
+	}
+	slices.Sort(names)
+	if want := []string{"a1", "a2", "a3", "b1", "b2", "c", "e"}; !slices.Equal(names, want) {
+		t.Errorf("popped %v, want every job once", names)
+	}
+	if _, ok := pq.Pop(); ok {
+		t.Error("Pop on an empty queue reported a value")
+	}
+}
+
+func TestPriorityQueueMatchesSort(t *testing.T) {
+	values := rand.Perm(200)
+	values = append(values, values[:50]...) // plenty of ties
+	pq := NewPriorityQueue(func(a, b int) bool { return a > b })
+	for _, v := range values {
+		pq.Push(v)
+	}
+	var got []int
+	for pq.Len() > 0 {
+		v, _ := pq.Pop()
+		got = append(got, v)
+	}
+	want := slices.Clone(values)
+	slices.Sort(want)
+	slices.Reverse(want)
+	if !slices.Equal(got, want) {
+		t.Errorf("max-heap popped %v, want %v", got, want)
+	}
+}
+
+// Sorting everything up front is the other way to get items out smallest first. It only works when
+// they're all known before the first one is needed, the heap takes them as they come
+
+func BenchmarkPriorityQueue(b *testing.B) {
+	values := rand.Perm(1000)
+	for b.Loop() {
+		pq := NewPriorityQueue(func(a, b int) bool { return a < b })
+		for _, v := range values {
+			pq.Push(v)
+		}
+		for pq.Len() > 0 {
+			pq.Pop()
+		}
+	}
+}
+
+func BenchmarkPriorityQueueSort(b *testing.B) {
+	values := rand.Perm(1000)
+	for b.Loop() {
+		sorted := slices.Clone(values)
+		slices.Sort(sorted)
+	}
+}
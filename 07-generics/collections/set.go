@@ -0,0 +1,96 @@
+package collections
+
+import "iter"
+
+// Set is a hash set. An empty struct takes up no memory, so the map is really just its keys
+type Set[T comparable] struct {
+	items map[T]struct{}
+}
+
+func NewSet[T comparable](items ...T) *Set[T] {
+	s := &Set[T]{items: make(map[T]struct{}, len(items))}
+	for _, v := range items {
+		s.Add(v)
+	}
+	return s
+}
+
+func (s *Set[T]) Add(v T) {
+	// the zero value Set has a nil map, writing to it would panic
+	if s.items == nil {
+		s.items = map[T]struct{}{}
+	}
+	s.items[v] = struct{}{}
+}
+
+func (s *Set[T]) Remove(v T) {
+	delete(s.items, v)
+}
+
+func (s *Set[T]) Contains(v T) bool {
+	_, ok := s.items[v]
+	return ok
+}
+
+func (s *Set[T]) Len() int {
+	return len(s.items)
+}
+
+// All walks the set in no particular order, maps don't keep one
+func (s *Set[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for v := range s.items {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Union, Intersection and Difference all return a new set and leave both inputs alone
+
+func (s *Set[T]) Union(other *Set[T]) *Set[T] {
+	out := NewSet[T]()
+	for v := range s.All() {
+		out.Add(v)
+	}
+	for v := range other.All() {
+		out.Add(v)
+	}
+	return out
+}
+
+func (s *Set[T]) Intersection(other *Set[T]) *Set[T] {
+	// loop over the smaller one, each lookup in the other is O(1) anyway
+	small, big := s, other
+	if small.Len() > big.Len() {
+		small, big = big, small
+	}
+	out := NewSet[T]()
+	for v := range small.All() {
+		if big.Contains(v) {
+			out.Add(v)
+		}
+	}
+	return out
+}
+
+// Difference is everything in s that isn't in other
+func (s *Set[T]) Difference(other *Set[T]) *Set[T] {
+	out := NewSet[T]()
+	for v := range s.All() {
+		if !other.Contains(v) {
+			out.Add(v)
+		}
+	}
+	return out
+}
+
+func (s *Set[T]) IsSubset(other *Set[T]) bool {
+	for v := range s.All() {
+		if !other.Contains(v) {
+			return false
+		}
+	}
+	return true
+}
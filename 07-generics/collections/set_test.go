@@ -0,0 +1,88 @@
+package collections
+
+import (
+	"slices"
+	"testing"
+)
+
+func benchSets(n int) (*Set[int], *Set[int]) {
+	a, b := NewSet[int](), NewSet[int]()
+	for i := range n {
+		a.Add(i)
+		b.Add(i + n/2)
+	}
+	return a, b
+}
+
+func sorted(s *Set[int]) []int {
+	return slices.Sorted(s.All())
+}
+
+func TestSetOperations(t *testing.T) {
+	a := NewSet(1, 2, 3, 4)
+	b := NewSet(3, 4, 5)
+	empty := NewSet[int]()
+
+	for _, tc := range []struct {
+		name string
+		got  *Set[int]
+		want []int
+	}{
+		{"a ∪ b", a.Union(b), []int{1, 2, 3, 4, 5}},
+		{"a ∩ b", a.Intersection(b), []int{3, 4}},
+		{"b ∩ a", b.Intersection(a), []int{3, 4}},
+		{"a - b", a.Difference(b), []int{1, 2}},
+		{"b - a", b.Difference(a), []int{5}},
+		{"a ∪ ∅", a.Union(empty), []int{1, 2, 3, 4}},
+		{"a ∩ ∅", a.Intersection(empty), []int{}},
+		{"∅ - a", empty.Difference(a), []int{}},
+	} {
+		if got := sorted(tc.got); !slices.Equal(got, tc.want) {
+			t.Errorf("%s = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+	// none of them touch their inputs
+	if !slices.Equal(sorted(a), []int{1, 2, 3, 4}) || !slices.Equal(sorted(b), []int{3, 4, 5}) {
+		t.Errorf("inputs changed to %v and %v", sorted(a), sorted(b))
+	}
+	if !a.Intersection(b).IsSubset(a) || a.IsSubset(b) {
+		t.Error("IsSubset got a ∩ b ⊆ a or a ⊆ b wrong")
+	}
+
+	// the zero value Set works without NewSet
+	var zero Set[int]
+	zero.Add(7)
+	if !zero.Contains(7) || zero.Len() != 1 {
+		t.Error("Add on a zero value Set didn't stick")
+	}
+}
+
+func BenchmarkSetContains(b *testing.B) {
+	s, _ := benchSets(1000)
+	for b.Loop() {
+		for i := range 2000 {
+			s.Contains(i)
+		}
+	}
+}
+
+func BenchmarkSetUnion(b *testing.B) {
+	x, y := benchSets(1000)
+	for b.Loop() {
+		x.Union(y)
+	}
+}
+
+func BenchmarkSetIntersection(b *testing.B) {
+	x, y := benchSets(1000)
+	for b.Loop() {
+		x.Intersection(y)
+	}
+}
+
+func BenchmarkSetDifference(b *testing.B) {
+	x, y := benchSets(1000)
+	for b.Loop() {
+		x.Difference(y)
+	}
+}
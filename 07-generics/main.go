@@ -1,36 +1,94 @@
 package main
 
-import "fmt"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"learninggo/generics/algo"
+	"learninggo/generics/collections"
+	"learninggo/generics/persistent"
+	"maps"
+	"slices"
+	"sync"
+)
 
+var (
+	ErrStackOverflow   = errors.New("stack overflow")
+	ErrInvalidCapacity = errors.New("capacity must be at least 1")
+)
 
-type Stack[T comparable] struct { 
+// Push and Pop only ever move values around, they never compare them, so any type will do
+// Asking for comparable here would rule out Stack[[]byte] for no reason
+type Stack[T any] struct {
 	items []T
+	limit int // 0 means no limit, which keeps the zero value Stack ready to use
 }
 
-func (s *Stack[T]) Push(item T) {
+// NewBoundedStack makes a stack that refuses to grow past capacity items. A stack that can't hold anything
+// is a bug, not a bound, so capacity has to be at least 1. For no limit at all use the zero value Stack
+func NewBoundedStack[T any](capacity int) (*Stack[T], error) {
+	if capacity <= 0 {
+		return nil, fmt.Errorf("%w, got %d", ErrInvalidCapacity, capacity)
+	}
+	return &Stack[T]{items: make([]T, 0, capacity), limit: capacity}, nil
+}
+
+func (s *Stack[T]) Push(item T) error {
+	if s.limit > 0 && len(s.items) >= s.limit {
+		return ErrStackOverflow
+	}
 	s.items = append(s.items, item)
+	return nil
+}
+
+// Pop returns false on an empty stack. The zero value alone can't tell us, 0 is a perfectly good int to push
+func (s *Stack[T]) Pop() (T, bool) {
+	var zero T // we cannot just return nil because it doesn't match nil
+	// In go fashion, we create a instance of T using var and automatically get the zero value
+	if len(s.items) == 0 {
+		return zero, false
+	}
+	last := len(s.items) - 1
+	v := s.items[last]
+	// The backing array still holds the popped value. Clearing it lets the GC have it if T holds pointers
+	s.items[last] = zero
+	s.items = s.items[:last]
+	return v, true
 }
 
-func (s *Stack[T]) Pop() T {
+func (s *Stack[T]) Peek() (T, bool) {
 	if len(s.items) == 0 {
-		var zero T // we cannot just return nil because it doesn't match nil
-		// In go fashion, we create a instance of T using var and automatically get the zero value
-		return zero
+		var zero T
+		return zero, false
 	}
-	v := s.items[len(s.items) - 1]
-	s.items = s.items[:len(s.items)]
-	return v
+	return s.items[len(s.items)-1], true
 }
 
-func (s *Stack[T]) Empty () bool {
+func (s *Stack[T]) Len() int {
+	return len(s.items)
+}
+
+func (s *Stack[T]) Empty() bool {
 	return len(s.items) == 0
 }
 
-func (s *Stack[T]) Contain(val T) bool {
-	if s.Empty() {
-		return false
+// All walks the stack from the top down without popping anything
+// for v := range stack.All() { ... }
+func (s *Stack[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for i := len(s.items) - 1; i >= 0; i-- {
+			if !yield(s.items[i]) {
+				return
+			}
+		}
 	}
-	for _, v := range s.items {
+}
+
+// Contains needs ==, which only comparable types have. Methods can't add constraints of their own,
+// so it's a function instead and Stack itself stays open to any T
+func Contains[T comparable](s *Stack[T], val T) bool {
+	for v := range s.All() {
 		if v == val {
 			return true
 		}
@@ -38,12 +96,262 @@ func (s *Stack[T]) Contain(val T) bool {
 	return false
 }
 
+// describe works on anything with Len and All. Stack never heard of collections.Collection,
+// it just happens to have the right methods, and that's all an interface asks for
+func describe[T any](name string, c collections.Collection[T]) {
+	fmt.Println(name, "holds", c.Len(), "items:", collections.Collect(c))
+}
 
-func main () {
-	stack := Stack[int] {}
+func main() {
+	stack := Stack[int]{}
 	stack.Push(20)
 	stack.Push(30)
 	stack.Push(43)
-	fmt.Println("What does stack have to offer?", stack.Pop())
-	fmt.Println("Do we have 30?", stack.Contain(30))
-}
\ No newline at end of file
+	top, _ := stack.Pop()
+	fmt.Println("What does stack have to offer?", top)
+	fmt.Println("Do we have 30?", Contains(&stack, 30))
+	fmt.Println("Do we still have 43?", Contains(&stack, 43))
+	describe("The stack", &stack)
+
+	for v := range stack.All() {
+		fmt.Println("Still on the stack", v)
+	}
+	stack.Pop()
+	stack.Pop()
+	if _, ok := stack.Pop(); !ok {
+		fmt.Println("Nothing left to pop")
+	}
+
+	// Slices aren't comparable, but they stack just fine
+	buffers, err := NewBoundedStack[[]byte](2)
+	if err != nil {
+		fmt.Println("Couldn't make a stack:", err)
+		return
+	}
+	buffers.Push([]byte("first"))
+	buffers.Push([]byte("second"))
+	if err := buffers.Push([]byte("third")); err != nil {
+		fmt.Println("Couldn't push:", err)
+	}
+	if b, ok := buffers.Peek(); ok {
+		fmt.Println("On top", string(b), "with", buffers.Len(), "buffers")
+	}
+
+	var jobs collections.Queue[string]
+	jobs.Enqueue("build")
+	jobs.Enqueue("test")
+	jobs.Enqueue("deploy")
+	next, _ := jobs.Dequeue()
+	fmt.Println("First job", next)
+	describe("The job queue", &jobs)
+
+	var window collections.Deque[int]
+	for i := range 6 {
+		window.PushBack(i)
+		if window.Len() > 3 {
+			window.PopFront()
+		}
+	}
+	window.PushFront(-1)
+	describe("The sliding window", &window)
+
+	gophers := collections.NewSet("Leo", "Mary", "Kate")
+	rustaceans := collections.NewSet("Kate", "Kunta")
+	describe("Both", gophers.Intersection(rustaceans))
+	fmt.Println("Everyone", gophers.Union(rustaceans).Len())
+	describe("Only Go", gophers.Difference(rustaceans))
+
+	type task struct {
+		name     string
+		priority int
+	}
+	tasks := collections.NewPriorityQueue(func(a, b task) bool { return a.priority > b.priority })
+	tasks.Push(task{"write docs", 1})
+	tasks.Push(task{"fix prod", 10})
+	tasks.Push(task{"review PR", 5})
+	for tasks.Len() > 0 {
+		t, _ := tasks.Pop()
+		fmt.Println("Working on", t.name)
+	}
+
+	cache := collections.NewLRUCache[string, int](2)
+	cache.Put("a", 1)
+	cache.Put("b", 2)
+	cache.Get("a")
+	if k, _, evicted := cache.Put("c", 3); evicted {
+		fmt.Println("Evicted", k)
+	}
+	for k, v := range cache.All() {
+		fmt.Println("Cached", k, v)
+	}
+	describe("The cache", cache.AsCollection())
+
+	// Shared undo stacks need one of the concurrent versions. sync_stack_test.go checks and benchmarks
+	// both under contention, run it with go test -race -bench Stack
+	const workers, pushes = 8, 1000
+	for name, s := range map[string]ConcurrentStack[int]{
+		"mutex":     &SyncStack[int]{},
+		"lock-free": &LockFreeStack[int]{},
+	} {
+		var wg sync.WaitGroup
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range pushes {
+					s.Push(i)
+				}
+			}()
+		}
+		wg.Wait()
+		fmt.Printf("The %s stack holds %d of %d pushes\n", name, s.Len(), workers*pushes)
+	}
+
+	cfg := config{"theme": "dark"}
+	history := NewHistory(10)
+	history.Execute(&setKey{cfg: cfg, Key: "theme", Value: "light"})
+	history.Begin("connect to prod")
+	history.Execute(&setKey{cfg: cfg, Key: "host", Value: "db.prod"})
+	history.Execute(&setKey{cfg: cfg, Key: "port", Value: "5432"})
+	history.Commit()
+	fmt.Println("Config", cfg, "can undo", history.UndoNames())
+
+	history.Undo()
+	fmt.Println("Undid the transaction", cfg)
+
+	saved, _ := json.Marshal(history)
+	fmt.Println("Saved session", string(saved))
+
+	// a new editor session picks up the config and history where the last one left off
+	restoredCfg := maps.Clone(cfg)
+	restored, err := RestoreHistory(saved, configDecoder(restoredCfg))
+	if err != nil {
+		fmt.Println("Couldn't restore:", err)
+		return
+	}
+	restored.Redo()
+	fmt.Println("Redid after restoring", restoredCfg)
+	restored.Undo()
+	restored.Undo()
+	fmt.Println("Undid everything", restoredCfg)
+	if err := restored.Undo(); err != nil {
+		fmt.Println(err)
+	}
+
+	type spell struct {
+		name   string
+		school string
+		damage int
+	}
+	spells := []spell{{"hate", "dark", 30}, {"ice", "frost", 23}, {"poison", "dark", 100}, {"blizzard", "frost", 60}}
+	names := algo.Map(spells, func(s spell) string { return s.name })
+	strong := algo.Filter(spells, func(s spell) bool { return s.damage >= 50 })
+	total := algo.Reduce(spells, 0, func(sum int, s spell) int { return sum + s.damage })
+	fmt.Println("Spells", names, "strong ones", len(strong), "total damage", total)
+	for school, group := range algo.GroupBy(spells, func(s spell) string { return s.school }) {
+		fmt.Println(school, "school has", len(group), "spells")
+	}
+	weakest, _ := algo.MinBy(spells, func(s spell) int { return s.damage })
+	fmt.Println("Weakest", weakest.name, "sorted", algo.Map(algo.SortBy(spells, func(s spell) int { return s.damage }), func(s spell) string { return s.name }))
+	fmt.Println("Zipped", algo.Zip(names, []int{1, 2, 3}))
+	fmt.Println("Flattened", algo.Flatten([][]int{{1, 2}, {3}, {}, {4, 5}}))
+
+	// Lazily: the even squares of every natural number, but we only ever compute the first three
+	naturals := func(yield func(int) bool) {
+		for i := 1; ; i++ {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+	squares := algo.MapSeq(naturals, func(n int) int { return n * n })
+	evens := algo.FilterSeq(squares, func(n int) bool { return n%2 == 0 })
+	fmt.Println("First even squares", slices.Collect(algo.Take(evens, 3)))
+	for name, damage := range algo.ZipSeq(slices.Values(names), algo.MapSeq(slices.Values(spells), func(s spell) int { return s.damage })) {
+		fmt.Println(name, "does", damage)
+	}
+
+	// A time-travel debugger keeps every version. With persistent structures that's one small
+	// struct per step, not a full copy of the program's state
+	var calls persistent.Stack[string]
+	var registers persistent.Vector[int]
+	var vars persistent.Map[string, int]
+	type snapshot struct {
+		calls     persistent.Stack[string]
+		registers persistent.Vector[int]
+		vars      persistent.Map[string, int]
+	}
+	var timeline []snapshot
+	for i, fn := range []string{"main", "parse", "eval"} {
+		calls = calls.Push(fn)
+		registers = registers.Append(i * 10)
+		vars = vars.Set(fn+".depth", i)
+		timeline = append(timeline, snapshot{calls, registers, vars})
+	}
+	registers, _ = registers.Set(0, 99)
+	_, calls, _ = calls.Pop()
+	vars = vars.Delete("main.depth")
+	timeline = append(timeline, snapshot{calls, registers, vars})
+
+	for step, snap := range timeline {
+		depth, ok := snap.vars.Get("main.depth")
+		fmt.Printf("Step %d: calls %v registers %v main.depth %d (%t) vars %d\n", step,
+			slices.Collect(snap.calls.All()), slices.Collect(snap.registers.All()), depth, ok, snap.vars.Len())
+	}
+
+	factorial := `
+	    push 5
+	    call fact
+	    halt
+	fact:           ; n -> n!
+	    dup
+	    push 2
+	    lt
+	    jz recurse
+	    ret         ; 0! and 1! are themselves, near enough
+	recurse:
+	    dup
+	    push 1
+	    sub
+	    call fact
+	    mul
+	    ret
+	`
+	code, labels, err := Assemble(factorial)
+	if err != nil {
+		fmt.Println("Couldn't assemble:", err)
+		return
+	}
+	listing, _ := Disassemble(code)
+	fmt.Print(listing)
+
+	vm, err := NewVM(code, 64, 1000)
+	if err != nil {
+		fmt.Println("Couldn't start:", err)
+		return
+	}
+	vm.Break(labels["recurse"]) // every time fact goes a level deeper
+	for {
+		err := vm.Run()
+		if errors.Is(err, ErrBreakpoint) {
+			fmt.Println("Paused at", vm.Current(), "stack", vm.Stack())
+			continue
+		}
+		if err != nil {
+			fmt.Println("Crashed:", err)
+		}
+		break
+	}
+	result, _ := vm.Result()
+	fmt.Println("5! is", result)
+
+	forever, _, _ := Assemble("loop:\n push 1\n call loop")
+	runaway, err := NewVM(forever, 16, 0)
+	if err != nil {
+		fmt.Println("Couldn't start:", err)
+		return
+	}
+	if err := runaway.Run(); errors.Is(err, ErrStackOverflow) {
+		fmt.Println("Caught runaway program:", err)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// Pop used to hand back the top without shrinking the stack, so the next Pop returned the same item again
+func TestPop(t *testing.T) {
+	var s Stack[*int]
+	one, two := new(int), new(int)
+	s.Push(one)
+	s.Push(two)
+
+	if got, ok := s.Pop(); !ok || got != two {
+		t.Fatalf("Pop = %v %v, want the last pushed", got, ok)
+	}
+	if s.Len() != 1 {
+		t.Fatalf("Len after a Pop = %d, want 1", s.Len())
+	}
+	// the slot past the end is still in the backing array, it shouldn't keep two alive
+	if popped := s.items[:2][1]; popped != nil {
+		t.Error("Pop left the popped pointer in the backing array")
+	}
+	if got, ok := s.Pop(); !ok || got != one {
+		t.Fatalf("second Pop = %v %v, want the first pushed", got, ok)
+	}
+	if got, ok := s.Pop(); ok || got != nil {
+		t.Errorf("Pop on an empty stack = %v %v, want nil false", got, ok)
+	}
+}
+
+// Stack only asks for any, so a slice type works everywhere except Contains, which needs ==
+func TestStackOfSlices(t *testing.T) {
+	var s Stack[[]byte]
+	if _, ok := s.Peek(); ok {
+		t.Error("Peek on an empty stack reported an item")
+	}
+	s.Push([]byte("bottom"))
+	s.Push([]byte("top"))
+
+	if top, ok := s.Peek(); !ok || !bytes.Equal(top, []byte("top")) || s.Len() != 2 {
+		t.Errorf("Peek = %q %v with %d left, want top without removing it", top, ok, s.Len())
+	}
+	var walked []string
+	for b := range s.All() {
+		walked = append(walked, string(b))
+	}
+	if want := []string{"top", "bottom"}; !slices.Equal(walked, want) {
+		t.Errorf("All walked %v, want %v", walked, want)
+	}
+
+	var names Stack[string]
+	names.Push("a")
+	names.Push("b")
+	if !Contains(&names, "a") || Contains(&names, "c") {
+		t.Error("Contains got a and c the wrong way round")
+	}
+}
+
+// The zero value has limit 0, which means no limit rather than no room
+func TestZeroValueStackIsUnbounded(t *testing.T) {
+	var s Stack[int]
+	for i := range 10_000 {
+		if err := s.Push(i); err != nil {
+			t.Fatalf("push %d: %v", i, err)
+		}
+	}
+	if s.Len() != 10_000 {
+		t.Errorf("Len = %d, want 10000", s.Len())
+	}
+}
+
+func TestNewBoundedStack(t *testing.T) {
+	for _, capacity := range []int{-1, 0} {
+		if _, err := NewBoundedStack[int](capacity); !errors.Is(err, ErrInvalidCapacity) {
+			t.Errorf("NewBoundedStack(%d) error = %v, want ErrInvalidCapacity", capacity, err)
+		}
+	}
+
+	s, err := NewBoundedStack[int](2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 2 {
+		if err := s.Push(i); err != nil {
+			t.Fatalf("push %d of 2: %v", i+1, err)
+		}
+	}
+	if err := s.Push(3); !errors.Is(err, ErrStackOverflow) {
+		t.Errorf("push past capacity error = %v, want ErrStackOverflow", err)
+	}
+}
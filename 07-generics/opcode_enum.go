@@ -0,0 +1,86 @@
+// Code generated by enumgen; DO NOT EDIT.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+var _OpcodeNames = map[Opcode]string{
+	OpHalt: "halt",
+	OpPush: "push",
+	OpPop:  "pop",
+	OpDup:  "dup",
+	OpSwap: "swap",
+	OpAdd:  "add",
+	OpSub:  "sub",
+	OpMul:  "mul",
+	OpDiv:  "div",
+	OpEq:   "eq",
+	OpLt:   "lt",
+	OpJmp:  "jmp",
+	OpJz:   "jz",
+	OpCall: "call",
+	OpRet:  "ret",
+}
+
+func (v Opcode) String() string {
+	if name, ok := _OpcodeNames[v]; ok {
+		return name
+	}
+	return fmt.Sprintf("Opcode(%d)", int(v))
+}
+
+// OpcodeValues returns every Opcode in declaration order
+func OpcodeValues() []Opcode {
+	return []Opcode{OpHalt, OpPush, OpPop, OpDup, OpSwap, OpAdd, OpSub, OpMul, OpDiv, OpEq, OpLt, OpJmp, OpJz, OpCall, OpRet}
+}
+
+func (v Opcode) IsValid() bool {
+	_, ok := _OpcodeNames[v]
+	return ok
+}
+
+// ParseOpcode matches a display name, ignoring case
+func ParseOpcode(s string) (Opcode, error) {
+	for _, v := range OpcodeValues() {
+		if strings.EqualFold(s, v.String()) {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("%q is not a valid Opcode", s)
+}
+
+func (v Opcode) MarshalText() ([]byte, error) {
+	if !v.IsValid() {
+		return nil, fmt.Errorf("cannot marshal invalid Opcode %d", int(v))
+	}
+	return []byte(v.String()), nil
+}
+
+func (v *Opcode) UnmarshalText(text []byte) error {
+	parsed, err := ParseOpcode(string(text))
+	if err != nil {
+		return err
+	}
+	*v = parsed
+	return nil
+}
+
+func (v Opcode) MarshalJSON() ([]byte, error) {
+	text, err := v.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(string(text))
+}
+
+func (v *Opcode) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("Opcode should be a string: %w", err)
+	}
+	return v.UnmarshalText([]byte(s))
+}
@@ -0,0 +1,232 @@
+package persistent
+
+import (
+	"hash/maphash"
+	"iter"
+	"math/bits"
+	"slices"
+)
+
+// Map is a hash array mapped trie. It works like Vector, except the path is read from the key's hash
+// rather than an index, and nodes don't keep 32 slots. A node keeps a 32 bit bitmap of which slots are
+// used, and a slice holding just those. The slot for bit b sits at the count of set bits below b
+//
+// Two keys whose hashes agree on every bit end up in a collision node, a plain list of the pairs
+
+// One seed for every Map, so the zero value is ready to use and every version of a map agrees on hashes
+var mapSeed = maphash.MakeSeed()
+
+// Two keys agreeing on all 64 bits of a real hash is too rare to ever see, so tests swap in a worse hash
+// here to get collisions on purpose. Nil means hash with maphash
+var testHash func(key any) uint64
+
+func hashOf[K comparable](key K) uint64 {
+	if testHash != nil {
+		return testHash(key)
+	}
+	return maphash.Comparable(mapSeed, key)
+}
+
+const (
+	hashBits = 64
+	mapBits  = 5
+	mapMask  = 1<<mapBits - 1
+)
+
+type Map[K comparable, V any] struct {
+	root *mapNode[K, V]
+	size int
+}
+
+type mapNode[K comparable, V any] struct {
+	bitmap  uint32
+	entries []mapEntry[K, V]
+	// only set on collision nodes, below the last level of the hash
+	collisions []mapEntry[K, V]
+}
+
+// An entry is either a key and value, or a child node
+type mapEntry[K comparable, V any] struct {
+	hash  uint64
+	key   K
+	value V
+	child *mapNode[K, V]
+}
+
+func (n *mapNode[K, V]) clone() *mapNode[K, V] {
+	if n == nil {
+		return &mapNode[K, V]{}
+	}
+	return &mapNode[K, V]{bitmap: n.bitmap, entries: slices.Clone(n.entries), collisions: slices.Clone(n.collisions)}
+}
+
+// slot finds where the hash sits in this node: its bit, and its index in entries
+func (n *mapNode[K, V]) slot(hash uint64, shift uint) (uint32, int) {
+	bit := uint32(1) << ((hash >> shift) & mapMask)
+	return bit, bits.OnesCount32(n.bitmap & (bit - 1))
+}
+
+func (m Map[K, V]) Len() int {
+	return m.size
+}
+
+func (m Map[K, V]) Get(key K) (V, bool) {
+	hash := hashOf(key)
+	n := m.root
+	for shift := uint(0); n != nil; shift += mapBits {
+		if shift >= hashBits {
+			for _, e := range n.collisions {
+				if e.key == key {
+					return e.value, true
+				}
+			}
+			break
+		}
+		bit, i := n.slot(hash, shift)
+		if n.bitmap&bit == 0 {
+			break
+		}
+		e := n.entries[i]
+		if e.child == nil {
+			if e.key == key {
+				return e.value, true
+			}
+			break
+		}
+		n = e.child
+	}
+	var zero V
+	return zero, false
+}
+
+// Set returns a map with key set to value. m itself is unchanged
+func (m Map[K, V]) Set(key K, value V) Map[K, V] {
+	e := mapEntry[K, V]{hash: hashOf(key), key: key, value: value}
+	root, added := setEntry(m.root, 0, e)
+	m.root = root
+	if added {
+		m.size++
+	}
+	return m
+}
+
+func setEntry[K comparable, V any](n *mapNode[K, V], shift uint, e mapEntry[K, V]) (*mapNode[K, V], bool) {
+	c := n.clone()
+	if shift >= hashBits {
+		for i, existing := range c.collisions {
+			if existing.key == e.key {
+				c.collisions[i] = e
+				return c, false
+			}
+		}
+		c.collisions = append(c.collisions, e)
+		return c, true
+	}
+
+	bit, i := c.slot(e.hash, shift)
+	if c.bitmap&bit == 0 {
+		c.bitmap |= bit
+		c.entries = slices.Insert(c.entries, i, e)
+		return c, true
+	}
+
+	existing := c.entries[i]
+	switch {
+	case existing.child != nil:
+		child, added := setEntry(existing.child, shift+mapBits, e)
+		c.entries[i] = mapEntry[K, V]{child: child}
+		return c, added
+	case existing.key == e.key:
+		c.entries[i] = e
+		return c, false
+	}
+	// two keys want the same slot, push them both down a level where their hashes can tell them apart
+	child, _ := setEntry(nil, shift+mapBits, existing)
+	child, _ = setEntry(child, shift+mapBits, e)
+	c.entries[i] = mapEntry[K, V]{child: child}
+	return c, true
+}
+
+// Delete returns a map without key. m itself is unchanged
+func (m Map[K, V]) Delete(key K) Map[K, V] {
+	root, removed := deleteEntry(m.root, 0, hashOf(key), key)
+	if removed {
+		m.root = root
+		m.size--
+	}
+	return m
+}
+
+func deleteEntry[K comparable, V any](n *mapNode[K, V], shift uint, hash uint64, key K) (*mapNode[K, V], bool) {
+	if n == nil {
+		return nil, false
+	}
+	if shift >= hashBits {
+		i := slices.IndexFunc(n.collisions, func(e mapEntry[K, V]) bool { return e.key == key })
+		if i < 0 {
+			return n, false
+		}
+		c := n.clone()
+		c.collisions = slices.Delete(c.collisions, i, i+1)
+		return c, true
+	}
+
+	bit, i := n.slot(hash, shift)
+	if n.bitmap&bit == 0 {
+		return n, false
+	}
+	existing := n.entries[i]
+	c := n.clone()
+	if existing.child == nil {
+		if existing.key != key {
+			return n, false
+		}
+		c.bitmap &^= bit
+		c.entries = slices.Delete(c.entries, i, i+1)
+		return c, true
+	}
+
+	child, removed := deleteEntry(existing.child, shift+mapBits, hash, key)
+	if !removed {
+		return n, false
+	}
+	if child.empty() {
+		c.bitmap &^= bit
+		c.entries = slices.Delete(c.entries, i, i+1)
+	} else {
+		c.entries[i] = mapEntry[K, V]{child: child}
+	}
+	return c, true
+}
+
+func (n *mapNode[K, V]) empty() bool {
+	return len(n.entries) == 0 && len(n.collisions) == 0
+}
+
+// All walks the map in hash order, which is as good as no order at all
+func (m Map[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		walkMap(m.root, yield)
+	}
+}
+
+func walkMap[K comparable, V any](n *mapNode[K, V], yield func(K, V) bool) bool {
+	if n == nil {
+		return true
+	}
+	for _, e := range n.collisions {
+		if !yield(e.key, e.value) {
+			return false
+		}
+	}
+	for _, e := range n.entries {
+		if e.child != nil {
+			if !walkMap(e.child, yield) {
+				return false
+			}
+		} else if !yield(e.key, e.value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+package persistent
+
+import (
+	"maps"
+	"math/rand/v2"
+	"testing"
+)
+
+// checkMap compares every way of reading m against a plain map
+func checkMap(t *testing.T, m Map[int, string], want map[int]string) {
+	t.Helper()
+	if m.Len() != len(want) {
+		t.Fatalf("Len = %d, want %d", m.Len(), len(want))
+	}
+	for k, w := range want {
+		if got, ok := m.Get(k); !ok || got != w {
+			t.Fatalf("Get(%d) = %q %v, want %q", k, got, ok, w)
+		}
+	}
+	if got := maps.Collect(m.All()); !maps.Equal(got, want) {
+		t.Fatalf("All = %v, want %v", got, want)
+	}
+}
+
+// exerciseMap runs random sets and deletes against a Map and a plain map side by side. Every 50 steps
+// it keeps a snapshot of both and checks at the end that none of the old versions moved
+func exerciseMap(t *testing.T, keys int) {
+	t.Helper()
+	r := rand.New(rand.NewPCG(1, 2))
+	var m Map[int, string]
+	want := map[int]string{}
+	type snapshot struct {
+		m    Map[int, string]
+		want map[int]string
+	}
+	var snapshots []snapshot
+
+	for step := range 2000 {
+		k := r.IntN(keys)
+		if r.IntN(3) == 0 {
+			m = m.Delete(k)
+			delete(want, k)
+		} else {
+			v := string(rune('a' + step%26))
+			m = m.Set(k, v)
+			want[k] = v
+		}
+		if step%50 == 0 {
+			snapshots = append(snapshots, snapshot{m, maps.Clone(want)})
+		}
+	}
+	checkMap(t, m, want)
+	for _, s := range snapshots {
+		checkMap(t, s.m, s.want)
+	}
+	if _, ok := m.Get(keys + 1); ok {
+		t.Error("Get found a key that was never set")
+	}
+	if got := m.Delete(keys + 1); got.Len() != m.Len() {
+		t.Error("deleting a missing key changed the size")
+	}
+}
+
+func TestMapAgainstBuiltin(t *testing.T) {
+	exerciseMap(t, 500)
+}
+
+// withHash swaps the hash for the length of a test
+func withHash(t *testing.T, hash func(key any) uint64) {
+	t.Cleanup(func() { testHash = nil })
+	testHash = hash
+}
+
+func TestMapCollisions(t *testing.T) {
+	t.Run("every bit", func(t *testing.T) {
+		// only 4 different hashes, so most keys share all 64 bits with others and land in collision nodes
+		withHash(t, func(key any) uint64 { return uint64(key.(int) % 4) })
+		exerciseMap(t, 100)
+	})
+	t.Run("high bits only", func(t *testing.T) {
+		// hashes that agree on every level but the last, so each pair of keys gets pushed all the way down
+		withHash(t, func(key any) uint64 { return uint64(key.(int)) << 59 })
+		exerciseMap(t, 32)
+	})
+}
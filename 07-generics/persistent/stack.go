@@ -0,0 +1,51 @@
+package persistent
+
+import "iter"
+
+// Stack is a cons list. Pushing makes a new node pointing at the old top, so the old stack is still there,
+// untouched, and both share every node below. Popping just hands back the next node down
+// It's a value type on purpose: copying a Stack copies a pointer and a size, never the items
+type Stack[T any] struct {
+	top  *cons[T]
+	size int
+}
+
+type cons[T any] struct {
+	value T
+	next  *cons[T]
+}
+
+func (s Stack[T]) Push(v T) Stack[T] {
+	return Stack[T]{top: &cons[T]{value: v, next: s.top}, size: s.size + 1}
+}
+
+// Pop returns the top value and the stack without it. s itself is unchanged
+func (s Stack[T]) Pop() (T, Stack[T], bool) {
+	if s.top == nil {
+		var zero T
+		return zero, s, false
+	}
+	return s.top.value, Stack[T]{top: s.top.next, size: s.size - 1}, true
+}
+
+func (s Stack[T]) Peek() (T, bool) {
+	if s.top == nil {
+		var zero T
+		return zero, false
+	}
+	return s.top.value, true
+}
+
+func (s Stack[T]) Len() int {
+	return s.size
+}
+
+func (s Stack[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for n := s.top; n != nil; n = n.next {
+			if !yield(n.value) {
+				return
+			}
+		}
+	}
+}
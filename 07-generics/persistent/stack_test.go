@@ -0,0 +1,26 @@
+package persistent
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestStack(t *testing.T) {
+	var empty Stack[string]
+	one := empty.Push("a")
+	two := one.Push("b")
+
+	if top, ok := two.Peek(); !ok || top != "b" || two.Len() != 2 {
+		t.Errorf("Peek = %q %v with %d, want b of 2", top, ok, two.Len())
+	}
+	top, rest, ok := two.Pop()
+	if !ok || top != "b" || rest.Len() != 1 {
+		t.Errorf("Pop = %q %v leaving %d, want b leaving 1", top, ok, rest.Len())
+	}
+	if got := slices.Collect(two.All()); !slices.Equal(got, []string{"b", "a"}) {
+		t.Errorf("Pop changed the stack it was called on, All = %v", got)
+	}
+	if _, _, ok := empty.Pop(); ok {
+		t.Error("Pop on an empty stack reported a value")
+	}
+}
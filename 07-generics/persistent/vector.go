@@ -0,0 +1,122 @@
+package persistent
+
+import (
+	"iter"
+	"slices"
+)
+
+// Vector is a 32-way trie. The index is read 5 bits at a time from the top, each 5 bits picks a child,
+// and the last 5 pick the value in a leaf. A million items is only four levels deep
+//
+// Changing an item copies the nodes on the path from the root down to it, at most 32 pointers a level,
+// and shares everything else with the old version. That's what makes old versions cheap to keep
+
+const (
+	vectorBits  = 5
+	vectorWidth = 1 << vectorBits
+	vectorMask  = vectorWidth - 1
+)
+
+type Vector[T any] struct {
+	root  *vectorNode[T]
+	shift uint // bits below the root, 0 when the root is a leaf
+	size  int
+}
+
+// A branch uses children, a leaf uses values. Never both
+type vectorNode[T any] struct {
+	children []*vectorNode[T]
+	values   []T
+}
+
+func (n *vectorNode[T]) clone() *vectorNode[T] {
+	if n == nil {
+		return &vectorNode[T]{}
+	}
+	return &vectorNode[T]{children: slices.Clone(n.children), values: slices.Clone(n.values)}
+}
+
+func (v Vector[T]) Len() int {
+	return v.size
+}
+
+func (v Vector[T]) Get(i int) (T, bool) {
+	if i < 0 || i >= v.size {
+		var zero T
+		return zero, false
+	}
+	n := v.root
+	for shift := v.shift; shift > 0; shift -= vectorBits {
+		n = n.children[(i>>shift)&vectorMask]
+	}
+	return n.values[i&vectorMask], true
+}
+
+// Set returns a vector with item i replaced. False means i was out of range
+func (v Vector[T]) Set(i int, x T) (Vector[T], bool) {
+	if i < 0 || i >= v.size {
+		return v, false
+	}
+	v.root = setAt(v.root, v.shift, i, x)
+	return v, true
+}
+
+func setAt[T any](n *vectorNode[T], shift uint, i int, x T) *vectorNode[T] {
+	c := n.clone()
+	if shift == 0 {
+		c.values[i&vectorMask] = x
+		return c
+	}
+	idx := (i >> shift) & vectorMask
+	c.children[idx] = setAt(c.children[idx], shift-vectorBits, i, x)
+	return c
+}
+
+func (v Vector[T]) Append(x T) Vector[T] {
+	// the trie is full, so it grows a level: a new root with the old one as its first child
+	if v.root != nil && v.size == 1<<(v.shift+vectorBits) {
+		v.root = &vectorNode[T]{children: []*vectorNode[T]{v.root}}
+		v.shift += vectorBits
+	}
+	v.root = appendAt(v.root, v.shift, v.size, x)
+	v.size++
+	return v
+}
+
+func appendAt[T any](n *vectorNode[T], shift uint, i int, x T) *vectorNode[T] {
+	c := n.clone()
+	if shift == 0 {
+		c.values = append(c.values, x)
+		return c
+	}
+	idx := (i >> shift) & vectorMask
+	if idx < len(c.children) {
+		c.children[idx] = appendAt(c.children[idx], shift-vectorBits, i, x)
+	} else {
+		c.children = append(c.children, appendAt(nil, shift-vectorBits, i, x))
+	}
+	return c
+}
+
+func (v Vector[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		walkVector(v.root, yield)
+	}
+}
+
+func walkVector[T any](n *vectorNode[T], yield func(T) bool) bool {
+	if n == nil {
+		return true
+	}
+	for _, x := range n.values {
+		if !yield(x) {
+			return false
+		}
+	}
+	for _, c := range n.children {
+		if !walkVector(c, yield) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+package persistent
+
+import (
+	"slices"
+	"testing"
+)
+
+// checkVector compares every way of reading v against want
+func checkVector(t *testing.T, v Vector[int], want []int) {
+	t.Helper()
+	if v.Len() != len(want) {
+		t.Fatalf("Len = %d, want %d", v.Len(), len(want))
+	}
+	for i, w := range want {
+		if got, ok := v.Get(i); !ok || got != w {
+			t.Fatalf("Get(%d) = %d %v, want %d", i, got, ok, w)
+		}
+	}
+	if _, ok := v.Get(len(want)); ok {
+		t.Fatalf("Get(%d) past the end found something", len(want))
+	}
+	if got := slices.Collect(v.All()); !slices.Equal(got, want) {
+		t.Fatalf("All = %v, want %v", got, want)
+	}
+}
+
+// A leaf holds 32, the next level 32*32. Appending across each of those grows the trie a level, and every
+// version kept along the way has to stay exactly as it was
+func TestVectorAppendAcrossLevels(t *testing.T) {
+	sizes := []int{0, 1, 31, 32, 33, 1023, 1024, 1025, 1100}
+	var v Vector[int]
+	versions := map[int]Vector[int]{0: v}
+	for i := range sizes[len(sizes)-1] {
+		v = v.Append(i)
+		if slices.Contains(sizes, i+1) {
+			versions[i+1] = v
+		}
+	}
+	for _, size := range sizes {
+		want := make([]int, size)
+		for i := range want {
+			want[i] = i
+		}
+		checkVector(t, versions[size], want)
+	}
+}
+
+func TestVectorSetKeepsVersions(t *testing.T) {
+	var old Vector[int]
+	for i := range 40 {
+		old = old.Append(i)
+	}
+	newer := old.Append(40)
+
+	// setting on the old version doesn't reach the newer one built on it
+	changedOld, ok := old.Set(33, -1)
+	if !ok {
+		t.Fatal("Set(33) out of range")
+	}
+	if got, _ := newer.Get(33); got != 33 {
+		t.Errorf("Set on the old version changed the newer to %d", got)
+	}
+	if got, _ := old.Get(33); got != 33 {
+		t.Errorf("Set changed its own receiver to %d", got)
+	}
+	if got, _ := changedOld.Get(33); got != -1 {
+		t.Errorf("Set result has %d, want -1", got)
+	}
+
+	// nor the other way round
+	changedNewer, _ := newer.Set(0, -2)
+	if got, _ := old.Get(0); got != 0 {
+		t.Errorf("Set on the newer version changed the old to %d", got)
+	}
+	if got, _ := changedNewer.Get(0); got != -2 {
+		t.Errorf("Set result has %d, want -2", got)
+	}
+
+	for _, i := range []int{-1, 41} {
+		if _, ok := newer.Set(i, 0); ok {
+			t.Errorf("Set(%d) on 41 items was allowed", i)
+		}
+	}
+	// appending to two versions of the same vector gives two separate vectors
+	a, b := old.Append(100), old.Append(200)
+	if got, _ := a.Get(40); got != 100 {
+		t.Errorf("first branch has %d at 40, want 100", got)
+	}
+	if got, _ := b.Get(40); got != 200 {
+		t.Errorf("second branch has %d at 40, want 200", got)
+	}
+}
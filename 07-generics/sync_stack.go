@@ -0,0 +1,141 @@
+package main
+
+import (
+	"iter"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// Stack is fine as long as one goroutine owns it. Two goroutines pushing at once race on items,
+// both read the same length and one push is lost. Below are two ways of sharing a stack safely
+
+// ConcurrentStack is the part of Stack both shared versions support
+type ConcurrentStack[T any] interface {
+	Push(T) error
+	Pop() (T, bool)
+	Len() int
+}
+
+// SyncStack is the straightforward one: a Stack with a mutex in front of it
+// Only one goroutine is ever inside, so everything Stack can do, SyncStack can do, bounds included
+type SyncStack[T any] struct {
+	mu sync.Mutex
+	s  Stack[T]
+}
+
+func NewBoundedSyncStack[T any](capacity int) (*SyncStack[T], error) {
+	s, err := NewBoundedStack[T](capacity)
+	if err != nil {
+		return nil, err
+	}
+	return &SyncStack[T]{s: *s}, nil
+}
+
+func (s *SyncStack[T]) Push(item T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.s.Push(item)
+}
+
+func (s *SyncStack[T]) Pop() (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.s.Pop()
+}
+
+func (s *SyncStack[T]) Peek() (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.s.Peek()
+}
+
+func (s *SyncStack[T]) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.s.Len()
+}
+
+// All walks a copy taken under the lock. Holding the lock while the caller's loop body runs
+// would block every other goroutine for as long as the loop takes, or forever if the body pushes
+func (s *SyncStack[T]) All() iter.Seq[T] {
+	s.mu.Lock()
+	items := slices.Clone(s.s.items)
+	s.mu.Unlock()
+	slices.Reverse(items)
+	return slices.Values(items)
+}
+
+// LockFreeStack is a Treiber stack. The stack is a linked list and the only shared state is the pointer
+// to the top node. Push and Pop read the top, build what the new top should be, then swap it in with
+// compare-and-swap. If another goroutine got there first the swap fails and we go round again
+//
+// The classic trap is ABA: a node is popped, freed and reused at the same address, so a stale CAS still
+// succeeds. Go's GC never reuses a node while anyone holds a pointer to it, so we can't hit that
+//
+// There's no capacity limit. Checking a count and then pushing is two steps, and another goroutine can
+// push in between them, so a bound would need the lock we're trying to avoid
+type LockFreeStack[T any] struct {
+	top  atomic.Pointer[lockFreeNode[T]]
+	size atomic.Int64
+}
+
+type lockFreeNode[T any] struct {
+	value T
+	next  *lockFreeNode[T]
+}
+
+// Push never fails, the error is there to match ConcurrentStack
+func (s *LockFreeStack[T]) Push(item T) error {
+	n := &lockFreeNode[T]{value: item}
+	for {
+		n.next = s.top.Load()
+		if s.top.CompareAndSwap(n.next, n) {
+			s.size.Add(1)
+			return nil
+		}
+	}
+}
+
+func (s *LockFreeStack[T]) Pop() (T, bool) {
+	for {
+		top := s.top.Load()
+		if top == nil {
+			var zero T
+			return zero, false
+		}
+		if s.top.CompareAndSwap(top, top.next) {
+			s.size.Add(-1)
+			return top.value, true
+		}
+	}
+}
+
+func (s *LockFreeStack[T]) Peek() (T, bool) {
+	top := s.top.Load()
+	if top == nil {
+		var zero T
+		return zero, false
+	}
+	return top.value, true
+}
+
+// Len can be a moment behind, a push has swapped in its node before it bumps the count. A pop can take
+// that node and drop the count before the push catches up, so the count dips below zero for a moment.
+// No stack holds fewer than nothing, so that reads as empty
+func (s *LockFreeStack[T]) Len() int {
+	return max(0, int(s.size.Load()))
+}
+
+// All walks the stack as it was when All was called. Nodes are never changed once pushed,
+// so holding on to the old top is a consistent snapshot for free
+func (s *LockFreeStack[T]) All() iter.Seq[T] {
+	top := s.top.Load()
+	return func(yield func(T) bool) {
+		for n := top; n != nil; n = n.next {
+			if !yield(n.value) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+// Both shared stacks get the same treatment. Run with -race to have the race detector watching as well
+var concurrentStacks = []struct {
+	name string
+	make func() ConcurrentStack[int]
+}{
+	{"SyncStack", func() ConcurrentStack[int] { return &SyncStack[int]{} }},
+	{"LockFreeStack", func() ConcurrentStack[int] { return &LockFreeStack[int]{} }},
+}
+
+// Every worker pushes then pops its share at the same time as all the others. Every value pushed has to
+// come back out exactly once and the stack has to end up empty
+func TestConcurrentStack(t *testing.T) {
+	const workers, ops = 8, 2000
+	for _, tc := range concurrentStacks {
+		t.Run(tc.name, func(t *testing.T) {
+			s := tc.make()
+			var wg sync.WaitGroup
+			popped := make([][]int, workers)
+			for w := range workers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := range ops {
+						if err := s.Push(w*ops + i); err != nil {
+							t.Errorf("push: %v", err)
+							return
+						}
+					}
+					for range ops {
+						if v, ok := s.Pop(); ok {
+							popped[w] = append(popped[w], v)
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			seen := make([]bool, workers*ops)
+			total := 0
+			for _, values := range popped {
+				for _, v := range values {
+					if seen[v] {
+						t.Fatalf("popped %d twice", v)
+					}
+					seen[v] = true
+					total++
+				}
+			}
+			if total != workers*ops {
+				t.Errorf("pushed %d values but popped %d", workers*ops, total)
+			}
+			if s.Len() != 0 {
+				t.Errorf("stack should be empty, has %d", s.Len())
+			}
+		})
+	}
+}
+
+// Forcing the window where a pop has counted down before the push it took from counted up
+func TestLockFreeStackLenNeverNegative(t *testing.T) {
+	var s LockFreeStack[int]
+	s.size.Add(-1)
+	if got := s.Len(); got != 0 {
+		t.Errorf("Len with the count behind = %d, want 0", got)
+	}
+}
+
+// Every goroutine pushes and pops the same stack, so this is as contended as it gets
+// Compare them with go test -bench Stack -cpu 1,4,8
+
+func benchmarkStack(b *testing.B, s ConcurrentStack[int]) {
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			s.Push(i)
+			s.Pop()
+		}
+	})
+}
+
+func BenchmarkSyncStack(b *testing.B) {
+	benchmarkStack(b, &SyncStack[int]{})
+}
+
+func BenchmarkLockFreeStack(b *testing.B) {
+	benchmarkStack(b, &LockFreeStack[int]{})
+}
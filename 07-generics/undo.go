@@ -0,0 +1,323 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Undo and redo are two stacks. Doing something pushes it onto undo. Undoing pops it off undo and pushes
+// it onto redo, redoing moves it back. Doing something new throws the redo stack away, that future
+// no longer exists
+
+// Command is a reversible change. Kind names it in a saved history, and its exported fields are
+// what gets saved, so a command should keep whatever it needs to undo itself in exported fields
+type Command interface {
+	Do() error
+	Undo() error
+	Kind() string
+}
+
+var (
+	ErrNothingToUndo    = errors.New("nothing to undo")
+	ErrNothingToRedo    = errors.New("nothing to redo")
+	ErrNoTransaction    = errors.New("no transaction open")
+	ErrTransactionOpen  = errors.New("a transaction is already open")
+	ErrTransactionInUse = errors.New("cannot undo or redo while a transaction is open")
+)
+
+// transaction is a group of commands that undo and redo as one
+type transaction struct {
+	name     string
+	commands []Command
+}
+
+// undo reverses the commands last to first. If one fails we redo the ones already undone,
+// so the document is back where it was instead of halfway between two states
+func (t transaction) undo() error {
+	for i := len(t.commands) - 1; i >= 0; i-- {
+		if err := t.commands[i].Undo(); err != nil {
+			for _, c := range t.commands[i+1:] {
+				c.Do()
+			}
+			return fmt.Errorf("undo %s: %w", t.name, err)
+		}
+	}
+	return nil
+}
+
+func (t transaction) redo() error {
+	for i, c := range t.commands {
+		if err := c.Do(); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				t.commands[j].Undo()
+			}
+			return fmt.Errorf("redo %s: %w", t.name, err)
+		}
+	}
+	return nil
+}
+
+type History struct {
+	undo  Stack[transaction]
+	redo  Stack[transaction]
+	open  *transaction
+	limit int // how many transactions undo remembers, 0 for no limit
+}
+
+func NewHistory(limit int) *History {
+	return &History{limit: limit}
+}
+
+// dropBottom removes the oldest item. A stack doesn't normally let you at the bottom, but a bounded
+// history would rather forget its oldest change than refuse a new one, which is what a bounded Stack does
+func (s *Stack[T]) dropBottom() {
+	if len(s.items) > 0 {
+		var zero T
+		s.items[0] = zero
+		s.items = s.items[1:]
+	}
+}
+
+func (h *History) record(t transaction) {
+	h.undo.Push(t)
+	if h.limit > 0 && h.undo.Len() > h.limit {
+		h.undo.dropBottom()
+	}
+	h.redo = Stack[transaction]{}
+}
+
+// Execute does the command and remembers it. Inside a transaction it joins the transaction,
+// otherwise it's a transaction of its own
+func (h *History) Execute(c Command) error {
+	if err := c.Do(); err != nil {
+		return err
+	}
+	if h.open != nil {
+		h.open.commands = append(h.open.commands, c)
+		return nil
+	}
+	h.record(transaction{name: c.Kind(), commands: []Command{c}})
+	return nil
+}
+
+func (h *History) Begin(name string) error {
+	if h.open != nil {
+		return ErrTransactionOpen
+	}
+	h.open = &transaction{name: name}
+	return nil
+}
+
+func (h *History) Commit() error {
+	if h.open == nil {
+		return ErrNoTransaction
+	}
+	t := *h.open
+	h.open = nil
+	if len(t.commands) > 0 {
+		h.record(t)
+	}
+	return nil
+}
+
+// Rollback undoes everything done since Begin and forgets it. If a command won't undo, undo puts back
+// the ones it already reversed, so the transaction stays open with all of its changes still applied.
+// Forgetting it then would leave changes in the document that nothing can undo
+func (h *History) Rollback() error {
+	if h.open == nil {
+		return ErrNoTransaction
+	}
+	if err := h.open.undo(); err != nil {
+		return err
+	}
+	h.open = nil
+	return nil
+}
+
+func (h *History) Undo() error {
+	if h.open != nil {
+		return ErrTransactionInUse
+	}
+	t, ok := h.undo.Pop()
+	if !ok {
+		return ErrNothingToUndo
+	}
+	if err := t.undo(); err != nil {
+		h.undo.Push(t)
+		return err
+	}
+	h.redo.Push(t)
+	return nil
+}
+
+func (h *History) Redo() error {
+	if h.open != nil {
+		return ErrTransactionInUse
+	}
+	t, ok := h.redo.Pop()
+	if !ok {
+		return ErrNothingToRedo
+	}
+	if err := t.redo(); err != nil {
+		h.redo.Push(t)
+		return err
+	}
+	h.undo.Push(t)
+	return nil
+}
+
+// UndoNames lists what Undo would undo, next first
+func (h *History) UndoNames() []string {
+	var names []string
+	for t := range h.undo.All() {
+		names = append(names, t.name)
+	}
+	return names
+}
+
+//////////////////////////////////////////////////////////////////////
+//                     Saving a session                             //
+//////////////////////////////////////////////////////////////////////
+
+// A saved history only makes sense next to the document it was recorded against. Save them together,
+// restore them together, and nothing is re-run on restore
+
+type savedCommand struct {
+	Kind string          `json:"kind"`
+	Data json.RawMessage `json:"data"`
+}
+
+type savedTransaction struct {
+	Name     string         `json:"name"`
+	Commands []savedCommand `json:"commands"`
+}
+
+type savedHistory struct {
+	Limit int                `json:"limit"`
+	Undo  []savedTransaction `json:"undo"` // bottom of the stack first
+	Redo  []savedTransaction `json:"redo"`
+}
+
+func saveStack(s *Stack[transaction]) ([]savedTransaction, error) {
+	out := []savedTransaction{}
+	for t := range s.All() {
+		saved := savedTransaction{Name: t.name}
+		for _, c := range t.commands {
+			data, err := json.Marshal(c)
+			if err != nil {
+				return nil, fmt.Errorf("save %s: %w", c.Kind(), err)
+			}
+			saved.Commands = append(saved.Commands, savedCommand{Kind: c.Kind(), Data: data})
+		}
+		out = append(out, saved)
+	}
+	// All walks top down, but we restore by pushing, so the bottom has to come first
+	slices.Reverse(out)
+	return out, nil
+}
+
+func (h *History) MarshalJSON() ([]byte, error) {
+	if h.open != nil {
+		return nil, ErrTransactionInUse
+	}
+	undo, err := saveStack(&h.undo)
+	if err != nil {
+		return nil, err
+	}
+	redo, err := saveStack(&h.redo)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(savedHistory{Limit: h.limit, Undo: undo, Redo: redo})
+}
+
+// CommandDecoder rebuilds a command from its kind and saved fields. It's also where a restored command
+// gets hooked back up to the document it edits, which was never saved
+type CommandDecoder func(kind string, data json.RawMessage) (Command, error)
+
+func restoreStack(saved []savedTransaction, decode CommandDecoder) (Stack[transaction], error) {
+	var s Stack[transaction]
+	for _, st := range saved {
+		t := transaction{name: st.Name}
+		for _, sc := range st.Commands {
+			c, err := decode(sc.Kind, sc.Data)
+			if err != nil {
+				return s, fmt.Errorf("restore %s: %w", sc.Kind, err)
+			}
+			t.commands = append(t.commands, c)
+		}
+		s.Push(t)
+	}
+	return s, nil
+}
+
+func RestoreHistory(data []byte, decode CommandDecoder) (*History, error) {
+	var saved savedHistory
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, err
+	}
+	if saved.Limit < 0 {
+		return nil, fmt.Errorf("saved history has a negative limit %d", saved.Limit)
+	}
+	// Someone edited the file, or saved it with a bigger limit. Forget the oldest, like record would have
+	if saved.Limit > 0 && len(saved.Undo) > saved.Limit {
+		saved.Undo = saved.Undo[len(saved.Undo)-saved.Limit:]
+	}
+	undo, err := restoreStack(saved.Undo, decode)
+	if err != nil {
+		return nil, err
+	}
+	redo, err := restoreStack(saved.Redo, decode)
+	if err != nil {
+		return nil, err
+	}
+	return &History{undo: undo, redo: redo, limit: saved.Limit}, nil
+}
+
+//////////////////////////////////////////////////////////////////////
+//                     Example: a config editor                     //
+//////////////////////////////////////////////////////////////////////
+
+type config map[string]string
+
+// setKey sets a config key, and remembers what was there so it can put it back
+type setKey struct {
+	cfg     config
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+	Old     string `json:"old"`
+	Existed bool   `json:"existed"`
+}
+
+func (c *setKey) Kind() string { return "set" }
+
+func (c *setKey) Do() error {
+	if c.Key == "" {
+		return errors.New("config keys can't be empty")
+	}
+	c.Old, c.Existed = c.cfg[c.Key]
+	c.cfg[c.Key] = c.Value
+	return nil
+}
+
+func (c *setKey) Undo() error {
+	if c.Existed {
+		c.cfg[c.Key] = c.Old
+	} else {
+		delete(c.cfg, c.Key)
+	}
+	return nil
+}
+
+func configDecoder(cfg config) CommandDecoder {
+	return func(kind string, data json.RawMessage) (Command, error) {
+		switch kind {
+		case "set":
+			c := &setKey{cfg: cfg}
+			return c, json.Unmarshal(data, c)
+		}
+		return nil, fmt.Errorf("unknown command %q", kind)
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+)
+
+// stuck does nothing and refuses to be undone, the way a command fails when the world moved under it
+type stuck struct{}
+
+func (stuck) Kind() string { return "stuck" }
+func (stuck) Do() error    { return nil }
+func (stuck) Undo() error  { return errors.New("stuck") }
+
+func set(cfg config, key, value string) *setKey {
+	return &setKey{cfg: cfg, Key: key, Value: value}
+}
+
+func wantConfig(t *testing.T, got, want config) {
+	t.Helper()
+	if !maps.Equal(got, want) {
+		t.Fatalf("config %v, want %v", got, want)
+	}
+}
+
+func TestUndoRedo(t *testing.T) {
+	cfg := config{"theme": "dark"}
+	h := NewHistory(0)
+	h.Execute(set(cfg, "theme", "light"))
+	h.Execute(set(cfg, "font", "mono"))
+	wantConfig(t, cfg, config{"theme": "light", "font": "mono"})
+
+	if err := h.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	wantConfig(t, cfg, config{"theme": "light"})
+	if err := h.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	wantConfig(t, cfg, config{"theme": "dark"})
+	if err := h.Undo(); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("Undo past the start error = %v, want ErrNothingToUndo", err)
+	}
+
+	if err := h.Redo(); err != nil {
+		t.Fatal(err)
+	}
+	wantConfig(t, cfg, config{"theme": "light"})
+
+	// a new change means the undone font no longer has a future to come back to
+	h.Execute(set(cfg, "size", "12"))
+	if err := h.Redo(); !errors.Is(err, ErrNothingToRedo) {
+		t.Errorf("Redo after a new Execute error = %v, want ErrNothingToRedo", err)
+	}
+	wantConfig(t, cfg, config{"theme": "light", "size": "12"})
+
+	// a command that fails isn't recorded
+	if err := h.Execute(set(cfg, "", "x")); err == nil {
+		t.Error("an empty key was accepted")
+	}
+	if got, want := h.UndoNames(), []string{"set", "set"}; !slices.Equal(got, want) {
+		t.Errorf("UndoNames = %v, want %v", got, want)
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	cfg := config{}
+	h := NewHistory(0)
+
+	if err := h.Commit(); !errors.Is(err, ErrNoTransaction) {
+		t.Errorf("Commit without Begin error = %v, want ErrNoTransaction", err)
+	}
+	h.Begin("connect")
+	if err := h.Begin("again"); !errors.Is(err, ErrTransactionOpen) {
+		t.Errorf("nested Begin error = %v, want ErrTransactionOpen", err)
+	}
+	h.Execute(set(cfg, "host", "db"))
+	h.Execute(set(cfg, "port", "5432"))
+	for name, call := range map[string]func() error{"Undo": h.Undo, "Redo": h.Redo} {
+		if err := call(); !errors.Is(err, ErrTransactionInUse) {
+			t.Errorf("%s inside a transaction error = %v, want ErrTransactionInUse", name, err)
+		}
+	}
+	if _, err := json.Marshal(h); !errors.Is(err, ErrTransactionInUse) {
+		t.Errorf("saving inside a transaction error = %v, want ErrTransactionInUse", err)
+	}
+	if err := h.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the whole transaction is one step
+	if got := h.UndoNames(); !slices.Equal(got, []string{"connect"}) {
+		t.Errorf("UndoNames = %v, want [connect]", got)
+	}
+	h.Undo()
+	wantConfig(t, cfg, config{})
+	h.Redo()
+	wantConfig(t, cfg, config{"host": "db", "port": "5432"})
+
+	h.Begin("abandoned")
+	h.Execute(set(cfg, "host", "other"))
+	h.Execute(set(cfg, "user", "root"))
+	if err := h.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	wantConfig(t, cfg, config{"host": "db", "port": "5432"})
+	if got := h.UndoNames(); !slices.Equal(got, []string{"connect"}) {
+		t.Errorf("after Rollback UndoNames = %v, want [connect]", got)
+	}
+	if err := h.Rollback(); !errors.Is(err, ErrNoTransaction) {
+		t.Errorf("second Rollback error = %v, want ErrNoTransaction", err)
+	}
+
+	// an empty transaction leaves nothing to undo
+	h.Begin("nothing")
+	h.Commit()
+	if got := h.UndoNames(); !slices.Equal(got, []string{"connect"}) {
+		t.Errorf("an empty transaction was recorded, UndoNames = %v", got)
+	}
+}
+
+// When part of a rollback fails, what was rolled back is put back and the transaction stays open,
+// so its changes can still be committed and undone later instead of being stranded in the document
+func TestRollbackFailureKeepsTransaction(t *testing.T) {
+	cfg := config{}
+	h := NewHistory(0)
+	h.Begin("stuck")
+	h.Execute(stuck{})
+	h.Execute(set(cfg, "host", "db"))
+
+	if err := h.Rollback(); err == nil {
+		t.Fatal("Rollback past a command that won't undo succeeded")
+	}
+	wantConfig(t, cfg, config{"host": "db"})
+	if err := h.Commit(); err != nil {
+		t.Fatalf("the transaction should still be open: %v", err)
+	}
+	if got := h.UndoNames(); !slices.Equal(got, []string{"stuck"}) {
+		t.Errorf("UndoNames = %v, want [stuck]", got)
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	cfg := config{}
+	h := NewHistory(2)
+	for _, v := range []string{"1", "2", "3"} {
+		h.Execute(set(cfg, "v", v))
+	}
+	h.Undo()
+	h.Undo()
+	wantConfig(t, cfg, config{"v": "1"})
+	if err := h.Undo(); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("the oldest change should have been forgotten, Undo error = %v", err)
+	}
+}
+
+func TestSaveAndRestore(t *testing.T) {
+	cfg := config{}
+	h := NewHistory(5)
+	h.Execute(set(cfg, "a", "1"))
+	h.Begin("pair")
+	h.Execute(set(cfg, "b", "2"))
+	h.Execute(set(cfg, "a", "3"))
+	h.Commit()
+	h.Execute(set(cfg, "c", "4"))
+	h.Undo()
+
+	saved, err := json.Marshal(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restoredCfg := maps.Clone(cfg)
+	restored, err := RestoreHistory(saved, configDecoder(restoredCfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := restored.UndoNames(), h.UndoNames(); !slices.Equal(got, want) {
+		t.Errorf("restored UndoNames = %v, want %v", got, want)
+	}
+
+	// the restored history drives its own copy of the config exactly as the original would
+	restored.Redo()
+	wantConfig(t, restoredCfg, config{"a": "3", "b": "2", "c": "4"})
+	restored.Undo()
+	restored.Undo()
+	wantConfig(t, restoredCfg, config{"a": "1"})
+	restored.Undo()
+	wantConfig(t, restoredCfg, config{})
+	if err := restored.Undo(); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("Undo past the restored start error = %v, want ErrNothingToUndo", err)
+	}
+}
+
+func TestRestoreHistoryLimit(t *testing.T) {
+	decode := configDecoder(config{})
+	if _, err := RestoreHistory([]byte(`{"limit": -1}`), decode); err == nil {
+		t.Error("a negative limit was restored")
+	}
+
+	saved := `{"limit": 1, "undo": [
+		{"name": "old", "commands": [{"kind": "set", "data": {"key": "a"}}]},
+		{"name": "new", "commands": [{"kind": "set", "data": {"key": "b"}}]}
+	]}`
+	h, err := RestoreHistory([]byte(saved), decode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := h.UndoNames(); !slices.Equal(got, []string{"new"}) {
+		t.Errorf("UndoNames over the limit = %v, want only [new]", got)
+	}
+
+	if _, err := RestoreHistory([]byte(`{"undo": [{"name": "x", "commands": [{"kind": "delete"}]}]}`), decode); err == nil {
+		t.Error("an unknown command kind was restored")
+	}
+}
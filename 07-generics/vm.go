@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//////////////////////////////////////////////////////////////////////
+//                     A stack machine                              //
+//////////////////////////////////////////////////////////////////////
+
+// The VM has no registers. Every instruction takes its inputs off the data stack and pushes its result,
+// so `2 + 3 * 4` is push 2, push 3, push 4, mul, add. Calls push their return address onto a second stack
+// Both are bounded Stacks, so runaway recursion is an ErrStackOverflow, not the host running out of memory
+
+// The trailing comments are the mnemonics the assembler reads, enumgen generates the rest
+
+//go:generate go run -C ../enumgen . -type=Opcode -dir=$PWD
+type Opcode byte
+
+const (
+	OpHalt Opcode = iota // halt
+	OpPush               // push
+	OpPop                // pop
+	OpDup                // dup
+	OpSwap               // swap
+	OpAdd                // add
+	OpSub                // sub
+	OpMul                // mul
+	OpDiv                // div
+	OpEq                 // eq
+	OpLt                 // lt
+	OpJmp                // jmp
+	OpJz                 // jz
+	OpCall               // call
+	OpRet                // ret
+)
+
+// Instructions with an operand are followed by it as a little endian int32
+const operandSize = 4
+
+func (op Opcode) hasOperand() bool {
+	switch op {
+	case OpPush, OpJmp, OpJz, OpCall:
+		return true
+	}
+	return false
+}
+
+var (
+	ErrStackUnderflow = errors.New("stack underflow")
+	ErrDivideByZero   = errors.New("divide by zero")
+	ErrBadJump        = errors.New("jump outside the program")
+	ErrBadOpcode      = errors.New("unknown opcode")
+	ErrStepLimit      = errors.New("step limit reached")
+	ErrHalted         = errors.New("machine has halted")
+	ErrBreakpoint     = errors.New("stopped at breakpoint")
+)
+
+//////////////////////////////////////////////////////////////////////
+//                     Assembler                                    //
+//////////////////////////////////////////////////////////////////////
+
+// Assemble turns source into bytecode. One instruction per line, `;` starts a comment and `name:` on
+// its own line labels the next instruction. Jumps and calls take a label or an address. labels maps each
+// label to its address, so a debugger can set a breakpoint by name instead of counting bytes
+//
+//	    push 5
+//	    call square
+//	    halt
+//	square:
+//	    dup
+//	    mul
+//	    ret
+func Assemble(src string) (code []byte, labels map[string]int, err error) {
+	type fixup struct {
+		at    int
+		label string
+		line  int
+	}
+	labels = map[string]int{}
+	var fixups []fixup
+
+	scanner := bufio.NewScanner(strings.NewReader(src))
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), ";")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if label, ok := strings.CutSuffix(fields[0], ":"); ok && len(fields) == 1 {
+			if label == "" || startsLikeNumber(label) {
+				return nil, nil, fmt.Errorf("line %d: label %q must start with a letter", line, label)
+			}
+			if _, dup := labels[label]; dup {
+				return nil, nil, fmt.Errorf("line %d: label %s defined twice", line, label)
+			}
+			labels[label] = len(code)
+			continue
+		}
+
+		op, err := ParseOpcode(fields[0])
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		operands := 0
+		if op.hasOperand() {
+			operands = 1
+		}
+		if len(fields)-1 != operands {
+			return nil, nil, fmt.Errorf("line %d: %s takes %d operand(s)", line, op, operands)
+		}
+		code = append(code, byte(op))
+		if !op.hasOperand() {
+			continue
+		}
+		n, err := strconv.ParseInt(fields[1], 10, 32)
+		switch {
+		case err == nil:
+		case errors.Is(err, strconv.ErrSyntax) && !startsLikeNumber(fields[1]):
+			// not a number, so it's a label we may not have seen yet. Leave room and fill it in at the end
+			fixups = append(fixups, fixup{at: len(code), label: fields[1], line: line})
+		default:
+			// a number that doesn't fit in 32 bits, or a typo in one, is not a label either
+			return nil, nil, fmt.Errorf("line %d: %s operand: %w", line, op, err)
+		}
+		code = binary.LittleEndian.AppendUint32(code, uint32(int32(n)))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	for _, f := range fixups {
+		addr, ok := labels[f.label]
+		if !ok {
+			return nil, nil, fmt.Errorf("line %d: no label %s", f.line, f.label)
+		}
+		binary.LittleEndian.PutUint32(code[f.at:], uint32(addr))
+	}
+	return code, labels, nil
+}
+
+// decode reads the instruction at pc, returning it, its operand and the address of the next one
+func decode(code []byte, pc int) (Opcode, int, int, error) {
+	if pc < 0 || pc >= len(code) {
+		return 0, 0, 0, fmt.Errorf("pc %d: %w", pc, ErrBadJump)
+	}
+	op := Opcode(code[pc])
+	if !op.IsValid() {
+		return 0, 0, 0, fmt.Errorf("pc %d: %w %d", pc, ErrBadOpcode, code[pc])
+	}
+	next := pc + 1
+	if !op.hasOperand() {
+		return op, 0, next, nil
+	}
+	if next+operandSize > len(code) {
+		return 0, 0, 0, fmt.Errorf("pc %d: %s is missing its operand", pc, op)
+	}
+	return op, int(int32(binary.LittleEndian.Uint32(code[next:]))), next + operandSize, nil
+}
+
+// Disassemble lists the bytecode one instruction a line, each with its address
+func Disassemble(code []byte) (string, error) {
+	var b strings.Builder
+	for pc := 0; pc < len(code); {
+		op, operand, next, err := decode(code, pc)
+		if err != nil {
+			return b.String(), err
+		}
+		fmt.Fprintf(&b, "%04d  %s", pc, op)
+		if op.hasOperand() {
+			fmt.Fprintf(&b, " %d", operand)
+		}
+		b.WriteByte('\n')
+		pc = next
+	}
+	return b.String(), nil
+}
+
+// startsLikeNumber is true for operands that were meant as numbers, labels can't start with a digit or sign
+func startsLikeNumber(operand string) bool {
+	return operand[0] == '-' || operand[0] == '+' || operand[0] >= '0' && operand[0] <= '9'
+}
+
+//////////////////////////////////////////////////////////////////////
+//                     Interpreter                                  //
+//////////////////////////////////////////////////////////////////////
+
+type VM struct {
+	code        []byte
+	pc          int
+	data        *Stack[int]
+	calls       *Stack[int]
+	steps       int
+	maxSteps    int // 0 for no limit
+	halted      bool
+	breakpoints map[int]bool
+	// Run stopped at the breakpoint on pc and nothing has run since, so the next Run carries on past it
+	atBreakpoint bool
+}
+
+// NewVM sizes both stacks to stackSize. maxSteps stops a program that never halts, 0 means trust it
+// There's no unbounded stackSize, runaway recursion is only caught by the call stack overflowing
+func NewVM(code []byte, stackSize, maxSteps int) (*VM, error) {
+	data, err := NewBoundedStack[int](stackSize)
+	if err != nil {
+		return nil, fmt.Errorf("stack size: %w", err)
+	}
+	if maxSteps < 0 {
+		return nil, fmt.Errorf("max steps can't be negative, got %d", maxSteps)
+	}
+	calls, _ := NewBoundedStack[int](stackSize)
+	return &VM{
+		code:        code,
+		data:        data,
+		calls:       calls,
+		maxSteps:    maxSteps,
+		breakpoints: map[int]bool{},
+	}, nil
+}
+
+func (vm *VM) Break(addr int) {
+	vm.breakpoints[addr] = true
+}
+
+func (vm *VM) PC() int {
+	return vm.pc
+}
+
+// Stack returns the data stack, top first
+func (vm *VM) Stack() []int {
+	var out []int
+	for v := range vm.data.All() {
+		out = append(out, v)
+	}
+	return out
+}
+
+// Result is whatever is on top of the data stack
+func (vm *VM) Result() (int, bool) {
+	return vm.data.Peek()
+}
+
+// Current disassembles the instruction about to run
+func (vm *VM) Current() string {
+	op, operand, _, err := decode(vm.code, vm.pc)
+	if err != nil {
+		return err.Error()
+	}
+	if op.hasOperand() {
+		return fmt.Sprintf("%04d  %s %d", vm.pc, op, operand)
+	}
+	return fmt.Sprintf("%04d  %s", vm.pc, op)
+}
+
+func (vm *VM) pop() (int, error) {
+	v, ok := vm.data.Pop()
+	if !ok {
+		return 0, ErrStackUnderflow
+	}
+	return v, nil
+}
+
+// pop2 takes both or neither, an underflow leaves the one value that was there where it was
+func (vm *VM) pop2() (int, int, error) {
+	if vm.data.Len() < 2 {
+		return 0, 0, ErrStackUnderflow
+	}
+	b, _ := vm.data.Pop()
+	a, _ := vm.data.Pop()
+	return a, b, nil
+}
+
+func (vm *VM) checkJump(addr int) error {
+	if addr < 0 || addr >= len(vm.code) {
+		return fmt.Errorf("%w: %d", ErrBadJump, addr)
+	}
+	return nil
+}
+
+func (vm *VM) jump(addr int) error {
+	if err := vm.checkJump(addr); err != nil {
+		return err
+	}
+	vm.pc = addr
+	return nil
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Step runs one instruction
+func (vm *VM) Step() error {
+	if vm.halted {
+		return ErrHalted
+	}
+	if vm.maxSteps > 0 && vm.steps >= vm.maxSteps {
+		return ErrStepLimit
+	}
+	op, operand, next, err := decode(vm.code, vm.pc)
+	if err != nil {
+		return err
+	}
+	pc := vm.pc
+	vm.steps++
+	vm.atBreakpoint = false
+	if err := vm.execute(op, operand, next); err != nil {
+		// leave pc on the instruction that failed, so a debugger shows where it happened
+		vm.pc = pc
+		return fmt.Errorf("pc %d %s: %w", pc, op, err)
+	}
+	return nil
+}
+
+// execute checks everything an instruction needs before it changes anything. When one fails the stacks
+// are as it found them and Step only has to put pc back, so the machine can be inspected or fixed up and
+// stepped again
+func (vm *VM) execute(op Opcode, operand, next int) error {
+	// most instructions just fall through to the next one, jumps overwrite this
+	pc := vm.pc
+	vm.pc = next
+
+	switch op {
+	case OpHalt:
+		vm.halted = true
+		vm.pc = pc
+	case OpPush:
+		return vm.data.Push(operand)
+	case OpPop:
+		_, err := vm.pop()
+		return err
+	case OpDup:
+		v, ok := vm.data.Peek()
+		if !ok {
+			return ErrStackUnderflow
+		}
+		return vm.data.Push(v)
+	case OpSwap:
+		a, b, err := vm.pop2()
+		if err != nil {
+			return err
+		}
+		vm.data.Push(b)
+		return vm.data.Push(a)
+	case OpAdd, OpSub, OpMul, OpDiv, OpEq, OpLt:
+		if vm.data.Len() < 2 {
+			return ErrStackUnderflow
+		}
+		if b, _ := vm.data.Peek(); op == OpDiv && b == 0 {
+			return ErrDivideByZero
+		}
+		a, b, _ := vm.pop2()
+		var r int
+		switch op {
+		case OpAdd:
+			r = a + b
+		case OpSub:
+			r = a - b
+		case OpMul:
+			r = a * b
+		case OpDiv:
+			r = a / b
+		case OpEq:
+			r = boolToInt(a == b)
+		case OpLt:
+			r = boolToInt(a < b)
+		}
+		return vm.data.Push(r)
+	case OpJmp:
+		return vm.jump(operand)
+	case OpJz:
+		v, ok := vm.data.Peek()
+		if !ok {
+			return ErrStackUnderflow
+		}
+		if v == 0 {
+			if err := vm.jump(operand); err != nil {
+				return err
+			}
+		}
+		vm.data.Pop()
+	case OpCall:
+		if err := vm.checkJump(operand); err != nil {
+			return err
+		}
+		if err := vm.calls.Push(next); err != nil {
+			return fmt.Errorf("call stack: %w", err)
+		}
+		vm.pc = operand
+	case OpRet:
+		addr, ok := vm.calls.Peek()
+		if !ok {
+			return fmt.Errorf("call stack: %w", ErrStackUnderflow)
+		}
+		if err := vm.jump(addr); err != nil {
+			return err
+		}
+		vm.calls.Pop()
+	}
+	return nil
+}
+
+// Run steps until the program halts, fails, or reaches a breakpoint. It stops before the instruction
+// at a breakpoint runs, including the very first one. Run again to carry on past it
+func (vm *VM) Run() error {
+	for {
+		if vm.halted {
+			return nil
+		}
+		if vm.breakpoints[vm.pc] && !vm.atBreakpoint {
+			vm.atBreakpoint = true
+			return ErrBreakpoint
+		}
+		if err := vm.Step(); err != nil {
+			return err
+		}
+	}
+}
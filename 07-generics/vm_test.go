@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestAssembleOperands(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		src     string
+		wantErr error
+	}{
+		{name: "number", src: "push -42\nhalt"},
+		{name: "label", src: "start:\npush 1\njmp start"},
+		{name: "label defined later", src: "jmp end\nend:\nhalt"},
+		{name: "out of range", src: "push 99999999999", wantErr: strconv.ErrRange},
+		{name: "typo in a number", src: "push 12x", wantErr: strconv.ErrSyntax},
+		{name: "typo in a negative number", src: "push -x", wantErr: strconv.ErrSyntax},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := Assemble(tc.src)
+			if tc.wantErr == nil && err != nil {
+				t.Fatalf("Assemble: %v", err)
+			}
+			if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Fatalf("Assemble error = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestAssembleLabelNames(t *testing.T) {
+	for _, src := range []string{":\nhalt", "1st:\nhalt", "-x:\nhalt"} {
+		if _, _, err := Assemble(src); err == nil {
+			t.Errorf("Assemble(%q) accepted a label that reads like a number", src)
+		}
+	}
+}
+
+func TestNewVMNeedsAStack(t *testing.T) {
+	if _, err := NewVM(nil, 0, 0); !errors.Is(err, ErrInvalidCapacity) {
+		t.Errorf("NewVM with no stack error = %v, want ErrInvalidCapacity", err)
+	}
+	if _, err := NewVM(nil, 16, -1); err == nil {
+		t.Error("NewVM with negative max steps should fail")
+	}
+}
+
+// run assembles src and runs it on a fresh VM
+func run(t *testing.T, src string, stackSize, maxSteps int) (*VM, error) {
+	t.Helper()
+	code, _, err := Assemble(src)
+	if err != nil {
+		t.Fatalf("Assemble: %v", err)
+	}
+	vm, err := NewVM(code, stackSize, maxSteps)
+	if err != nil {
+		t.Fatalf("NewVM: %v", err)
+	}
+	return vm, vm.Run()
+}
+
+const factorial = `
+	    call fact
+	    halt
+	fact:
+	    dup
+	    push 2
+	    lt
+	    jz recurse
+	    ret
+	recurse:
+	    dup
+	    push 1
+	    sub
+	    call fact
+	    mul
+	    ret
+`
+
+func TestRunFactorial(t *testing.T) {
+	for n, want := range map[int]int{1: 1, 5: 120, 10: 3628800} {
+		vm, err := run(t, "push "+strconv.Itoa(n)+factorial, 64, 0)
+		if err != nil {
+			t.Fatalf("%d!: %v", n, err)
+		}
+		if got, ok := vm.Result(); !ok || got != want {
+			t.Errorf("%d! = %d %v, want %d", n, got, ok, want)
+		}
+		if len(vm.Stack()) != 1 {
+			t.Errorf("%d! left %v on the stack, want just the result", n, vm.Stack())
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		src       string
+		stackSize int
+		maxSteps  int
+		want      error
+		wantStack []int // the stack is left as the failing instruction found it
+		wantPC    int
+	}{
+		{name: "data stack overflow", src: "loop:\npush 1\njmp loop", stackSize: 4, want: ErrStackOverflow,
+			wantStack: []int{1, 1, 1, 1}, wantPC: 0},
+		{name: "call stack overflow", src: "loop:\ncall loop", stackSize: 4, want: ErrStackOverflow, wantPC: 0},
+		{name: "underflow", src: "push 1\nadd", stackSize: 4, want: ErrStackUnderflow, wantStack: []int{1}, wantPC: 5},
+		{name: "swap underflow", src: "push 1\nswap", stackSize: 4, want: ErrStackUnderflow, wantStack: []int{1}, wantPC: 5},
+		{name: "return with no call", src: "ret", stackSize: 4, want: ErrStackUnderflow},
+		{name: "divide by zero", src: "push 6\npush 0\ndiv", stackSize: 4, want: ErrDivideByZero,
+			wantStack: []int{0, 6}, wantPC: 10},
+		{name: "jump outside", src: "push 0\njz 999", stackSize: 4, want: ErrBadJump, wantStack: []int{0}, wantPC: 5},
+		{name: "step limit", src: "loop:\njmp loop", stackSize: 4, maxSteps: 100, want: ErrStepLimit},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			vm, err := run(t, tc.src, tc.stackSize, tc.maxSteps)
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("Run error = %v, want %v", err, tc.want)
+			}
+			if got := vm.Stack(); !slices.Equal(got, tc.wantStack) {
+				t.Errorf("stack after the failure = %v, want %v", got, tc.wantStack)
+			}
+			if tc.want != ErrStepLimit && vm.PC() != tc.wantPC {
+				t.Errorf("pc = %d, want %d on the failing instruction", vm.PC(), tc.wantPC)
+			}
+		})
+	}
+}
+
+// Fixing up the stack after a failure and stepping again carries on as if nothing happened
+func TestStepAgainAfterFailure(t *testing.T) {
+	vm, err := run(t, "push 6\npush 0\ndiv\nhalt", 4, 0)
+	if !errors.Is(err, ErrDivideByZero) {
+		t.Fatalf("Run error = %v, want ErrDivideByZero", err)
+	}
+	vm.data.Pop()
+	vm.data.Push(3)
+	if err := vm.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := vm.Result(); got != 2 {
+		t.Errorf("6 / 3 = %d after the retry, want 2", got)
+	}
+}
+
+func TestBreakpoints(t *testing.T) {
+	code, labels, err := Assemble("push 5" + factorial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm, _ := NewVM(code, 64, 0)
+	vm.Break(0) // before anything has run
+	vm.Break(labels["recurse"])
+
+	var stops []int
+	for {
+		err := vm.Run()
+		if !errors.Is(err, ErrBreakpoint) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			break
+		}
+		stops = append(stops, vm.PC())
+		if len(stops) > 10 {
+			t.Fatalf("still stopping after %v, Run isn't carrying on past breakpoints", stops)
+		}
+	}
+	// once at the start, then for 5, 4, 3 and 2 going down
+	r := labels["recurse"]
+	if want := []int{0, r, r, r, r}; !slices.Equal(stops, want) {
+		t.Errorf("stopped at %v, want %v", stops, want)
+	}
+	if got, _ := vm.Result(); got != 120 {
+		t.Errorf("5! with breakpoints = %d, want 120", got)
+	}
+
+	// a halted machine stays halted
+	if err := vm.Step(); !errors.Is(err, ErrHalted) {
+		t.Errorf("Step after halt error = %v, want ErrHalted", err)
+	}
+	if err := vm.Run(); err != nil {
+		t.Errorf("Run after halt = %v, want nil", err)
+	}
+}
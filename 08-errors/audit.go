@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeDenied  = "denied"  // the user got something wrong
+	OutcomeLimited = "limited" // turned away by the rate limiter before the password was checked
+	OutcomeError   = "error"   // our fault, not theirs
+)
+
+// AuditRecord is one login attempt. The password is never recorded, not even a wrong one,
+// wrong passwords are often someone's real password with a typo
+type AuditRecord struct {
+	Time    time.Time `json:"time"`
+	User    string    `json:"user"`
+	Source  string    `json:"source"`
+	Outcome string    `json:"outcome"`
+	Status  string    `json:"status,omitempty"`
+}
+
+// AuditLog appends records to a file as JSON lines. Each record is one line whatever the user typed
+// as a username, JSON escapes newlines, so nobody can forge a record by logging in as one
+//
+// Once the file reaches maxSize it's rotated: audit.log becomes audit.log.1, audit.log.1 becomes
+// audit.log.2 and so on, and the oldest past maxBackups is deleted
+type AuditLog struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+func OpenAuditLog(path string, maxSize int64, maxBackups int) (*AuditLog, error) {
+	// with no size to stay under, every record would rotate the one before it away
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("audit log max size must be positive, got %d", maxSize)
+	}
+	if maxBackups < 0 {
+		return nil, fmt.Errorf("audit log backups can't be negative, got %d", maxBackups)
+	}
+	l := &AuditLog{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	return l, l.open()
+}
+
+func (l *AuditLog) open() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.file, l.size = f, info.Size()
+	return nil
+}
+
+func backupName(path string, n int) string {
+	return path + "." + strconv.Itoa(n)
+}
+
+func (l *AuditLog) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	os.Remove(backupName(l.path, l.maxBackups))
+	for n := l.maxBackups - 1; n >= 1; n-- {
+		os.Rename(backupName(l.path, n), backupName(l.path, n+1))
+	}
+	if l.maxBackups > 0 {
+		if err := os.Rename(l.path, backupName(l.path, 1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(l.path); err != nil {
+		return err
+	}
+	return l.open()
+}
+
+func (l *AuditLog) Record(r AuditRecord) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return fmt.Errorf("rotate audit log: %w", err)
+		}
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	return err
+}
+
+func (l *AuditLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+//////////////////////////////////////////////////////////////////////
+//                     Querying                                     //
+//////////////////////////////////////////////////////////////////////
+
+// AuditQuery matches records. Empty fields match anything
+type AuditQuery struct {
+	User    string
+	Source  string
+	Outcome string
+	Since   time.Time
+}
+
+func (q AuditQuery) Match(r AuditRecord) bool {
+	return (q.User == "" || q.User == r.User) &&
+		(q.Source == "" || q.Source == r.Source) &&
+		(q.Outcome == "" || q.Outcome == r.Outcome) &&
+		!r.Time.Before(q.Since)
+}
+
+// auditFiles lists the log and its backups oldest first, so records come out in the order they happened
+func auditFiles(path string) ([]string, error) {
+	backups, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return nil, err
+	}
+	numbered := map[string]int{}
+	for _, b := range backups {
+		if n, err := strconv.Atoi(strings.TrimPrefix(b, path+".")); err == nil {
+			numbered[b] = n
+		}
+	}
+	files := make([]string, 0, len(numbered)+1)
+	for b := range numbered {
+		files = append(files, b)
+	}
+	slices.SortFunc(files, func(a, b string) int { return numbered[b] - numbered[a] })
+	return append(files, path), nil
+}
+
+// maxAuditLine is the longest line QueryAudit reads. Our records are a few hundred bytes, a username
+// from the login form is at most maxLoginBody, so anything near this long wasn't written by us
+const maxAuditLine = 1 << 20
+
+// ErrBadAuditLines means some lines of the log couldn't be read as records. A crash halfway through
+// a write leaves a torn last line, and one bad line shouldn't hide every record after it
+var ErrBadAuditLines = errors.New("unreadable audit lines skipped")
+
+// splitAuditLines is bufio.ScanLines, except a line longer than maxAuditLine doesn't stop the scan.
+// Its first maxAuditLine bytes come back as the line, which won't parse, and the rest is dropped
+func splitAuditLines() bufio.SplitFunc {
+	skipping := false
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		i := bytes.IndexByte(data, '\n')
+		switch {
+		case skipping && i < 0:
+			return len(data), nil, nil
+		case skipping:
+			skipping = false
+			return i + 1, nil, nil
+		case i < 0 && len(data) >= maxAuditLine:
+			skipping = true
+			return len(data), data, nil
+		}
+		return bufio.ScanLines(data, atEOF)
+	}
+}
+
+// QueryAudit reads the log at path and every backup of it. It only reads, so it's safe to run while
+// an AuditLog is writing, at worst it misses the records written after it started
+// Lines that aren't records are skipped. The records that could be read come back either way,
+// along with an ErrBadAuditLines saying how many were skipped and where the first one was
+func QueryAudit(path string, q AuditQuery) ([]AuditRecord, error) {
+	files, err := auditFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	var out []AuditRecord
+	var bad int
+	var firstBad error
+	for _, name := range files {
+		f, err := os.Open(name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue // rotated away while we were looking
+		}
+		if err != nil {
+			return out, err
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(nil, maxAuditLine)
+		scanner.Split(splitAuditLines())
+		for line := 1; scanner.Scan(); line++ {
+			var r AuditRecord
+			if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+				if bad++; firstBad == nil {
+					firstBad = fmt.Errorf("%s:%d: %w", name, line, err)
+				}
+				continue
+			}
+			if q.Match(r) {
+				out = append(out, r)
+			}
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return out, err
+		}
+	}
+	if bad > 0 {
+		return out, fmt.Errorf("%w: %d, the first at %w", ErrBadAuditLines, bad, firstBad)
+	}
+	return out, nil
+}
+
+// runAudit is the `audit` subcommand
+//
+//	go run . audit -log audit.log -user Mary -outcome denied -since 24h
+func runAudit(args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("audit", flag.ContinueOnError)
+	path := flags.String("log", "audit.log", "audit log to read, its backups are read too")
+	var q AuditQuery
+	flags.StringVar(&q.User, "user", "", "only attempts on this user")
+	flags.StringVar(&q.Source, "source", "", "only attempts from this source")
+	flags.StringVar(&q.Outcome, "outcome", "", "only this outcome: success, denied, limited or error")
+	since := flags.Duration("since", 0, "only attempts in the last while, 0 for all of them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *since > 0 {
+		q.Since = time.Now().Add(-*since)
+	}
+
+	// a few unreadable lines still leave the rest worth showing, the error comes after them
+	records, err := QueryAudit(*path, q)
+	if err != nil && !errors.Is(err, ErrBadAuditLines) {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tUSER\tSOURCE\tOUTCOME\tSTATUS")
+	for _, r := range records {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.Time.Format(time.DateTime), r.User, r.Source, r.Outcome, r.Status)
+	}
+	if flushErr := tw.Flush(); err == nil {
+		err = flushErr
+	}
+	return err
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+var auditEpoch = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// record is attempt i of a run a minute apart, alternating between Mary guessing and Leo getting in.
+// Both come out the same length in JSON, so a test can size the log in records
+func record(i int) AuditRecord {
+	r := AuditRecord{Time: auditEpoch.Add(time.Duration(i) * time.Minute), User: "Mary", Source: "10.0.0.1", Outcome: OutcomeDenied}
+	if i%2 == 1 {
+		r.User, r.Outcome = "Leo", OutcomeSuccess
+	}
+	return r
+}
+
+// recordSize is the length of one record's line in the log
+func recordSize(t *testing.T) int64 {
+	t.Helper()
+	line, err := json.Marshal(record(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return int64(len(line)) + 1
+}
+
+// writeAudit records attempts from..to-1 in the log at path
+func writeAudit(t *testing.T, path string, maxSize int64, maxBackups, from, to int) {
+	t.Helper()
+	audit, err := OpenAuditLog(path, maxSize, maxBackups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer audit.Close()
+	for i := from; i < to; i++ {
+		if err := audit.Record(record(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// attempts says which attempts the file at name holds, in the order they're in
+func attempts(t *testing.T, name string) []int {
+	t.Helper()
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var got []int
+	for scanner := bufio.NewScanner(f); scanner.Scan(); {
+		var r AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, int(r.Time.Sub(auditEpoch)/time.Minute))
+	}
+	return got
+}
+
+func indexes(records []AuditRecord) []int {
+	var got []int
+	for _, r := range records {
+		got = append(got, int(r.Time.Sub(auditEpoch)/time.Minute))
+	}
+	return got
+}
+
+func TestOpenAuditLogRejects(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	for _, tc := range []struct {
+		maxSize    int64
+		maxBackups int
+	}{{0, 1}, {-1, 1}, {1 << 20, -1}} {
+		if _, err := OpenAuditLog(path, tc.maxSize, tc.maxBackups); err == nil {
+			t.Errorf("OpenAuditLog accepted max size %d, %d backups", tc.maxSize, tc.maxBackups)
+		}
+	}
+}
+
+func TestAuditLogRotation(t *testing.T) {
+	size := recordSize(t)
+
+	t.Run("backups", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "audit.log")
+		// two records to a file, the newest in audit.log and the oldest pair past two backups gone
+		writeAudit(t, path, 2*size, 2, 0, 7)
+		for name, want := range map[string][]int{"audit.log": {6}, "audit.log.1": {4, 5}, "audit.log.2": {2, 3}} {
+			if got := attempts(t, filepath.Join(dir, name)); !slices.Equal(got, want) {
+				t.Errorf("%s holds %v, want %v", name, got, want)
+			}
+		}
+		if files, _ := filepath.Glob(path + "*"); len(files) != 3 {
+			t.Errorf("files %v, want audit.log and 2 backups", files)
+		}
+	})
+
+	t.Run("no backups", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "audit.log")
+		writeAudit(t, path, 2*size, 0, 0, 5)
+		if got := attempts(t, path); !slices.Equal(got, []int{4}) {
+			t.Errorf("audit.log holds %v, want [4]", got)
+		}
+		if files, _ := filepath.Glob(path + "*"); len(files) != 1 {
+			t.Errorf("files %v, want audit.log alone", files)
+		}
+	})
+
+	// a reopened log carries on from the size it already is
+	t.Run("reopened", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "audit.log")
+		writeAudit(t, path, 2*size, 1, 0, 1)
+		writeAudit(t, path, 2*size, 1, 1, 3)
+		if got := attempts(t, path); !slices.Equal(got, []int{2}) {
+			t.Errorf("audit.log holds %v, want [2]", got)
+		}
+		if got := attempts(t, path+".1"); !slices.Equal(got, []int{0, 1}) {
+			t.Errorf("audit.log.1 holds %v, want [0 1]", got)
+		}
+	})
+}
+
+func TestQueryAudit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+	// a record per file, so the backups run past 9 and have to be ordered by number, not by name
+	writeAudit(t, path, recordSize(t), 20, 0, 12)
+	// not a backup, whatever it holds
+	if err := os.WriteFile(path+".old", []byte("not a record\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		query AuditQuery
+		want  []int
+	}{
+		{"everything", AuditQuery{}, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}},
+		{"user", AuditQuery{User: "Leo"}, []int{1, 3, 5, 7, 9, 11}},
+		{"outcome", AuditQuery{Outcome: OutcomeDenied}, []int{0, 2, 4, 6, 8, 10}},
+		{"since", AuditQuery{Since: auditEpoch.Add(9 * time.Minute)}, []int{9, 10, 11}},
+		{"user since", AuditQuery{User: "Mary", Since: auditEpoch.Add(5 * time.Minute)}, []int{6, 8, 10}},
+		{"source", AuditQuery{Source: "192.0.2.1"}, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			records, err := QueryAudit(path, tc.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := indexes(records); !slices.Equal(got, tc.want) {
+				t.Errorf("QueryAudit found %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// A torn write, some junk and a line far longer than anything we write are skipped, the records
+// around them still come back, and the error says what was skipped
+func TestQueryAuditSkipsBadLines(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+	writeAudit(t, path, 2*recordSize(t), 1, 0, 4)
+
+	f, err := os.OpenFile(path+".1", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("garbage\n")
+	f.WriteString(`{"user": "` + strings.Repeat("a", 2*maxAuditLine) + "\"}\n")
+	f.Close()
+	f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"time": "2024-01-01T13:00:00Z", "us`)
+	f.Close()
+
+	records, err := QueryAudit(path, AuditQuery{})
+	if !errors.Is(err, ErrBadAuditLines) {
+		t.Fatalf("QueryAudit error = %v, want ErrBadAuditLines", err)
+	}
+	if !strings.Contains(err.Error(), ": 3, the first at "+path+".1:3") {
+		t.Errorf("error %q doesn't give the count and the first bad line", err)
+	}
+	if got := indexes(records); !slices.Equal(got, []int{0, 1, 2, 3}) {
+		t.Errorf("QueryAudit found %v, want every good record", got)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+//////////////////////////////////////////////////////////////////////
+//                     Password hashing                             //
+//////////////////////////////////////////////////////////////////////
+
+// PasswordHasher turns a password into something safe to store and checks a password against it
+// The hash carries its own salt and settings, so a store only ever keeps one string per user
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(hash, password string) (bool, error)
+}
+
+var ErrBadHash = errors.New("malformed password hash")
+
+// BcryptHasher is bcrypt from golang.org/x/crypto. It's slow on purpose and the salt and cost are kept
+// in the hash, $2a$<cost>$<salt and key>. Verify reads the cost back out, so raising Cost later doesn't
+// lock out everyone hashed before
+//
+// bcrypt only looks at the first 72 bytes of a password. Hash refuses anything longer rather than quietly
+// ignoring the rest of it
+type BcryptHasher struct {
+	Cost int
+}
+
+// Every step up in cost doubles the work, 12 is about a quarter of a second on a server today
+func NewBcryptHasher() BcryptHasher {
+	return BcryptHasher{Cost: 12}
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (h BcryptHasher) Verify(hash, password string) (bool, error) {
+	// the comparison takes the same time however many bytes are right
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword), errors.Is(err, bcrypt.ErrPasswordTooLong):
+		// too long to ever have been hashed, so it can't be the right one
+		return false, nil
+	}
+	return false, fmt.Errorf("%w: %w", ErrBadHash, err)
+}
+
+//////////////////////////////////////////////////////////////////////
+//                     Logging in                                   //
+//////////////////////////////////////////////////////////////////////
+
+// userLocks hands out one mutex per username, so two logins only wait for each other when they're for
+// the same account. A name's entry goes away once nobody holds or is waiting for it, otherwise every name
+// anyone ever tried would stay in the map for good
+type userLocks struct {
+	mu    sync.Mutex
+	locks map[string]*userLock
+}
+
+type userLock struct {
+	sync.Mutex
+	users int // holding it or waiting for it
+}
+
+func (l *userLocks) lock(user string) (unlock func()) {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = map[string]*userLock{}
+	}
+	ul, ok := l.locks[user]
+	if !ok {
+		ul = &userLock{}
+		l.locks[user] = ul
+	}
+	ul.users++
+	l.mu.Unlock()
+
+	ul.Lock()
+	return func() {
+		ul.Unlock()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if ul.users--; ul.users == 0 {
+			delete(l.locks, user)
+		}
+	}
+}
+
+type Authenticator struct {
+	// locks makes updating an account's failure count one step. Without it, parallel guesses all read the
+	// same count and each write back count+1, so an attacker gets as many tries as they can run at once
+	// Hashing is slow on purpose and happens outside it, so logins never queue up behind each other's hashes
+	locks       userLocks
+	store       UserStore
+	hasher      PasswordHasher
+	tokens      *TokenService
+	maxFailures int
+	lockout     time.Duration
+	now         func() time.Time
+	// dummyHash is checked when the user doesn't exist, so that case takes as long as a wrong password
+	dummyHash string
+}
+
+// NewAuthenticator locks an account for lockout after maxFailures wrong passwords in a row
+func NewAuthenticator(store UserStore, hasher PasswordHasher, tokens *TokenService, maxFailures int, lockout time.Duration) (*Authenticator, error) {
+	if maxFailures <= 0 {
+		return nil, fmt.Errorf("max failures must be at least 1, got %d", maxFailures)
+	}
+	if lockout <= 0 {
+		return nil, fmt.Errorf("lockout must be positive, got %v", lockout)
+	}
+	dummy, err := hasher.Hash("not anyone's password")
+	if err != nil {
+		return nil, err
+	}
+	return &Authenticator{
+		store:       store,
+		hasher:      hasher,
+		tokens:      tokens,
+		maxFailures: maxFailures,
+		lockout:     lockout,
+		now:         time.Now,
+		dummyHash:   dummy,
+	}, nil
+}
+
+// Register's scopes end up in every token the user is granted
+func (a *Authenticator) Register(user, pass string, scopes ...string) error {
+	if user == "" || pass == "" {
+		return errors.New("username and password are both required")
+	}
+	hash, err := a.hasher.Hash(pass)
+	if err != nil {
+		return err
+	}
+	return a.store.CreateUser(User{Username: user, PasswordHash: hash, Scopes: scopes})
+}
+
+func (a *Authenticator) findUserByUsername(user string) (User, error) {
+	u, err := a.store.FindUser(user)
+	if errors.Is(err, ErrNoSuchUser) {
+		a.hasher.Verify(a.dummyHash, "")
+		return u, StatusErr{
+			message: "cannot find user",
+			status:  UserNotFound,
+		}
+	}
+	return u, err
+}
+
+func (a *Authenticator) accountLocked(u User) error {
+	return StatusErr{
+		message: "account locked until " + u.LockedUntil.Format(time.Kitchen),
+		status:  AccountLocked,
+	}
+}
+
+// recordAttempt counts a wrong password against the account, and a right one clears the count
+// It reads the user again under the account's lock, the copy the password was checked against may be
+// stale by now. If other guesses locked the account in the meantime, even the right password is refused
+func (a *Authenticator) recordAttempt(user string, ok bool) (User, error) {
+	unlock := a.locks.lock(user)
+	defer unlock()
+
+	u, err := a.store.FindUser(user)
+	if err != nil {
+		return u, err
+	}
+	if a.now().Before(u.LockedUntil) {
+		return u, a.accountLocked(u)
+	}
+	if ok {
+		if u.FailedLogins == 0 && u.LockedUntil.IsZero() {
+			return u, nil
+		}
+		u.FailedLogins = 0
+		u.LockedUntil = time.Time{}
+		return u, a.store.UpdateUser(u)
+	}
+
+	u.FailedLogins++
+	if u.FailedLogins >= a.maxFailures {
+		u.FailedLogins = 0
+		u.LockedUntil = a.now().Add(a.lockout)
+	}
+	if err := a.store.UpdateUser(u); err != nil {
+		return u, err
+	}
+	return u, StatusErr{
+		message: "password incorrect",
+		status:  InvalidKey,
+	}
+}
+
+// LoginUser always fails with a StatusErr. Anything that isn't the user's fault, the store or the hasher
+// failing, is Internal with the real error as its cause
+func (a *Authenticator) LoginUser(user, pass string) (string, error) {
+	token, err := a.login(user, pass)
+	var se StatusErr
+	if err != nil && !errors.As(err, &se) {
+		return "", StatusErr{message: "login failed", status: Internal, cause: err}
+	}
+	return token, err
+}
+
+func (a *Authenticator) login(user, pass string) (string, error) {
+	u, err := a.findUserByUsername(user)
+	if err != nil {
+		return "", err
+	}
+	// a locked account is refused whatever the password, even the right one, otherwise the lockout
+	// would still let an attacker find out when they'd guessed it. The hash is checked all the same and
+	// the answer thrown away, answering without it would be quicker and give away that the account is locked
+	if a.now().Before(u.LockedUntil) {
+		a.hasher.Verify(u.PasswordHash, pass)
+		return "", a.accountLocked(u)
+	}
+	ok, err := a.hasher.Verify(u.PasswordHash, pass)
+	if err != nil {
+		return "", fmt.Errorf("user %s: %w", u.Username, err)
+	}
+	if u, err = a.recordAttempt(u.Username, ok); err != nil {
+		return "", err
+	}
+	return a.tokens.Issue(u.Username, u.Scopes...)
+}
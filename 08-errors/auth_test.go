@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestAuthenticator(t *testing.T, hasher PasswordHasher, maxFailures int) *Authenticator {
+	t.Helper()
+	tokens, err := NewTokenService("test", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens.AddKey("test", NewKey())
+	auth, err := NewAuthenticator(NewMemoryUserStore(), hasher, tokens, maxFailures, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return auth
+}
+
+func TestNewAuthenticatorRejectsNoLimit(t *testing.T) {
+	hasher := BcryptHasher{Cost: bcrypt.MinCost}
+	for _, maxFailures := range []int{-1, 0} {
+		if _, err := NewAuthenticator(NewMemoryUserStore(), hasher, nil, maxFailures, time.Hour); err == nil {
+			t.Errorf("NewAuthenticator accepted max failures %d", maxFailures)
+		}
+	}
+	if _, err := NewAuthenticator(NewMemoryUserStore(), hasher, nil, 3, 0); err == nil {
+		t.Error("NewAuthenticator accepted a lockout of 0")
+	}
+}
+
+// Guesses running at once each have to count. If they all read the same failure count, an attacker
+// would get as many guesses as they can run in parallel instead of maxFailures
+func TestParallelGuessesLockTheAccount(t *testing.T) {
+	const maxFailures, guesses = 3, 20
+	auth := newTestAuthenticator(t, BcryptHasher{Cost: bcrypt.MinCost}, maxFailures)
+	if err := auth.Register("Mary", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for range guesses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			auth.LoginUser("Mary", "wrong")
+		}()
+	}
+	wg.Wait()
+
+	if _, err := auth.LoginUser("Mary", "correct horse"); !errors.Is(err, StatusErr{status: AccountLocked}) {
+		t.Errorf("right password after %d parallel wrong ones: got %v, want the account locked", guesses, err)
+	}
+}
+
+// blockingHasher holds every Verify until release is closed
+type blockingHasher struct {
+	PasswordHasher
+	arrived chan struct{}
+	release chan struct{}
+}
+
+func (h blockingHasher) Verify(hash, password string) (bool, error) {
+	h.arrived <- struct{}{}
+	<-h.release
+	return h.PasswordHasher.Verify(hash, password)
+}
+
+// Two logins for different users must not wait for each other's password hashing
+func TestLoginsHashInParallel(t *testing.T) {
+	hasher := blockingHasher{PasswordHasher: BcryptHasher{Cost: bcrypt.MinCost}, arrived: make(chan struct{}), release: make(chan struct{})}
+	auth := newTestAuthenticator(t, hasher, 3)
+	for _, user := range []string{"Leo", "Mary"} {
+		if err := auth.Register(user, "pass"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	done := make(chan error, 2)
+	for _, user := range []string{"Leo", "Mary"} {
+		go func() {
+			_, err := auth.LoginUser(user, "pass")
+			done <- err
+		}()
+	}
+	// both have to be inside Verify before either is let go, which can't happen if one waits on the other
+	for range 2 {
+		select {
+		case <-hasher.arrived:
+		case <-time.After(5 * time.Second):
+			t.Fatal("logins for different users hashed one after the other")
+		}
+	}
+	close(hasher.release)
+	for range 2 {
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestBcryptHasher(t *testing.T) {
+	h := BcryptHasher{Cost: bcrypt.MinCost}
+	hash, err := h.Hash("open sesame")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		password string
+		want     bool
+	}{
+		{"open sesame", true},
+		{"open sesame!", false},
+		{"", false},
+		{string(make([]byte, 100)), false},
+	} {
+		if ok, err := h.Verify(hash, tc.password); ok != tc.want || err != nil {
+			t.Errorf("Verify(%.20q) = %v, %v, want %v", tc.password, ok, err, tc.want)
+		}
+	}
+	if _, err := h.Verify("not a hash", "open sesame"); !errors.Is(err, ErrBadHash) {
+		t.Errorf("Verify of a garbage hash: got %v, want ErrBadHash", err)
+	}
+	if _, err := h.Hash(string(make([]byte, 100))); err == nil {
+		t.Error("Hash accepted a password bcrypt would cut short")
+	}
+}
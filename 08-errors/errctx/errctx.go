@@ -0,0 +1,248 @@
+// Package errctx adds what a bare error string leaves out: which operation failed, the values it was
+// working with, and optionally where in the code it happened
+//
+//	return errctx.Wrap(err, "load config", "path", path)
+//
+// Printing with %v gives the usual one line message. %+v adds the fields and stack, one per line,
+// and Attr hands the lot to slog. Catch and Recover turn a panic into an error too, a *PanicError
+package errctx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"strings"
+)
+
+type Error struct {
+	op     string
+	msg    string // only for errors made by New, a wrapped one takes its message from err
+	err    error
+	fields []slog.Attr
+	stack  []uintptr
+}
+
+// kv is alternating keys and values, or slog.Attrs, the same as slog's own Info or With take
+func attrs(kv []any) []slog.Attr {
+	return slog.Group("", kv...).Value.Group()
+}
+
+func New(op, msg string, kv ...any) error {
+	return &Error{op: op, msg: msg, fields: attrs(kv)}
+}
+
+// Wrap returns nil for a nil err, so `return errctx.Wrap(f(), "f")` is safe
+func Wrap(err error, op string, kv ...any) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{op: op, err: err, fields: attrs(kv)}
+}
+
+// WithStack records the call stack at the point it's called. Capturing costs a few microseconds,
+// so it's opt in, and a no-op for an error that already carries a stack from further down
+func WithStack(err error) error {
+	if err == nil || StackOf(err) != nil {
+		return err
+	}
+	pcs := make([]uintptr, 32)
+	// skip runtime.Callers and WithStack itself, so the first frame is whoever called us
+	n := runtime.Callers(2, pcs)
+	return &Error{err: err, stack: pcs[:n]}
+}
+
+func (e *Error) Error() string {
+	msg := e.msg
+	if e.err != nil {
+		msg = e.err.Error()
+	}
+	if e.op == "" {
+		return msg
+	}
+	return e.op + ": " + msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Format prints the one line message for %v and %s, and the whole story for %+v
+func (e *Error) Format(f fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && f.Flag('+'):
+		writeVerbose(f, e, "")
+	case verb == 'q':
+		fmt.Fprintf(f, "%q", e.Error())
+	default:
+		io.WriteString(f, e.Error())
+	}
+}
+
+// LogValue covers slog.Any("error", err) when err is an *Error. Anything wrapping one, fmt.Errorf for
+// instance, hides it from slog, so Attr is the safer way to log
+func (e *Error) LogValue() slog.Value {
+	return Attr("", e).Value
+}
+
+// walk calls fn on every *Error down err's chain, outermost first. It stops at a joined error,
+// there's no single chain past that point
+func walk(err error, fn func(*Error)) {
+	for err != nil {
+		if e, ok := err.(*Error); ok {
+			fn(e)
+		}
+		err = errors.Unwrap(err)
+	}
+}
+
+// Fields collects the fields of every *Error in err's chain, outermost first
+func Fields(err error) []slog.Attr {
+	var fields []slog.Attr
+	walk(err, func(e *Error) { fields = append(fields, e.fields...) })
+	return fields
+}
+
+// Ops lists the operations err passed through, outermost first
+func Ops(err error) []string {
+	var ops []string
+	walk(err, func(e *Error) {
+		if e.op != "" {
+			ops = append(ops, e.op)
+		}
+	})
+	return ops
+}
+
+// StackOf returns the frames WithStack captured, innermost call first, or nil if it never was
+// The Go runtime's own frames are left out
+func StackOf(err error) []runtime.Frame {
+	var pcs []uintptr
+	walk(err, func(e *Error) {
+		if pcs == nil {
+			pcs = e.stack
+		}
+	})
+	if pcs == nil {
+		return nil
+	}
+	var out []runtime.Frame
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		// runtime.main and goexit are at the bottom of every stack, they tell nobody anything
+		if !strings.HasPrefix(frame.Function, "runtime.") {
+			out = append(out, frame)
+		}
+		if !more {
+			return out
+		}
+	}
+}
+
+func formatFrame(f runtime.Frame) string {
+	return fmt.Sprintf("%s (%s:%d)", f.Function, f.File, f.Line)
+}
+
+//////////////////////////////////////////////////////////////////////
+//                     Multi-errors                                 //
+//////////////////////////////////////////////////////////////////////
+
+// Join is errors.Join with friendlier printing. errors.Is and errors.As see every error inside it
+// Nil errors are dropped, and if nothing is left Join returns nil
+func Join(errs ...error) error {
+	var kept []error
+	for _, err := range errs {
+		if err != nil {
+			kept = append(kept, err)
+		}
+	}
+	switch len(kept) {
+	case 0:
+		return nil
+	case 1:
+		return kept[0]
+	}
+	return &multiError{errs: kept}
+}
+
+type multiError struct {
+	errs []error
+}
+
+func (m *multiError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d errors:", len(m.errs))
+	for _, err := range m.errs {
+		// a nested multi-error prints over several lines, keep them all under their bullet
+		b.WriteString("\n  * " + strings.ReplaceAll(err.Error(), "\n", "\n    "))
+	}
+	return b.String()
+}
+
+func (m *multiError) Unwrap() []error {
+	return m.errs
+}
+
+func (m *multiError) Format(f fmt.State, verb rune) {
+	if verb == 'v' && f.Flag('+') {
+		writeVerbose(f, m, "")
+		return
+	}
+	io.WriteString(f, m.Error())
+}
+
+// writeVerbose is the %+v layout. The message first, then what each *Error in the chain knows,
+// and every error of a multi-error likewise, indented beneath it
+func writeVerbose(w io.Writer, err error, indent string) {
+	if m, ok := err.(*multiError); ok {
+		fmt.Fprintf(w, "%s%d errors:\n", indent, len(m.errs))
+		for _, err := range m.errs {
+			writeVerbose(w, err, indent+"  ")
+		}
+		return
+	}
+	fmt.Fprintf(w, "%s%s\n", indent, err.Error())
+	for _, field := range Fields(err) {
+		fmt.Fprintf(w, "%s    %s\n", indent, field)
+	}
+	for _, frame := range StackOf(err) {
+		fmt.Fprintf(w, "%s    at %s\n", indent, formatFrame(frame))
+	}
+}
+
+//////////////////////////////////////////////////////////////////////
+//                     slog                                         //
+//////////////////////////////////////////////////////////////////////
+
+// Attr turns err into a slog group holding the message, the operations, every field and the stack
+//
+//	logger.Error("request failed", errctx.Attr("error", err))
+func Attr(key string, err error) slog.Attr {
+	if err == nil {
+		return slog.Attr{}
+	}
+	if m, ok := err.(*multiError); ok {
+		var children []any
+		for i, err := range m.errs {
+			children = append(children, Attr(fmt.Sprint(i), err))
+		}
+		return slog.Group(key, children...)
+	}
+	group := []any{slog.String("msg", err.Error())}
+	if ops := Ops(err); len(ops) > 0 {
+		group = append(group, slog.String("op", strings.Join(ops, " > ")))
+	}
+	for _, field := range Fields(err) {
+		group = append(group, field)
+	}
+	if frames := StackOf(err); frames != nil {
+		stack := make([]string, len(frames))
+		for i, frame := range frames {
+			stack[i] = formatFrame(frame)
+		}
+		group = append(group, slog.Any("stack", stack))
+	}
+	return slog.Group(key, group...)
+}
@@ -0,0 +1,137 @@
+package errctx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// The fields and operations of every layer survive, even with a plain fmt.Errorf in between
+func TestFieldsAndOpsAccumulate(t *testing.T) {
+	inner := Wrap(fs.ErrNotExist, "open", "path", "/etc/app.json")
+	middle := fmt.Errorf("while starting: %w", inner)
+	outer := Wrap(middle, "load config", slog.Int("attempt", 2))
+
+	if got, want := Ops(outer), []string{"load config", "open"}; !slices.Equal(got, want) {
+		t.Errorf("Ops = %v, want %v", got, want)
+	}
+	var fields []string
+	for _, f := range Fields(outer) {
+		fields = append(fields, f.String())
+	}
+	if want := []string{"attempt=2", "path=/etc/app.json"}; !slices.Equal(fields, want) {
+		t.Errorf("Fields = %v, want %v", fields, want)
+	}
+	if want := "load config: while starting: open: file does not exist"; outer.Error() != want {
+		t.Errorf("Error = %q, want %q", outer.Error(), want)
+	}
+	if !errors.Is(outer, fs.ErrNotExist) {
+		t.Error("errors.Is didn't reach the cause")
+	}
+	var e *Error
+	if !errors.As(outer, &e) || e.op != "load config" {
+		t.Errorf("errors.As found %v, want the outermost *Error", e)
+	}
+
+	if Wrap(nil, "nothing") != nil {
+		t.Error("Wrap(nil) isn't nil")
+	}
+	if got := New("parse", "unexpected EOF", "line", 3).Error(); got != "parse: unexpected EOF" {
+		t.Errorf("New = %q", got)
+	}
+}
+
+func TestStackOf(t *testing.T) {
+	plain := Wrap(errors.New("boom"), "op")
+	if StackOf(plain) != nil {
+		t.Error("an error that never had WithStack has a stack")
+	}
+
+	err := Wrap(WithStack(errors.New("boom")), "outer")
+	frames := StackOf(err)
+	if len(frames) == 0 {
+		t.Fatal("WithStack didn't record a stack")
+	}
+	// the first frame is whoever called WithStack
+	if !strings.HasSuffix(frames[0].Function, "TestStackOf") {
+		t.Errorf("innermost frame is %s, want TestStackOf", frames[0].Function)
+	}
+	for _, f := range frames {
+		if strings.HasPrefix(f.Function, "runtime.") {
+			t.Errorf("runtime frame %s left in", f.Function)
+		}
+	}
+	// a second WithStack keeps the deeper stack instead of recording a new one
+	if again := WithStack(err); again != err {
+		t.Error("WithStack on an error with a stack wrapped it again")
+	}
+
+	verbose := fmt.Sprintf("%+v", err)
+	if !strings.Contains(verbose, "at ") || !strings.Contains(verbose, "TestStackOf") {
+		t.Errorf("%%+v has no stack:\n%s", verbose)
+	}
+	if got := fmt.Sprintf("%v", err); got != "outer: boom" {
+		t.Errorf("%%v = %q, want just the message", got)
+	}
+}
+
+func TestJoin(t *testing.T) {
+	if Join(nil, nil) != nil {
+		t.Error("Join of nothing isn't nil")
+	}
+	single := errors.New("only")
+	if Join(nil, single) != single {
+		t.Error("Join of one error should be that error")
+	}
+
+	nested := Join(errors.New("disk full"), Wrap(fs.ErrPermission, "chmod", "path", "/tmp/x"))
+	err := Join(errors.New("first"), nested)
+	want := "2 errors:\n  * first\n  * 2 errors:\n      * disk full\n      * chmod: permission denied"
+	if err.Error() != want {
+		t.Errorf("Error =\n%s\nwant\n%s", err.Error(), want)
+	}
+	if got := fmt.Sprintf("%v", err); got != want {
+		t.Errorf("%%v =\n%s\nwant the same as Error", got)
+	}
+
+	verbose := fmt.Sprintf("%+v", err)
+	wantVerbose := "2 errors:\n  first\n  2 errors:\n    disk full\n    chmod: permission denied\n        path=/tmp/x\n"
+	if verbose != wantVerbose {
+		t.Errorf("%%+v =\n%s\nwant\n%s", verbose, wantVerbose)
+	}
+
+	// errors.Is and errors.As look inside, however deep
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Error("errors.Is didn't find fs.ErrPermission in the join")
+	}
+	var e *Error
+	if !errors.As(err, &e) || e.op != "chmod" {
+		t.Errorf("errors.As found %v in the join", e)
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Error("errors.Is found an error that isn't there")
+	}
+}
+
+func TestAttr(t *testing.T) {
+	err := Wrap(Wrap(errors.New("refused"), "dial", "host", "db"), "query")
+	var buf bytes.Buffer
+	slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})).Error("failed", Attr("error", err))
+
+	want := `level=ERROR msg=failed error.msg="query: dial: refused" error.op="query > dial" error.host=db` + "\n"
+	if buf.String() != want {
+		t.Errorf("logged\n%s\nwant\n%s", buf.String(), want)
+	}
+}
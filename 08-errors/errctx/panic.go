@@ -0,0 +1,52 @@
+package errctx
+
+import (
+	"fmt"
+	"io"
+	"runtime/debug"
+)
+
+// PanicError is a panic turned back into an ordinary error, with the stack of the goroutine that panicked
+// By the time anyone reads the error that goroutine has unwound, so the stack has to be taken in Recover
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", p.Value)
+}
+
+// Unwrap exposes the panic value when it was an error, so errors.Is still finds, say, a runtime.Error
+func (p *PanicError) Unwrap() error {
+	err, _ := p.Value.(error)
+	return err
+}
+
+// Format adds the stack for %+v
+func (p *PanicError) Format(f fmt.State, verb rune) {
+	io.WriteString(f, p.Error())
+	if verb == 'v' && f.Flag('+') {
+		fmt.Fprintf(f, "\n%s", p.Stack)
+	}
+}
+
+// Recover turns a panic into an error in *errp. recover only works when called by the deferred
+// function itself, so Recover has to be deferred directly, not from inside another func literal
+//
+//	func risky() (err error) {
+//		defer errctx.Recover(&err)
+//		...
+//	}
+func Recover(errp *error) {
+	// You can listen in on a panic by using
+	if v := recover(); v != nil {
+		*errp = &PanicError{Value: v, Stack: debug.Stack()}
+	}
+}
+
+// Catch runs fn and returns its error, or the panic it raised as a *PanicError
+func Catch(fn func() error) (err error) {
+	defer Recover(&err)
+	return fn()
+}
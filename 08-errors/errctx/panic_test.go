@@ -0,0 +1,42 @@
+package errctx
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestPanicError(t *testing.T) {
+	err := Catch(func() error {
+		var m map[string]int
+		m["boom"]++
+		return nil
+	})
+	var p *PanicError
+	if !errors.As(err, &p) {
+		t.Fatalf("Catch returned %v, want a *PanicError", err)
+	}
+	// the runtime's own panic value is an error, and stays findable underneath
+	var re runtime.Error
+	if !errors.As(err, &re) {
+		t.Error("errors.As didn't find the runtime.Error")
+	}
+	if got := fmt.Sprintf("%v", err); got != "panic: assignment to entry in nil map" {
+		t.Errorf("%%v = %q", got)
+	}
+	verbose := fmt.Sprintf("%+v", err)
+	if !strings.Contains(verbose, "goroutine") || !strings.Contains(verbose, "panic_test.go") {
+		t.Errorf("%%+v has no stack:\n%s", verbose)
+	}
+
+	// a panic with a plain value has nothing to unwrap
+	err = Catch(func() error { panic("config file is missing") })
+	if !errors.As(err, &p) || p.Value != "config file is missing" || errors.Unwrap(p) != nil {
+		t.Errorf("Catch of a string panic = %v", err)
+	}
+	if err := Catch(func() error { return nil }); err != nil {
+		t.Errorf("Catch without a panic = %v", err)
+	}
+}
@@ -1,3 +1,5 @@
 module learninggo/errors
 
-go 1.22.2
+go 1.25.0
+
+require golang.org/x/crypto v0.54.0
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"learninggo/errors/errctx"
+	"log/slog"
+	"net"
+	"net/http"
+)
+
+type loginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type loginResponse struct {
+	Token     string `json:"token"`
+	TokenType string `json:"tokenType"`
+}
+
+// Credentials are tiny, anything bigger is someone trying to make us buffer it
+const maxLoginBody = 4 << 10
+
+// badCredentials is the one answer for an unknown user, a wrong password and a locked account. Telling
+// them apart would let anyone find out who has an account by trying names, and only real accounts can
+// get locked. The Authenticator checks a hash in all three cases, a dummy one for a missing user, so they
+// take as long as each other and the timing doesn't tell them apart either. Telling an owner their account is locked is for a channel only
+// they can read, like their email
+var badCredentials = StatusErr{message: "invalid username or password", status: InvalidKey}
+
+// LoginHandler answers POST /login. Credentials come in as JSON, a token goes back as JSON, and every
+// failure is a problem details body with the matching status code
+func LoginHandler(guard *LoginGuard) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req loginRequest
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxLoginBody))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			WriteProblem(w, r, StatusErr{message: "body must be JSON with a username and password", status: InvalidRequest, cause: err})
+			return
+		}
+		if req.Username == "" || req.Password == "" {
+			WriteProblem(w, r, StatusErr{message: "username and password are both required", status: InvalidRequest})
+			return
+		}
+
+		// RemoteAddr is the only source we can trust. X-Forwarded-For is whatever the client wants it to be
+		source, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			source = r.RemoteAddr
+		}
+		token, err := guard.LoginUser(req.Username, req.Password, source)
+		switch {
+		case err == nil:
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Cache-Control", "no-store")
+			json.NewEncoder(w).Encode(loginResponse{Token: token, TokenType: "Bearer"})
+		case errors.Is(err, StatusErr{status: UserNotFound}), errors.Is(err, StatusErr{status: InvalidKey}),
+			errors.Is(err, StatusErr{status: AccountLocked}):
+			WriteProblem(w, r, badCredentials)
+		default:
+			if StatusOf(err) == Internal {
+				slog.Error("login failed", errctx.Attr("error", err))
+			}
+			WriteProblem(w, r, err)
+		}
+	})
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newLoginServer mounts LoginHandler the way main does, so the method check is the mux's
+func newLoginServer(t *testing.T, store UserStore, perSource *RateLimiter) http.Handler {
+	t.Helper()
+	tokens, err := NewTokenService("test", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens.AddKey("test", NewKey())
+	auth, err := NewAuthenticator(store, BcryptHasher{Cost: bcrypt.MinCost}, tokens, 2, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.FindUser("Leo"); errors.Is(err, ErrNoSuchUser) {
+		if err := auth.Register("Leo", "open sesame"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mux := http.NewServeMux()
+	mux.Handle("POST /login", LoginHandler(newTestGuard(t, auth, newTestLimiter(t, time.Hour, 100), perSource)))
+	return mux
+}
+
+func postLogin(h http.Handler, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body)))
+	return w
+}
+
+func TestLoginHandlerSuccess(t *testing.T) {
+	h := newLoginServer(t, NewMemoryUserStore(), newTestLimiter(t, time.Hour, 100))
+	w := postLogin(h, `{"username": "Leo", "password": "open sesame"}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"token"`) {
+		t.Fatalf("got %d %s, want 200 with a token", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("Cache-Control = %q, a token must not be cached", got)
+	}
+}
+
+// Who has an account must not be readable from the answers. An unknown name, a wrong password and a
+// locked account all get exactly the same response
+func TestLoginHandlerBadCredentialsLookAlike(t *testing.T) {
+	h := newLoginServer(t, NewMemoryUserStore(), newTestLimiter(t, time.Hour, 100))
+	unknown := postLogin(h, `{"username": "Nobody", "password": "open sesame"}`)
+	wrong := postLogin(h, `{"username": "Leo", "password": "open says me"}`)
+	postLogin(h, `{"username": "Leo", "password": "open says me"}`) // second failure locks Leo
+	locked := postLogin(h, `{"username": "Leo", "password": "open sesame"}`)
+
+	if unknown.Code != http.StatusUnauthorized {
+		t.Errorf("unknown user got %d, want 401", unknown.Code)
+	}
+	for name, w := range map[string]*httptest.ResponseRecorder{"wrong password": wrong, "locked account": locked} {
+		if w.Code != unknown.Code || w.Body.String() != unknown.Body.String() {
+			t.Errorf("%s got %d %s, unknown user got %d %s", name, w.Code, w.Body, unknown.Code, unknown.Body)
+		}
+	}
+}
+
+// countingHasher counts the hashes checked. Checking one is the slow part of a login, so the count is what
+// the response time gives away
+type countingHasher struct {
+	PasswordHasher
+	verified *atomic.Int32
+}
+
+func (h countingHasher) Verify(hash, password string) (bool, error) {
+	h.verified.Add(1)
+	return h.PasswordHasher.Verify(hash, password)
+}
+
+// A locked account is answered exactly like a wrong password, and takes as long: a hash is checked
+// either way, whether the password is right or not
+func TestLoginHandlerLockedLooksLikeWrongPassword(t *testing.T) {
+	hasher := countingHasher{PasswordHasher: BcryptHasher{Cost: bcrypt.MinCost}, verified: new(atomic.Int32)}
+	auth := newTestAuthenticator(t, hasher, 2)
+	if err := auth.Register("Leo", "open sesame"); err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("POST /login", LoginHandler(newTestGuard(t, auth, newTestLimiter(t, time.Hour, 100), newTestLimiter(t, time.Hour, 100))))
+
+	wrong := postLogin(mux, `{"username": "Leo", "password": "open says me"}`)
+	postLogin(mux, `{"username": "Leo", "password": "open says me"}`) // second failure locks Leo
+	for name, body := range map[string]string{
+		"wrong password": `{"username": "Leo", "password": "open says me"}`,
+		"right password": `{"username": "Leo", "password": "open sesame"}`,
+	} {
+		before := hasher.verified.Load()
+		w := postLogin(mux, body)
+		if w.Code != wrong.Code || w.Body.String() != wrong.Body.String() {
+			t.Errorf("locked, %s got %d %s, a wrong password got %d %s", name, w.Code, w.Body, wrong.Code, wrong.Body)
+		}
+		if n := hasher.verified.Load() - before; n != 1 {
+			t.Errorf("locked, %s checked %d hashes, a wrong password checks 1", name, n)
+		}
+	}
+}
+
+func TestLoginHandlerBadRequest(t *testing.T) {
+	h := newLoginServer(t, NewMemoryUserStore(), newTestLimiter(t, time.Hour, 100))
+	for name, body := range map[string]string{
+		"not JSON":         `not json`,
+		"no password":      `{"username": "Leo"}`,
+		"no username":      `{"password": "open sesame"}`,
+		"empty":            `{}`,
+		"unexpected field": `{"username": "Leo", "password": "open sesame", "admin": true}`,
+		"too big":          `{"username": "` + strings.Repeat("a", maxLoginBody) + `", "password": "x"}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := postLogin(h, body)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("got %d %s, want 400", w.Code, w.Body)
+			}
+			if got := w.Header().Get("Content-Type"); got != "application/problem+json" {
+				t.Errorf("Content-Type = %q, want problem details", got)
+			}
+		})
+	}
+}
+
+func TestLoginHandlerRateLimited(t *testing.T) {
+	h := newLoginServer(t, NewMemoryUserStore(), newTestLimiter(t, time.Minute, 1))
+	postLogin(h, `{"username": "Leo", "password": "open says me"}`)
+	w := postLogin(h, `{"username": "Leo", "password": "open sesame"}`)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("got %d %s, want 429", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("Retry-After = %q, want 60", got)
+	}
+}
+
+func TestLoginHandlerOnlyPost(t *testing.T) {
+	h := newLoginServer(t, NewMemoryUserStore(), newTestLimiter(t, time.Hour, 100))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET got %d, want 405", w.Code)
+	}
+	if got := w.Header().Get("Allow"); got != http.MethodPost {
+		t.Errorf("Allow = %q, want POST", got)
+	}
+}
+
+// brokenStore fails the way a real database does, with details nobody outside should see
+type brokenStore struct {
+	*MemoryUserStore
+}
+
+const storeSecret = "dial tcp 10.0.3.7:5432: connection refused"
+
+func (brokenStore) FindUser(string) (User, error) {
+	return User{}, errors.New(storeSecret)
+}
+
+func TestLoginHandlerInternalErrorLeaksNothing(t *testing.T) {
+	// the real error is logged, keep it out of the test output
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	store := brokenStore{NewMemoryUserStore()}
+	h := newLoginServer(t, store, newTestLimiter(t, time.Hour, 100))
+	w := postLogin(h, `{"username": "Leo", "password": "open sesame"}`)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("got %d %s, want 500", w.Code, w.Body)
+	}
+	for _, secret := range []string{"10.0.3.7", "connection refused", "dial tcp"} {
+		if strings.Contains(w.Body.String(), secret) {
+			t.Errorf("body %s gives away %q", w.Body, secret)
+		}
+	}
+}
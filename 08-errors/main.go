@@ -3,31 +3,46 @@ package main
 import (
 	"archive/zip"
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"learninggo/errors/errctx"
+	"learninggo/errors/result"
+	"learninggo/errors/safezip"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 func calcDivision(a, b int) (exp int, rem int, err error) {
 	if b == 0 {
 		// Ideally, you should return zero values when we return an error
-		return exp, rem, errors.New("cannot divide by zero")
+		// errctx remembers which operation failed and with what, a bare "cannot divide by zero" doesn't say
+		return exp, rem, errctx.New("calcDivision", "cannot divide by zero", "a", a, "b", b)
 	}
 	return a / b, a % b, nil
 }
 
-func madeToPanic() {
+func madeToPanic() (err error) {
 	// A panic is like throwing errors in many languages
 	// A panic can be raised by the Go runtime, but you can also call panic() to exit immediately
 	// The moment that a program panics the executing function will stop further execution, and will execute all the
 	// defer functions is order
 	// Then it will go up the call stack and execute defer too, until it reaches main, where it will call the defer
 	// function there too and quit
-	defer func() {
-		// You can listen in on a panic by using
-		if v := recover(); v != nil {
-			fmt.Println("You stopped this func")
-		}
-	}()
+	// Recover listens in on the panic and hands it back as err, value and stack included, see errctx/panic.go
+	defer errctx.Recover(&err)
 
 	// The general advice is not to use this pattern for exception handling. Instead, use method below
 	// Panic is reserved for cases where the program cannot recover from
@@ -35,69 +50,211 @@ func madeToPanic() {
 	panic("I'm made to panic")
 }
 
-// defining error states
-
-type Status int
-
-const (
-	InvalidKey Status = iota
-	UserNotFound
-)
-
-type StatusErr struct {
-	message string
-	status  Status
-}
-
-// To define a struct as an error.
-// It needs to implement the Error interface by implementing an Error() function.
-func (s StatusErr) Error() string {
-	return s.message
-}
+// demoArchives round trips a directory through a zip, then feeds Extract the archives an attacker would
+func demoArchives() error {
+	src, err := os.MkdirTemp("", "src")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(src)
+	os.MkdirAll(filepath.Join(src, "docs"), 0o755)
+	os.WriteFile(filepath.Join(src, "README.md"), []byte(strings.Repeat("hello zip\n", 20)), 0o644)
+	os.WriteFile(filepath.Join(src, "docs", "guide.txt"), []byte("read me first"), 0o644)
+	os.Symlink("docs/guide.txt", filepath.Join(src, "GUIDE"))
 
-// We need to implement this method to be able to compare errors using errors.Is(a, b)
-func (s StatusErr) Is(err error) bool {
-	statusErr := err.(StatusErr)
-	return statusErr.status == s.status
-}
+	var archive bytes.Buffer
+	if err := safezip.Create(&archive, src); err != nil {
+		return err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil {
+		return err
+	}
+	for _, e := range safezip.List(zr) {
+		fmt.Printf("%-16s %v %4d bytes, %.1fx\n", e.Name, e.Mode, e.Size, e.Ratio())
+	}
+	if err := safezip.Verify(zr, safezip.DefaultLimits); err != nil {
+		return err
+	}
+	dest, err := os.MkdirTemp("", "dest")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dest)
+	opts := safezip.Options{Limits: safezip.DefaultLimits, Symlinks: safezip.AllowContainedSymlinks}
+	if err := safezip.Extract(zr, dest, opts); err != nil {
+		return err
+	}
+	guide, _ := os.ReadFile(filepath.Join(dest, "GUIDE"))
+	fmt.Printf("Extracted, and the symlink still works: %q\n", guide)
 
-func findUserByUsername(user string) (string, error) {
-	return "", errors.New("cannot find user")
-}
+	// Now the hostile ones
+	build := func(name string, method uint16, mode fs.FileMode, content []byte) []byte {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		header := &zip.FileHeader{Name: name, Method: method}
+		header.SetMode(mode)
+		w, _ := zw.CreateHeader(header)
+		w.Write(content)
+		zw.Close()
+		return buf.Bytes()
+	}
+	read := func(b []byte) *zip.Reader {
+		zr, _ := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+		return zr
+	}
+	for _, bad := range []struct {
+		name    string
+		archive []byte
+	}{
+		{"zip slip", build("../../etc/cron.d/evil", zip.Deflate, 0o644, []byte("* * * * * root rm -rf /"))},
+		{"zip bomb", build("zeros.bin", zip.Deflate, 0o644, make([]byte, 10<<20))},
+		{"escaping symlink", build("passwd", zip.Store, fs.ModeSymlink|0o777, []byte("../../../etc/passwd"))},
+	} {
+		empty, err := os.MkdirTemp("", "dest")
+		if err != nil {
+			return err
+		}
+		err = safezip.Extract(read(bad.archive), empty, opts)
+		os.RemoveAll(empty)
+		var entryErr *safezip.EntryError
+		if errors.As(err, &entryErr) {
+			fmt.Printf("Refused the %s, entry %q: %v\n", bad.name, entryErr.Name, entryErr.Err)
+		}
+	}
 
-func checkPassword(pass string) (string, error) {
-	return "", errors.New("incorrect password")
+	// Flip a byte of the stored data and it no longer matches its CRC
+	tampered := build("notes.txt", zip.Store, 0o644, []byte("meet at noon"))
+	offset, _ := read(tampered).File[0].DataOffset()
+	tampered[offset] ^= 0xff
+	if err := safezip.Verify(read(tampered), safezip.DefaultLimits); errors.Is(err, safezip.ErrChecksum) {
+		fmt.Println("Verify caught it:", err)
+	}
+	return nil
 }
 
-func grantToken(user string) string {
-	return "dasdsvsdvsewefewf"
-}
+// demoLoginServer runs /login on a real local server and logs in over HTTP the way a frontend would
+func demoLoginServer(tokens *TokenService) error {
+	auth, err := NewAuthenticator(NewMemoryUserStore(), BcryptHasher{Cost: bcrypt.MinCost}, tokens, 3, 15*time.Minute)
+	if err != nil {
+		return err
+	}
+	auth.Register("Leo", "open sesame")
+	// a fresh directory of our own, a fixed name in the shared temp dir could be someone else's file
+	// or another run of the demo
+	dir, err := os.MkdirTemp("", "learninggo-login-demo-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	audit, err := OpenAuditLog(filepath.Join(dir, "audit.log"), 1<<20, 1)
+	if err != nil {
+		return err
+	}
+	defer audit.Close()
+	perUser, err := NewRateLimiter(time.Second, 10)
+	if err != nil {
+		return err
+	}
+	perSource, err := NewRateLimiter(time.Second, 10)
+	if err != nil {
+		return err
+	}
 
-func LoginUser(user, pass string) (string, error) {
+	mux := http.NewServeMux()
+	mux.Handle("POST /login", LoginHandler(NewLoginGuard(auth, perUser, perSource, audit)))
+	server := httptest.NewServer(RecoverHandler(mux, func(p *errctx.PanicError) { fmt.Printf("%+v\n", p) }))
+	defer server.Close()
 
-	_, err := findUserByUsername(user)
-	if err != nil {
-		return "", StatusErr{
-			message: "cannot find user",
-			status:  UserNotFound,
+	var replies []string
+	for _, body := range []string{
+		`{"username": "Leo", "password": "open sesame"}`,
+		`{"username": "Leo", "password": "open says me"}`,
+		`{"username": "Nobody", "password": "open sesame"}`,
+		`{"username": "Leo"}`,
+		`not json`,
+	} {
+		resp, err := http.Post(server.URL+"/login", "application/json", strings.NewReader(body))
+		if err != nil {
+			return err
 		}
-	}
-	_, passErr := checkPassword(pass)
-	if passErr != nil {
-		return "", StatusErr{
-			message: "password incorrect",
-			status:  InvalidKey,
+		reply, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		replies = append(replies, string(reply))
+		if len(reply) > 60 {
+			reply = append(reply[:60], "..."...)
 		}
+		fmt.Printf("%-50s %d %s\n", body, resp.StatusCode, bytes.TrimSpace(reply))
+	}
+	fmt.Println("Wrong password and unknown user get the same answer:", replies[1] == replies[2])
+
+	resp, err := http.Get(server.URL + "/login")
+	if err != nil {
+		return err
 	}
-	return grantToken(user), nil
+	resp.Body.Close()
+	fmt.Println("GET /login", resp.StatusCode, "allowed:", resp.Header.Get("Allow"))
+	return nil
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "audit" {
+		if err := runAudit(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(ExitCode(StatusErr{message: err.Error(), status: InvalidRequest}))
+		}
+		return
+	}
 
 	_, _, err := calcDivision(3, 0)
 	if err != nil {
 		fmt.Println("This is how error handling is done", err)
 	}
+	// Further up, each caller adds what it knows. %+v prints all of it, one field per line
+	err = errctx.WithStack(errctx.Wrap(err, "split bill", "diners", 0))
+	fmt.Printf("%+v", err)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	logger.Error("cannot split the bill", errctx.Attr("error", err))
+
+	// A pipeline checks once at the end instead of after every step. Whichever step fails first,
+	// that's the error that comes out, and nothing after it runs
+	splitBill := func(bill string) result.Result[int] {
+		total, diners, _ := strings.Cut(bill, "/")
+		parsed := result.Collect(result.Of(strconv.Atoi(total)), result.Of(strconv.Atoi(diners)))
+		divided := result.Then(parsed, func(n []int) (result.Pair[int, int], error) {
+			return result.Of2(calcDivision(n[0], n[1])).Get()
+		})
+		share := result.Map(divided, func(p result.Pair[int, int]) int { return p.First })
+		return share.Wrap("split bill", "bill", bill)
+	}
+	for _, bill := range []string{"120/4", "90/0", "ten/2"} {
+		fmt.Println(bill, "->", splitBill(bill))
+	}
+	shares := result.MapEach([]string{"120/4", "60/3", "ten/2"}, func(bill string) (int, error) {
+		return splitBill(bill).Get()
+	})
+	fmt.Println("Every bill:", shares, "bad number:", errors.Is(shares.Err(), strconv.ErrSyntax))
+	fmt.Println("Try:", result.Try(func() (int, error) { return []int{}[2], nil }))
+	fmt.Println("Must:", result.Must(strconv.Atoi("42")))
+
+	// Validation wants every problem at once, not just the first one
+	checkOrder := func(qty int, sku, email string) error {
+		var errs []error
+		if qty <= 0 {
+			errs = append(errs, errctx.New("check order", "quantity must be positive", "qty", qty))
+		}
+		if sku == "" {
+			errs = append(errs, errctx.New("check order", "missing sku"))
+		}
+		if !strings.Contains(email, "@") {
+			errs = append(errs, errctx.New("check order", "invalid email", "email", email))
+		}
+		return errctx.Join(errs...)
+	}
+	if err := checkOrder(0, "", "leo.example.com"); err != nil {
+		fmt.Println(err)
+		fmt.Printf("%+v", err)
+	}
 
 	data := []byte("Some random statement")
 	notZipFl := bytes.NewReader(data)
@@ -106,11 +263,207 @@ func main() {
 	if err2 == zip.ErrFormat {
 		fmt.Println("Should panic")
 	}
+	if err := demoArchives(); err != nil {
+		fmt.Println("Archive demo failed:", err)
+	}
 
-	_, err3 := LoginUser("Leo", "12345")
+	// a real hasher takes most of a second per password on purpose, far too slow for a demo
+	tokens, err := NewTokenService("learninggo", time.Hour)
+	if err != nil {
+		fmt.Println("Couldn't set up tokens:", err)
+		return
+	}
+	tokens.AddKey("2024-01", NewKey())
+	auth, err := NewAuthenticator(NewMemoryUserStore(), BcryptHasher{Cost: bcrypt.MinCost}, tokens, 3, 15*time.Minute)
+	if err != nil {
+		fmt.Println("Couldn't set up logins:", err)
+		return
+	}
+	auth.Register("Mary", "correct horse", "fleet:read", "fleet:write")
+
+	_, err3 := auth.LoginUser("Leo", "12345")
 	if errors.Is(err3, StatusErr{status: UserNotFound}) {
 		fmt.Println("Couldn't find user")
 	}
+	// Statuses know their own names now, handy for logs
+	var se StatusErr
+	if errors.As(err3, &se) {
+		fmt.Println("Login failed with status:", se.status)
+	}
+
+	for _, guess := range []string{"123456", "password", "qwerty", "correct horse"} {
+		if _, err := auth.LoginUser("Mary", guess); err != nil {
+			fmt.Printf("Mary with %q: %v\n", guess, err)
+		}
+	}
+	// fifteen minutes later
+	auth.now = func() time.Time { return time.Now().Add(15 * time.Minute) }
+	token, err := auth.LoginUser("Mary", "correct horse")
+	if err != nil {
+		fmt.Println("Mary is still locked out:", err)
+		return
+	}
+	fmt.Println("Mary is back in with", token)
+	if claims, err := tokens.VerifyToken(token); err == nil {
+		fmt.Println("Token is for", claims.Subject, "who can write:", claims.HasScope("fleet:write"))
+	}
+
+	// Rotate: sign with a new key, keep accepting the old one until its tokens have expired
+	key := NewKey()
+	tokens.AddKey("2024-02", key)
+	if _, err := tokens.VerifyToken(token); err == nil {
+		fmt.Println("Mary's token survived the rotation")
+	}
+	tokens.RetireKey("2024-01")
+
+	head, _, _ := strings.Cut(token, ".")
+	fresh, _ := tokens.Issue("Mary")
+	// a service whose clock is two hours behind issues tokens that ran out an hour ago
+	expiring, _ := NewTokenService("learninggo", time.Hour)
+	expiring.now = func() time.Time { return time.Now().Add(-2 * time.Hour) }
+	expiring.AddKey("2024-02", key)
+	expired, _ := expiring.Issue("Mary")
+	for _, bad := range []struct{ name, token string }{
+		{"old key", token},
+		{"tampered", fresh[:len(fresh)-2] + "xx"},
+		{"garbage", head + ".not-a-token"},
+		{"expired", expired},
+	} {
+		_, err := tokens.VerifyToken(bad.token)
+		if errors.As(err, &se) {
+			fmt.Printf("%s token: %s (%v)\n", bad.name, se.status, err)
+		}
+	}
+
+	// Mary's password gets guessed at from one machine, then a botnet tries her account from many
+	dir, err := os.MkdirTemp("", "users")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	auditPath := filepath.Join(dir, "audit.log")
+	audit, err := OpenAuditLog(auditPath, 1024, 3)
+	if err != nil {
+		fmt.Println("Couldn't open the audit log:", err)
+		return
+	}
+	defer audit.Close()
+	perUser, err := NewRateLimiter(time.Minute, 5)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	perSource, err := NewRateLimiter(time.Minute, 3)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	guard := NewLoginGuard(auth, perUser, perSource, audit)
+	for i := range 8 {
+		source := "10.0.0.1"
+		if i >= 3 {
+			source = fmt.Sprintf("192.168.0.%d", i)
+		}
+		_, err := guard.LoginUser("Mary", "password"+fmt.Sprint(i), source)
+		if errors.As(err, &se) && se.status == RateLimited {
+			fmt.Printf("Attempt %d from %s: %v, retry in %v\n", i, source, err, se.RetryAfter().Round(time.Second))
+		}
+	}
+	guard.LoginUser("Kunta", "letmein", "10.0.0.9")
+	rec := httptest.NewRecorder()
+	_, err = guard.LoginUser("Mary", "correct horse", "10.0.0.1")
+	WriteProblem(rec, httptest.NewRequest("POST", "/login", nil), err)
+	fmt.Print("Limited client gets ", rec.Code, " Retry-After: ", rec.Header().Get("Retry-After"), " ", rec.Body.String())
+
+	logs, _ := filepath.Glob(auditPath + "*")
+	fmt.Println("Audit log files:", len(logs))
+	// same as: go run . audit -log <path> -user Mary -outcome limited
+	runAudit([]string{"-log", auditPath, "-user", "Mary", "-outcome", "limited"}, os.Stdout)
 
-	madeToPanic()
+	if err := demoLoginServer(tokens); err != nil {
+		fmt.Println("Login server demo failed:", err)
+	}
+
+	// The same thing kept on disk
+	path := filepath.Join(dir, "users.json")
+	first, _ := NewAuthenticator(NewFileUserStore(path), BcryptHasher{Cost: bcrypt.MinCost}, tokens, 3, 15*time.Minute)
+	first.Register("Kate", "hunter2")
+	if err := first.Register("Kate", "again"); errors.Is(err, ErrUserExists) {
+		fmt.Println("Kate is already registered")
+	}
+	// a fresh Authenticator only shares the file, so this proves Kate was saved
+	second, _ := NewAuthenticator(NewFileUserStore(path), BcryptHasher{Cost: bcrypt.MinCost}, tokens, 3, 15*time.Minute)
+	if _, err := second.LoginUser("Kate", "hunter2"); err == nil {
+		fmt.Println("Kate logged in from", filepath.Base(path))
+	}
+
+	// Someone mangles the file. That's not Kate's fault, so it's Internal, but the real error is still there
+	os.WriteFile(path, []byte("{not json"), 0o600)
+	_, err = second.LoginUser("Kate", "hunter2")
+	var syntaxErr *json.SyntaxError
+	fmt.Println("Login:", err)
+	fmt.Println("Status", StatusOf(err), "exit code", ExitCode(err), "JSON to blame:", errors.As(err, &syntaxErr))
+	// this used to panic, the old Is assumed every error it was asked about was a StatusErr
+	fmt.Println("Is it EOF?", errors.Is(err, io.EOF))
+	problem, _ := json.Marshal(ProblemFor(err, "/login"))
+	fmt.Println("What the client sees", string(problem))
+
+	for _, status := range StatusValues() {
+		fmt.Printf("%-20s HTTP %d exit %d\n", status, status.HTTPStatus(), status.ExitCode())
+	}
+
+	if err := madeToPanic(); err != nil {
+		fmt.Println("You stopped this func:", err)
+	}
+
+	// A panic in a goroutine can't be recovered by whoever started it, it takes the program down
+	var wg sync.WaitGroup
+	wg.Add(1)
+	SafeGo(func() {
+		var m map[string]int
+		m["boom"]++ // writing to a nil map panics
+	}, func(p *errctx.PanicError) {
+		defer wg.Done()
+		fmt.Println("A goroutine crashed:", p)
+	})
+	wg.Wait()
+
+	handler := RecoverHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var users []string
+		fmt.Fprint(w, users[3])
+	}), func(p *errctx.PanicError) {
+		fmt.Println("Handler crashed:", p)
+	})
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/users/3", nil))
+	fmt.Print("Client got ", rec.Code, " ", rec.Body.String())
+
+	// One worker crashes for a while then settles down, the other never does and is given up on
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	supervisor := &Supervisor{
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 80 * time.Millisecond,
+		Budget:     4,
+		Window:     time.Second,
+		OnCrash: func(name string, err error, wait time.Duration) {
+			fmt.Printf("%s crashed (%v), restarting in %v\n", name, err, wait)
+		},
+	}
+	flakyRuns := 0
+	supervisor.Go(ctx, "flaky", func(ctx context.Context) error {
+		flakyRuns++
+		if flakyRuns < 3 {
+			return errors.New("lost the database connection")
+		}
+		<-ctx.Done()
+		return nil
+	})
+	supervisor.Go(ctx, "doomed", func(ctx context.Context) error {
+		panic("config file is missing")
+	})
+	if err := supervisor.Wait(); err != nil {
+		fmt.Println("Supervisor gave up:", err)
+	}
 }
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"learninggo/errors/errctx"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SafeGo starts fn in a goroutine. A panic in a goroutine nobody recovers kills the whole program,
+// SafeGo hands it to report instead
+func SafeGo(fn func(), report func(*errctx.PanicError)) {
+	go func() {
+		err := errctx.Catch(func() error {
+			fn()
+			return nil
+		})
+		var p *errctx.PanicError
+		if errors.As(err, &p) {
+			report(p)
+		}
+	}()
+}
+
+// RecoverHandler is middleware that answers a panicking handler with a 500 problem instead of
+// net/http's default of dropping the connection. If the handler already started writing its
+// response there's nothing better we can do than cut it short. A problem tacked on after half a
+// response would read to the client as part of it, so that case aborts the connection instead
+func RecoverHandler(next http.Handler, report func(*errctx.PanicError)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tw := &trackingWriter{ResponseWriter: w}
+		err := errctx.Catch(func() error {
+			next.ServeHTTP(tw, r)
+			return nil
+		})
+		var p *errctx.PanicError
+		if !errors.As(err, &p) {
+			return
+		}
+		// ErrAbortHandler is how a handler asks net/http to abort quietly, it isn't a crash
+		if errors.Is(p, http.ErrAbortHandler) {
+			panic(http.ErrAbortHandler)
+		}
+		report(p)
+		if tw.wrote {
+			panic(http.ErrAbortHandler)
+		}
+		WriteProblem(w, r, StatusErr{message: "the server hit a problem", status: Internal, cause: p})
+	})
+}
+
+// trackingWriter remembers whether the response has started. Once the status line is out it can't be changed
+type trackingWriter struct {
+	http.ResponseWriter
+	wrote bool
+}
+
+func (w *trackingWriter) WriteHeader(code int) {
+	w.wrote = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *trackingWriter) Write(b []byte) (int, error) {
+	w.wrote = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush sends the headers too. Handlers that stream check for http.Flusher, so it has to be on the wrapper
+func (w *trackingWriter) Flush() {
+	w.wrote = true
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the writer underneath for everything else
+func (w *trackingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+//////////////////////////////////////////////////////////////////////
+//                     Supervisor                                   //
+//////////////////////////////////////////////////////////////////////
+
+// Worker runs until ctx is cancelled. Returning nil means it finished its work, an error or a panic
+// means it crashed and should be restarted
+type Worker func(ctx context.Context) error
+
+var ErrRestartBudget = errors.New("restart budget exhausted")
+
+// Supervisor restarts crashed workers, waiting longer after every crash in a row, from MinBackoff
+// up to MaxBackoff. A worker that crashes more than Budget times within Window is given up on,
+// something is wrong that restarting won't fix
+//
+// A field left at 0 gets its default below, so the zero Supervisor is ready to use. Taken literally a
+// 0 would be no use anyway: no wait at all restarts in a tight loop, and no budget gives up on the first crash
+type Supervisor struct {
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	Budget     int
+	Window     time.Duration
+	// OnCrash hears about every crash, with how long the supervisor will wait before the restart
+	OnCrash func(name string, err error, wait time.Duration)
+
+	wg   sync.WaitGroup
+	mu   sync.Mutex
+	errs []error
+}
+
+const (
+	defaultMinBackoff = 100 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+	defaultBudget     = 5
+	defaultWindow     = time.Minute
+)
+
+// settings is s with the defaults filled in. MaxBackoff below MinBackoff would make the doubling wait
+// shorter than the first one, so it's raised to MinBackoff
+func (s *Supervisor) settings() (minBackoff, maxBackoff time.Duration, budget int, window time.Duration) {
+	minBackoff, maxBackoff, budget, window = s.MinBackoff, s.MaxBackoff, s.Budget, s.Window
+	if minBackoff <= 0 {
+		minBackoff = defaultMinBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+	if budget <= 0 {
+		budget = defaultBudget
+	}
+	if window <= 0 {
+		window = defaultWindow
+	}
+	return minBackoff, max(maxBackoff, minBackoff), budget, window
+}
+
+// Run supervises one worker until it finishes, ctx is cancelled, or it runs out of restarts
+func (s *Supervisor) Run(ctx context.Context, name string, w Worker) error {
+	minBackoff, maxBackoff, budget, window := s.settings()
+	var crashes []time.Time
+	backoff := minBackoff
+	for {
+		started := time.Now()
+		err := errctx.Catch(func() error { return w(ctx) })
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil {
+			return nil
+		}
+
+		now := time.Now()
+		// only crashes inside the window count against the budget
+		recent := crashes[:0]
+		for _, t := range crashes {
+			if now.Sub(t) < window {
+				recent = append(recent, t)
+			}
+		}
+		crashes = append(recent, now)
+		if len(crashes) > budget {
+			return errctx.Wrap(fmt.Errorf("%w: %w", ErrRestartBudget, err), "supervise",
+				"worker", name, "crashes", len(crashes), "window", window)
+		}
+
+		// a worker that stayed up longer than the longest wait was healthy for a while, start over
+		if now.Sub(started) > maxBackoff {
+			backoff = minBackoff
+		}
+		if s.OnCrash != nil {
+			s.OnCrash(name, err, backoff)
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// Go supervises a worker in the background, Wait collects how each one ended
+func (s *Supervisor) Go(ctx context.Context, name string, w Worker) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := s.Run(ctx, name, w); err != nil && ctx.Err() == nil {
+			s.mu.Lock()
+			s.errs = append(s.errs, err)
+			s.mu.Unlock()
+		}
+	}()
+}
+
+// Wait blocks until every worker has stopped. Cancelling ctx is a normal way to stop,
+// so the error only covers workers that gave up
+func (s *Supervisor) Wait() error {
+	s.wg.Wait()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return errctx.Join(s.errs...)
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"learninggo/errors/errctx"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRecoverHandler(t *testing.T) {
+	var reported atomic.Int32
+	report := func(*errctx.PanicError) { reported.Add(1) }
+
+	t.Run("before writing", func(t *testing.T) {
+		reported.Store(0)
+		h := RecoverHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var users []string
+			fmt.Fprint(w, users[3])
+		}), report)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/3", nil))
+
+		var p Problem
+		if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != http.StatusInternalServerError || p.Status != 500 || p.Instance != "/users/3" {
+			t.Errorf("got %d %+v, want a 500 problem", w.Code, p)
+		}
+		// the panic stays in the logs, the client only hears something went wrong
+		if strings.Contains(p.Detail, "index out of range") {
+			t.Errorf("the panic leaked to the client: %q", p.Detail)
+		}
+		if reported.Load() != 1 {
+			t.Errorf("reported %d times, want 1", reported.Load())
+		}
+	})
+
+	// a problem after half a response would be read as the rest of it, so the connection is cut instead
+	t.Run("after writing", func(t *testing.T) {
+		reported.Store(0)
+		h := RecoverHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprint(w, `{"users": [`)
+			panic("lost the database halfway")
+		}), report)
+		w := httptest.NewRecorder()
+		v := catchPanic(func() { h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil)) })
+		if v != http.ErrAbortHandler {
+			t.Errorf("panicked with %v, want http.ErrAbortHandler", v)
+		}
+		if got := w.Body.String(); got != `{"users": [` {
+			t.Errorf("body %q had something added after the handler's own output", got)
+		}
+		if reported.Load() != 1 {
+			t.Errorf("reported %d times, want 1", reported.Load())
+		}
+	})
+
+	t.Run("ErrAbortHandler", func(t *testing.T) {
+		reported.Store(0)
+		h := RecoverHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		}), report)
+		w := httptest.NewRecorder()
+		v := catchPanic(func() { h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil)) })
+		if v != http.ErrAbortHandler {
+			t.Errorf("panicked with %v, want http.ErrAbortHandler passed on", v)
+		}
+		if reported.Load() != 0 {
+			t.Error("a deliberate abort was reported as a crash")
+		}
+	})
+
+	t.Run("flusher", func(t *testing.T) {
+		h := RecoverHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := w.(http.Flusher); !ok {
+				t.Error("the wrapped writer lost http.Flusher")
+			}
+		}), report)
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
+
+func catchPanic(fn func()) (v any) {
+	defer func() { v = recover() }()
+	fn()
+	return nil
+}
+
+// crashes returns a worker that fails n times, then finishes
+func crashes(n int) (Worker, *atomic.Int32) {
+	var runs atomic.Int32
+	return func(ctx context.Context) error {
+		if int(runs.Add(1)) <= n {
+			return errors.New("crashed")
+		}
+		return nil
+	}, &runs
+}
+
+func TestSupervisorBackoff(t *testing.T) {
+	var waits []time.Duration
+	var runs int
+	s := &Supervisor{
+		MinBackoff: time.Millisecond,
+		MaxBackoff: 4 * time.Millisecond,
+		Budget:     100,
+		Window:     time.Hour,
+		OnCrash:    func(_ string, _ error, wait time.Duration) { waits = append(waits, wait) },
+	}
+	err := s.Run(context.Background(), "worker", func(ctx context.Context) error {
+		runs++
+		switch {
+		case runs == 5:
+			// up and healthy for longer than the longest wait before crashing
+			time.Sleep(10 * time.Millisecond)
+		case runs == 7:
+			return nil
+		}
+		return errors.New("crashed")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms := time.Millisecond
+	if want := []time.Duration{ms, 2 * ms, 4 * ms, 4 * ms, ms, 2 * ms}; !slices.Equal(waits, want) {
+		t.Errorf("waited %v, want doubling up to the max and starting over after a healthy run %v", waits, want)
+	}
+}
+
+func TestSupervisorBudget(t *testing.T) {
+	s := &Supervisor{MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond, Budget: 2, Window: time.Hour}
+	w, runs := crashes(100)
+	err := s.Run(context.Background(), "doomed", w)
+	if !errors.Is(err, ErrRestartBudget) {
+		t.Fatalf("Run error = %v, want ErrRestartBudget", err)
+	}
+	if runs.Load() != 3 {
+		t.Errorf("ran %d times, want the first run and 2 restarts", runs.Load())
+	}
+
+	// crashes that have aged out of the window no longer count
+	s.Window = time.Nanosecond
+	w, runs = crashes(5)
+	if err := s.Run(context.Background(), "flaky", w); err != nil {
+		t.Errorf("crashes spread wider than the window gave up: %v", err)
+	}
+	if runs.Load() != 6 {
+		t.Errorf("ran %d times, want 6", runs.Load())
+	}
+}
+
+// The zero Supervisor restarts a crash rather than giving up on it, and waits before doing so
+func TestSupervisorDefaults(t *testing.T) {
+	var waited time.Duration
+	s := &Supervisor{OnCrash: func(_ string, _ error, wait time.Duration) { waited = wait }}
+	w, _ := crashes(1)
+	if err := s.Run(context.Background(), "worker", w); err != nil {
+		t.Fatalf("zero Supervisor gave up: %v", err)
+	}
+	if waited != defaultMinBackoff {
+		t.Errorf("waited %v, want the default %v", waited, defaultMinBackoff)
+	}
+
+	// a max below the min is raised to it
+	lo, hi, _, _ := (&Supervisor{MinBackoff: time.Second, MaxBackoff: time.Millisecond}).settings()
+	if lo != time.Second || hi != time.Second {
+		t.Errorf("settings = %v, %v, want both 1s", lo, hi)
+	}
+}
+
+// Cancelling is how a supervisor is stopped, so Wait only reports workers that gave up
+func TestSupervisorWaitIgnoresCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Supervisor{MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond, Budget: 1, Window: time.Hour}
+
+	started := make(chan struct{})
+	s.Go(ctx, "steady", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	// on a context of its own, so it gives up whenever the cancel lands
+	s.Go(context.Background(), "crashing", func(ctx context.Context) error {
+		panic("config file is missing")
+	})
+	<-started
+	cancel()
+
+	err := s.Wait()
+	if !errors.Is(err, ErrRestartBudget) {
+		t.Fatalf("Wait error = %v, want the crashing worker's ErrRestartBudget", err)
+	}
+	if errors.Is(err, context.Canceled) {
+		t.Errorf("Wait reported the cancelled worker: %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// A token bucket holds up to burst tokens and refills at a steady rate. Every attempt takes a token,
+// and with none left the attempt is refused. Someone who logs in now and then never notices it,
+// someone trying a thousand passwords gets burst tries and then one per refill
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter keeps a bucket per key, a username or an address say
+type RateLimiter struct {
+	mu      sync.Mutex
+	perSec  float64
+	burst   float64
+	buckets map[string]*bucket
+	calls   int
+	now     func() time.Time
+}
+
+// NewRateLimiter allows burst attempts at once, then one more every interval
+func NewRateLimiter(interval time.Duration, burst int) (*RateLimiter, error) {
+	// a zero interval refills infinitely fast, and a zero burst never allows anything at all
+	if interval <= 0 {
+		return nil, fmt.Errorf("rate limit interval must be positive, got %v", interval)
+	}
+	if burst <= 0 {
+		return nil, fmt.Errorf("rate limit burst must be at least 1, got %d", burst)
+	}
+	return &RateLimiter{
+		perSec:  float64(time.Second) / float64(interval),
+		burst:   float64(burst),
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}, nil
+}
+
+// Every key anyone ever tried would stay in the map forever. A full bucket behaves exactly like a
+// missing one, so every so often we drop the full ones
+const pruneEvery = 1000
+
+func (l *RateLimiter) refill(b *bucket, now time.Time) {
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.perSec)
+	b.last = now
+}
+
+// Allow takes a token for key. When there isn't one, it says how long until there will be
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+
+	l.calls++
+	if l.calls%pruneEvery == 0 {
+		for k, b := range l.buckets {
+			if l.refill(b, now); b.tokens >= l.burst {
+				delete(l.buckets, k)
+			}
+		}
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	l.refill(b, now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.perSec * float64(time.Second))
+	return false, wait
+}
+
+//////////////////////////////////////////////////////////////////////
+//                     Guarding the login                           //
+//////////////////////////////////////////////////////////////////////
+
+// LoginGuard sits in front of an Authenticator. Attempts are limited per user, so one account can't be
+// guessed at from many machines, and per source, so one machine can't guess at many accounts.
+// Every attempt, allowed or not, goes in the audit log
+type LoginGuard struct {
+	auth      *Authenticator
+	perUser   *RateLimiter
+	perSource *RateLimiter
+	audit     *AuditLog
+}
+
+func NewLoginGuard(auth *Authenticator, perUser, perSource *RateLimiter, audit *AuditLog) *LoginGuard {
+	return &LoginGuard{auth: auth, perUser: perUser, perSource: perSource, audit: audit}
+}
+
+func rateLimited(wait time.Duration) StatusErr {
+	return StatusErr{message: "too many login attempts", status: RateLimited, retryAfter: wait}
+}
+
+// LoginUser is Authenticator.LoginUser for a caller at source, usually the client's address
+// A refused attempt still uses up a token, otherwise hammering away while limited would cost nothing
+// The source is checked first and a source that's over its limit stops there. Taking the user's token
+// anyway would let one machine lock anyone out of their account just by hammering it with their name
+func (g *LoginGuard) LoginUser(user, pass, source string) (string, error) {
+	token, err := g.login(user, pass, source)
+	// an attempt we couldn't record is an attempt we don't allow
+	if auditErr := g.audit.Record(attemptRecord(user, source, err)); auditErr != nil {
+		return "", StatusErr{message: "login failed", status: Internal, cause: auditErr}
+	}
+	return token, err
+}
+
+func (g *LoginGuard) login(user, pass, source string) (string, error) {
+	if ok, wait := g.perSource.Allow(source); !ok {
+		return "", rateLimited(wait)
+	}
+	if ok, wait := g.perUser.Allow(user); !ok {
+		return "", rateLimited(wait)
+	}
+	return g.auth.LoginUser(user, pass)
+}
+
+func attemptRecord(user, source string, err error) AuditRecord {
+	r := AuditRecord{Time: time.Now(), User: user, Source: source, Outcome: OutcomeSuccess}
+	if err == nil {
+		return r
+	}
+	status := StatusOf(err)
+	r.Status = status.String()
+	switch status {
+	case RateLimited:
+		r.Outcome = OutcomeLimited
+	case Internal:
+		r.Outcome = OutcomeError
+	default:
+		r.Outcome = OutcomeDenied
+	}
+	return r
+}
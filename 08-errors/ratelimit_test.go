@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestLimiter(t *testing.T, interval time.Duration, burst int) *RateLimiter {
+	t.Helper()
+	l, err := NewRateLimiter(interval, burst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func newTestGuard(t *testing.T, auth *Authenticator, perUser, perSource *RateLimiter) *LoginGuard {
+	t.Helper()
+	audit, err := OpenAuditLog(filepath.Join(t.TempDir(), "audit.log"), 1<<20, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { audit.Close() })
+	return NewLoginGuard(auth, perUser, perSource, audit)
+}
+
+// One machine hammering away at someone's name runs into its own limit, and must not use up
+// the tokens the real owner needs to log in from somewhere else
+func TestLimitedSourceDoesNotDrainUser(t *testing.T) {
+	auth := newTestAuthenticator(t, BcryptHasher{Cost: bcrypt.MinCost}, 5)
+	if err := auth.Register("Mary", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	guard := newTestGuard(t, auth, newTestLimiter(t, time.Hour, 3), newTestLimiter(t, time.Hour, 2))
+
+	for range 20 {
+		guard.LoginUser("Mary", "guess", "203.0.113.7")
+	}
+	if _, err := guard.LoginUser("Mary", "guess", "203.0.113.7"); !errors.Is(err, StatusErr{status: RateLimited}) {
+		t.Fatalf("attacker should be limited, got %v", err)
+	}
+	if _, err := guard.LoginUser("Mary", "correct horse", "198.51.100.1"); err != nil {
+		t.Errorf("Mary from her own machine: %v", err)
+	}
+}
+
+func TestNewRateLimiterRejects(t *testing.T) {
+	for _, tc := range []struct {
+		interval time.Duration
+		burst    int
+	}{{0, 1}, {-time.Second, 1}, {time.Second, 0}, {time.Second, -1}} {
+		if _, err := NewRateLimiter(tc.interval, tc.burst); err == nil {
+			t.Errorf("NewRateLimiter accepted interval %v, burst %d", tc.interval, tc.burst)
+		}
+	}
+}
+
+// The limiter's clock only moves when the test moves it
+func TestRateLimiterRefill(t *testing.T) {
+	l := newTestLimiter(t, 10*time.Second, 2)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+
+	allow := func(key string, want bool, wantWait time.Duration) {
+		t.Helper()
+		ok, wait := l.Allow(key)
+		if ok != want || wait != wantWait {
+			t.Errorf("at %v Allow(%q) = %v, %v, want %v, %v", now.Format(time.TimeOnly), key, ok, wait, want, wantWait)
+		}
+	}
+	// the burst, then a wait for the next token
+	allow("Mary", true, 0)
+	allow("Mary", true, 0)
+	allow("Mary", false, 10*time.Second)
+	// someone else has a bucket of their own
+	allow("Leo", true, 0)
+
+	// halfway to a token, the wait is what's left of the interval
+	now = now.Add(5 * time.Second)
+	allow("Mary", false, 5*time.Second)
+	now = now.Add(5 * time.Second)
+	allow("Mary", true, 0)
+	allow("Mary", false, 10*time.Second)
+
+	// a long quiet spell refills the bucket, but only up to the burst
+	now = now.Add(time.Hour)
+	allow("Mary", true, 0)
+	allow("Mary", true, 0)
+	allow("Mary", false, 10*time.Second)
+}
+
+// Full buckets are dropped now and then, and a dropped bucket comes back full
+func TestRateLimiterPrunes(t *testing.T) {
+	l := newTestLimiter(t, time.Second, 1)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+
+	l.Allow("Mary")
+	now = now.Add(time.Minute)
+	for i := range pruneEvery - 1 {
+		l.Allow(fmt.Sprint("source-", i))
+	}
+	if _, ok := l.buckets["Mary"]; ok {
+		t.Error("Mary's bucket refilled a minute ago and is still kept")
+	}
+	if ok, _ := l.Allow("Mary"); !ok {
+		t.Error("Mary was refused after her bucket was dropped")
+	}
+}
@@ -0,0 +1,160 @@
+// Package result carries a value or an error through a chain of steps, so the error check is written once
+// at the end instead of after every step
+//
+//	total := result.Then(result.Of(strconv.Atoi(s)), half).OrElse(0)
+//
+// Every step after the first failure is skipped, and the error that comes out at the end is the
+// original one. Wrap and MapEach add context around it, never in place of it, so errors.Is and
+// errors.As work on it as usual
+package result
+
+import (
+	"fmt"
+	"learninggo/errors/errctx"
+)
+
+// Result is a value or an error, never both. The zero Result is Ok with T's zero value
+type Result[T any] struct {
+	val T
+	err error
+}
+
+func Ok[T any](v T) Result[T] {
+	return Result[T]{val: v}
+}
+
+// Err makes a failed Result. A nil err would make an Ok one, which is almost certainly a bug,
+// so Err refuses it
+func Err[T any](err error) Result[T] {
+	if err == nil {
+		panic("result.Err called with a nil error")
+	}
+	return Result[T]{err: err}
+}
+
+// Of adapts the usual (T, error) return, so Of(strconv.Atoi(s)) just works
+func Of[T any](v T, err error) Result[T] {
+	if err != nil {
+		return Result[T]{err: err}
+	}
+	return Ok(v)
+}
+
+// Pair holds the two values of a (A, B, error) return
+type Pair[A, B any] struct {
+	First  A
+	Second B
+}
+
+// Of2 adapts a (A, B, error) return
+func Of2[A, B any](a A, b B, err error) Result[Pair[A, B]] {
+	return Of(Pair[A, B]{a, b}, err)
+}
+
+// Get turns the Result back into (T, error), for handing on to code that doesn't know about Result
+func (r Result[T]) Get() (T, error) {
+	return r.val, r.err
+}
+
+func (r Result[T]) IsOk() bool {
+	return r.err == nil
+}
+
+func (r Result[T]) Err() error {
+	return r.err
+}
+
+// OrElse gives up on the error and uses def instead
+func (r Result[T]) OrElse(def T) T {
+	if r.err != nil {
+		return def
+	}
+	return r.val
+}
+
+// Must is for when failing means a bug, not bad input, like regexp.MustCompile. It panics with the error
+func (r Result[T]) Must() T {
+	if r.err != nil {
+		panic(r.err)
+	}
+	return r.val
+}
+
+// Wrap adds an operation and fields to a failed Result's error, see errctx.Wrap. An Ok one is untouched
+func (r Result[T]) Wrap(op string, kv ...any) Result[T] {
+	if r.err != nil {
+		r.err = errctx.Wrap(r.err, op, kv...)
+	}
+	return r
+}
+
+func (r Result[T]) String() string {
+	if r.err != nil {
+		return "Err(" + r.err.Error() + ")"
+	}
+	return fmt.Sprintf("Ok(%v)", r.val)
+}
+
+// Then, Map and friends have to be functions. A method can't introduce a type parameter of its own,
+// so r.Then couldn't turn a Result[string] into a Result[int]
+
+// Then runs f on the value, or passes the error on without calling f
+func Then[T, U any](r Result[T], f func(T) (U, error)) Result[U] {
+	if r.err != nil {
+		return Result[U]{err: r.err}
+	}
+	return Of(f(r.val))
+}
+
+// Map is Then for a step that can't fail
+func Map[T, U any](r Result[T], f func(T) U) Result[U] {
+	if r.err != nil {
+		return Result[U]{err: r.err}
+	}
+	return Ok(f(r.val))
+}
+
+// Collect gathers the values into one slice, or returns the first error. Later results aren't looked at
+// once one has failed
+func Collect[T any](rs ...Result[T]) Result[[]T] {
+	out := make([]T, 0, len(rs))
+	for _, r := range rs {
+		if r.err != nil {
+			return Result[[]T]{err: r.err}
+		}
+		out = append(out, r.val)
+	}
+	return Ok(out)
+}
+
+// MapEach runs f on every item, stopping at the first error. The error says which item it was
+func MapEach[T, U any](items []T, f func(T) (U, error)) Result[[]U] {
+	out := make([]U, 0, len(items))
+	for i, item := range items {
+		v, err := f(item)
+		if err != nil {
+			return Result[[]U]{err: errctx.Wrap(err, "item", "index", i, "value", item)}
+		}
+		out = append(out, v)
+	}
+	return Ok(out)
+}
+
+// Try runs f, turning a panic into an error as well. For steps that might index past the end of a slice
+// or hit a nil map, which is code that shouldn't fail but does. The panic comes back as an
+// *errctx.PanicError, with the stack of where it happened and the runtime error still reachable with errors.As
+func Try[T any](f func() (T, error)) Result[T] {
+	var v T
+	err := errctx.Catch(func() (err error) {
+		v, err = f()
+		return err
+	})
+	return Of(v, err)
+}
+
+// Must unwraps a (T, error) return directly, for package level variables and the like
+//
+//	var config = result.Must(loadConfig())
+func Must[T any](v T, err error) T {
+	return Of(v, err).Must()
+}
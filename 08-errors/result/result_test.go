@@ -0,0 +1,147 @@
+package result
+
+import (
+	"errors"
+	"fmt"
+	"learninggo/errors/errctx"
+	"log/slog"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var errOdd = errors.New("odd number")
+
+func half(n int) (int, error) {
+	if n%2 != 0 {
+		return 0, fmt.Errorf("half of %d: %w", n, errOdd)
+	}
+	return n / 2, nil
+}
+
+func TestThen(t *testing.T) {
+	if got, err := Then(Ok(8), half).Get(); got != 4 || err != nil {
+		t.Errorf("Then(Ok(8), half) = %d, %v, want 4", got, err)
+	}
+
+	// after the first failure nothing else runs, and the first error is the one that comes out
+	called := 0
+	counted := func(n int) (int, error) {
+		called++
+		return half(n)
+	}
+	r := Then(Then(Then(Of(strconv.Atoi("6")), counted), counted), counted)
+	if !errors.Is(r.Err(), errOdd) || called != 2 {
+		t.Errorf("got %v after %d calls, want errOdd after 2", r, called)
+	}
+	r = Then(Then(Of(strconv.Atoi("six")), counted), counted)
+	var numErr *strconv.NumError
+	if !errors.As(r.Err(), &numErr) || called != 2 {
+		t.Errorf("got %v after %d more calls, want the Atoi error and no calls", r, called-2)
+	}
+	if got := Map(r, func(n int) string { return strconv.Itoa(n) }); got.IsOk() || got.Err() != r.Err() {
+		t.Errorf("Map of a failed Result = %v, want the same error", got)
+	}
+	if got := r.OrElse(-1); got != -1 {
+		t.Errorf("OrElse = %d, want -1", got)
+	}
+}
+
+// The error says which item failed, and the original error is still underneath
+func TestMapEach(t *testing.T) {
+	got, err := MapEach([]int{2, 4, 6}, half).Get()
+	if err != nil || !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("MapEach = %v, %v, want [1 2 3]", got, err)
+	}
+
+	called := 0
+	r := MapEach([]int{2, 3, 5}, func(n int) (int, error) {
+		called++
+		return half(n)
+	})
+	if !errors.Is(r.Err(), errOdd) {
+		t.Fatalf("MapEach error = %v, want errOdd underneath", r.Err())
+	}
+	if called != 2 {
+		t.Errorf("called %d times, want it to stop at the first error", called)
+	}
+	if got := errctx.Ops(r.Err()); !slices.Equal(got, []string{"item"}) {
+		t.Errorf("Ops = %v, want [item]", got)
+	}
+	var fields []string
+	for _, f := range errctx.Fields(r.Err()) {
+		fields = append(fields, f.String())
+	}
+	if want := []string{"index=1", "value=3"}; !slices.Equal(fields, want) {
+		t.Errorf("Fields = %v, want %v", fields, want)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	if got, err := Collect(Ok(1), Ok(2)).Get(); err != nil || !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("Collect = %v, %v, want [1 2]", got, err)
+	}
+	first, second := errors.New("first"), errors.New("second")
+	if err := Collect(Ok(1), Err[int](first), Err[int](second)).Err(); err != first {
+		t.Errorf("Collect error = %v, want the first one", err)
+	}
+	if got, err := Collect[int]().Get(); err != nil || got == nil || len(got) != 0 {
+		t.Errorf("Collect of nothing = %#v, %v, want an empty slice", got, err)
+	}
+}
+
+func TestTry(t *testing.T) {
+	r := Try(func() (int, error) {
+		var items []int
+		return items[2], nil
+	})
+	// the runtime's panic is an error, findable underneath the PanicError
+	var re runtime.Error
+	if !errors.As(r.Err(), &re) {
+		t.Fatalf("Try error = %v, want a runtime.Error underneath", r.Err())
+	}
+	var p *errctx.PanicError
+	if !errors.As(r.Err(), &p) {
+		t.Fatalf("Try error = %v, want an *errctx.PanicError", r.Err())
+	}
+	// and the stack goes back to where it happened
+	if !strings.Contains(string(p.Stack), "result_test.go") {
+		t.Errorf("stack doesn't reach the test:\n%s", p.Stack)
+	}
+	if got := r.String(); got != "Err(panic: runtime error: index out of range [2] with length 0)" {
+		t.Errorf("String = %q", got)
+	}
+
+	if err := Try(func() (int, error) { panic("out of cheese") }).Err(); !errors.As(err, &p) || p.Value != "out of cheese" {
+		t.Errorf("Try of a string panic = %v", err)
+	}
+	if got, err := Try(func() (int, error) { return half(3) }).Get(); !errors.Is(err, errOdd) || got != 0 {
+		t.Errorf("Try of an ordinary error = %d, %v", got, err)
+	}
+	if got := Try(func() (int, error) { return 7, nil }); !got.IsOk() || got.OrElse(0) != 7 {
+		t.Errorf("Try without a panic = %v", got)
+	}
+}
+
+func TestErrNilPanics(t *testing.T) {
+	err := errctx.Catch(func() error {
+		Err[int](nil)
+		return nil
+	})
+	var p *errctx.PanicError
+	if !errors.As(err, &p) || p.Value != "result.Err called with a nil error" {
+		t.Errorf("Err(nil) = %v, want a panic", err)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	r := Err[int](errOdd).Wrap("split bill", slog.Int("diners", 3))
+	if !errors.Is(r.Err(), errOdd) || r.Err().Error() != "split bill: odd number" {
+		t.Errorf("Wrap = %v", r.Err())
+	}
+	if ok := Ok(4).Wrap("split bill"); ok.Err() != nil {
+		t.Errorf("Wrap of an Ok Result = %v", ok)
+	}
+}
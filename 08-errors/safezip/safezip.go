@@ -0,0 +1,402 @@
+// Package safezip reads archives we didn't make ourselves. A zip is a list of instructions from whoever
+// built it: write this file here, make it this big. Followed blindly they can write outside the
+// destination (zip slip), fill the disk from a few kilobytes (a zip bomb), or plant a symlink that
+// sends a later entry somewhere else. Extract checks each entry for all of those before writing it
+package safezip
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Each failure class is its own sentinel, match them with errors.Is. The entry at fault, if there is
+// one, is in an *EntryError
+var (
+	ErrUnsafePath    = errors.New("entry path escapes the destination")
+	ErrFileTooLarge  = errors.New("entry is larger than allowed")
+	ErrTotalTooLarge = errors.New("archive expands to more than allowed")
+	ErrRatio         = errors.New("entry compresses suspiciously well")
+	ErrTooManyFiles  = errors.New("archive has too many entries")
+	ErrSymlink       = errors.New("symlink not allowed")
+	ErrChecksum      = errors.New("entry checksum mismatch")
+	ErrCorrupt       = errors.New("entry data is corrupt")
+	ErrExists        = errors.New("entry already exists in the destination")
+)
+
+type EntryError struct {
+	Name string
+	Err  error
+}
+
+func (e *EntryError) Error() string {
+	return e.Name + ": " + e.Err.Error()
+}
+
+func (e *EntryError) Unwrap() error {
+	return e.Err
+}
+
+func entryErr(name string, err error) error {
+	return &EntryError{Name: name, Err: err}
+}
+
+//////////////////////////////////////////////////////////////////////
+//                     Inspecting                                   //
+//////////////////////////////////////////////////////////////////////
+
+type Entry struct {
+	Name           string
+	Size           uint64
+	CompressedSize uint64
+	Mode           fs.FileMode
+	Modified       time.Time
+	CRC32          uint32
+}
+
+// Ratio is how many times bigger the entry gets when it's extracted
+func (e Entry) Ratio() float64 {
+	if e.CompressedSize == 0 {
+		return 0
+	}
+	return float64(e.Size) / float64(e.CompressedSize)
+}
+
+// List describes the entries as the archive claims they are. Nothing is decompressed,
+// so nothing here is proof, sizes included
+func List(zr *zip.Reader) []Entry {
+	entries := make([]Entry, len(zr.File))
+	for i, f := range zr.File {
+		entries[i] = Entry{
+			Name:           f.Name,
+			Size:           f.UncompressedSize64,
+			CompressedSize: f.CompressedSize64,
+			Mode:           f.Mode(),
+			Modified:       f.Modified,
+			CRC32:          f.CRC32,
+		}
+	}
+	return entries
+}
+
+// Verify decompresses every entry and checks it against its CRC, reporting every bad entry, not just the first
+// Decompressing a bomb is what a bomb wants, so it's held to the same limits as Extract
+func Verify(zr *zip.Reader, limits Limits) error {
+	if err := limits.checkCount(len(zr.File)); err != nil {
+		return err
+	}
+	var errs []error
+	var total int64
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		n, err := copyEntry(io.Discard, f, limits, &total)
+		if err != nil {
+			errs = append(errs, entryErr(f.Name, err))
+			// past the total, every other entry would fail the same way
+			if errors.Is(err, ErrTotalTooLarge) {
+				break
+			}
+			continue
+		}
+		if uint64(n) != f.UncompressedSize64 {
+			errs = append(errs, entryErr(f.Name, fmt.Errorf("%w: header says %d bytes, got %d", ErrChecksum, f.UncompressedSize64, n)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+//////////////////////////////////////////////////////////////////////
+//                     Extracting                                   //
+//////////////////////////////////////////////////////////////////////
+
+type SymlinkPolicy int
+
+const (
+	// RejectSymlinks fails extraction at the first symlink. It's the zero value, so the safe default
+	RejectSymlinks SymlinkPolicy = iota
+	// SkipSymlinks leaves symlinks out and extracts everything else
+	SkipSymlinks
+	// AllowContainedSymlinks keeps symlinks whose target stays inside the destination
+	AllowContainedSymlinks
+)
+
+// Limits bound what an archive may expand to. A zero limit means no limit, so set every one of them
+// for archives from strangers, DefaultLimits is a reasonable start
+type Limits struct {
+	MaxFiles     int
+	MaxFileSize  int64
+	MaxTotalSize int64
+	// MaxRatio is the most any entry may grow by when decompressed. Ordinary files manage 10x or so,
+	// a bomb thousands
+	MaxRatio float64
+}
+
+var DefaultLimits = Limits{
+	MaxFiles:     10_000,
+	MaxFileSize:  100 << 20,
+	MaxTotalSize: 1 << 30,
+	MaxRatio:     100,
+}
+
+func (l Limits) checkCount(n int) error {
+	if l.MaxFiles > 0 && n > l.MaxFiles {
+		return fmt.Errorf("%w: %d, limit %d", ErrTooManyFiles, n, l.MaxFiles)
+	}
+	return nil
+}
+
+type Options struct {
+	Limits   Limits
+	Symlinks SymlinkPolicy
+}
+
+// copyEntry decompresses f into w and checks its CRC. The header's sizes are whatever the archive's
+// author typed, so the limits are enforced on the bytes as they come out, not on what was promised
+// total carries the running total across entries
+func copyEntry(w io.Writer, f *zip.File, limits Limits, total *int64) (int64, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	// find the tightest of the limits, and read one byte past it so we can tell reaching it from passing it
+	limit, limitErr := int64(-1), error(nil)
+	tighten := func(n int64, err error) {
+		if limit < 0 || n < limit {
+			limit, limitErr = n, err
+		}
+	}
+	if limits.MaxFileSize > 0 {
+		tighten(limits.MaxFileSize, ErrFileTooLarge)
+	}
+	if limits.MaxTotalSize > 0 {
+		tighten(limits.MaxTotalSize-*total, ErrTotalTooLarge)
+	}
+	if limits.MaxRatio > 0 {
+		tighten(int64(float64(max(f.CompressedSize64, 1))*limits.MaxRatio), ErrRatio)
+	}
+	r := io.Reader(corruptReader{rc})
+	if limit >= 0 {
+		r = io.LimitReader(r, limit+1)
+	}
+
+	// archive/zip checks CRCs too, but only once a read hits the end of the entry. We stop short of
+	// the end whenever a limit trips, so we keep our own
+	sum := crc32.NewIEEE()
+	n, err := io.Copy(io.MultiWriter(w, sum), r)
+	*total += n
+	if err != nil && !errors.Is(err, zip.ErrChecksum) {
+		return n, err
+	}
+	if limit >= 0 && n > limit {
+		return n, limitErr
+	}
+	if errors.Is(err, zip.ErrChecksum) || sum.Sum32() != f.CRC32 {
+		return n, ErrChecksum
+	}
+	return n, nil
+}
+
+// corruptReader tags what goes wrong decompressing as ErrCorrupt, a broken deflate stream for instance.
+// Only the reading side is tagged, a disk filling up while we write isn't the archive's fault
+type corruptReader struct {
+	r io.Reader
+}
+
+func (c corruptReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if err != nil && err != io.EOF && !errors.Is(err, zip.ErrChecksum) {
+		err = fmt.Errorf("%w: %w", ErrCorrupt, err)
+	}
+	return n, err
+}
+
+// localName turns an entry name into a path that is guaranteed to stay under the destination
+// Zip names always use /, so a \ is either a Windows path or an attempt to sneak one past us
+func localName(name string) (string, error) {
+	if strings.Contains(name, `\`) {
+		return "", ErrUnsafePath
+	}
+	local := filepath.FromSlash(strings.TrimSuffix(name, "/"))
+	if !filepath.IsLocal(local) {
+		return "", ErrUnsafePath
+	}
+	return local, nil
+}
+
+// Extract unpacks the archive under dest, which must already exist. It refuses to overwrite anything,
+// so extract into a fresh directory. On error whatever was extracted so far stays put
+//
+// All writes go through an os.Root, which the OS won't let a path or a symlink escape. The checks below
+// would catch those first. The Root is there for whatever they miss
+func Extract(zr *zip.Reader, dest string, opts Options) error {
+	if err := opts.Limits.checkCount(len(zr.File)); err != nil {
+		return err
+	}
+	root, err := os.OpenRoot(dest)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	var total int64
+	for _, f := range zr.File {
+		name, err := localName(f.Name)
+		if err != nil {
+			return entryErr(f.Name, err)
+		}
+		if dir := filepath.Dir(name); dir != "." {
+			if err := root.MkdirAll(dir, 0o755); err != nil {
+				return entryErr(f.Name, err)
+			}
+		}
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			err = root.MkdirAll(name, 0o755)
+		case mode&fs.ModeSymlink != 0:
+			err = extractSymlink(root, f, name, opts.Symlinks)
+		case mode.IsRegular():
+			err = extractFile(root, f, name, opts.Limits, &total)
+		default:
+			// devices, pipes and sockets have no business in an upload
+			err = fmt.Errorf("unsupported file type %v", mode.Type())
+		}
+		if err != nil {
+			return entryErr(f.Name, err)
+		}
+	}
+	return nil
+}
+
+func extractFile(root *os.Root, f *zip.File, name string, limits Limits, total *int64) error {
+	// keep the permission bits but never setuid, setgid or sticky
+	perm := f.Mode().Perm()
+	if perm == 0 {
+		perm = 0o644
+	}
+	out, err := root.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if errors.Is(err, fs.ErrExist) {
+		return ErrExists
+	}
+	if err != nil {
+		return err
+	}
+	_, err = copyEntry(out, f, limits, total)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// don't leave a half written or unverified file behind
+		root.Remove(name)
+	}
+	return err
+}
+
+func extractSymlink(root *os.Root, f *zip.File, name string, policy SymlinkPolicy) error {
+	switch policy {
+	case SkipSymlinks:
+		return nil
+	case AllowContainedSymlinks:
+	default:
+		return ErrSymlink
+	}
+	// a symlink entry's content is its target
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	target, err := io.ReadAll(io.LimitReader(rc, 4096))
+	rc.Close()
+	if err != nil {
+		return err
+	}
+	// the target is relative to the link's own directory
+	resolved := path.Join(path.Dir(filepath.ToSlash(name)), string(target))
+	if path.IsAbs(string(target)) || !filepath.IsLocal(filepath.FromSlash(resolved)) {
+		return fmt.Errorf("%w: %s points outside the destination", ErrSymlink, target)
+	}
+	err = root.Symlink(string(target), name)
+	if errors.Is(err, fs.ErrExist) {
+		return ErrExists
+	}
+	return err
+}
+
+//////////////////////////////////////////////////////////////////////
+//                     Creating                                     //
+//////////////////////////////////////////////////////////////////////
+
+// Create writes every file, directory and symlink under dir into a new archive on w. Names are
+// relative to dir, so extracting the archive recreates dir's contents, not dir itself
+func Create(w io.Writer, dir string) error {
+	zw := zip.NewWriter(w)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if d.IsDir() {
+			header.Name += "/"
+			_, err = zw.CreateHeader(header)
+			return err
+		}
+		header.Method = zip.Deflate
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			header.Method = zip.Store
+			entry, err := zw.CreateHeader(header)
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(entry, target)
+			return err
+		case info.Mode().IsRegular():
+			entry, err := zw.CreateHeader(header)
+			if err != nil {
+				return err
+			}
+			src, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer src.Close()
+			_, err = io.Copy(entry, src)
+			return err
+		}
+		// sockets and the like can't be archived, leave them out
+		return nil
+	})
+	if err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
@@ -0,0 +1,409 @@
+package safezip
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// file is one entry of an archive built for a test. Raw entries are written as they are,
+// so a test can hand Extract whatever CRC or compressed bytes it likes
+type file struct {
+	name string
+	body string
+	mode fs.FileMode
+	raw  *zip.FileHeader
+}
+
+func symlink(name, target string) file {
+	return file{name: name, body: target, mode: fs.ModeSymlink | 0o777}
+}
+
+func archive(t *testing.T, files ...file) *zip.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		var w io.Writer
+		var err error
+		if f.raw != nil {
+			w, err = zw.CreateRaw(f.raw)
+		} else {
+			h := &zip.FileHeader{Name: f.name, Method: zip.Deflate}
+			h.SetMode(f.mode | 0o644)
+			w, err = zw.CreateHeader(h)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, f.body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zr
+}
+
+// stored is an uncompressed entry whose header claims crc, right or not
+func stored(name, body string, crc uint32) file {
+	return file{name: name, body: body, raw: &zip.FileHeader{
+		Name: name, Method: zip.Store, CRC32: crc,
+		CompressedSize64: uint64(len(body)), UncompressedSize64: uint64(len(body)),
+	}}
+}
+
+// destination is an empty directory inside another, so a test can check nothing landed next to it
+func destination(t *testing.T) (dest, parent string) {
+	t.Helper()
+	parent = t.TempDir()
+	dest = filepath.Join(parent, "dest")
+	if err := os.Mkdir(dest, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return dest, parent
+}
+
+// tree lists everything under dir, directories with a trailing slash and symlinks with their target
+func tree(t *testing.T, dir string) []string {
+	t.Helper()
+	var got []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == dir {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		switch {
+		case d.IsDir():
+			rel += "/"
+		case d.Type()&fs.ModeSymlink != 0:
+			target, _ := os.Readlink(p)
+			rel += " -> " + target
+		}
+		got = append(got, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+// incompressible is text deflate can't do much with, so the ratio limit stays out of the way
+func incompressible(n int) string {
+	var b strings.Builder
+	for i := range n {
+		b.WriteByte(byte('a' + i*7%26))
+		b.WriteByte(byte('A' + i*i%26))
+	}
+	return b.String()[:n]
+}
+
+func TestExtractRejects(t *testing.T) {
+	hello := "hello, world\n"
+	for _, tc := range []struct {
+		name   string
+		files  []file
+		opts   Options
+		want   error
+		entry  string
+		remain []string
+	}{
+		// zip slip, in each of its spellings
+		{name: "parent", files: []file{{name: "../x", body: hello}}, want: ErrUnsafePath, entry: "../x"},
+		{name: "parent further in", files: []file{{name: "a/../../x", body: hello}}, want: ErrUnsafePath, entry: "a/../../x"},
+		{name: "absolute", files: []file{{name: "/etc/passwd", body: hello}}, want: ErrUnsafePath, entry: "/etc/passwd"},
+		{name: "windows parent", files: []file{{name: `..\x`, body: hello}}, want: ErrUnsafePath, entry: `..\x`},
+		{name: "windows separator", files: []file{{name: `a\b.txt`, body: hello}}, want: ErrUnsafePath, entry: `a\b.txt`},
+		{
+			// entries before the bad one are already out, the bad one is gone
+			name:   "unsafe path after a good one",
+			files:  []file{{name: "ok.txt", body: hello}, {name: "../x", body: hello}},
+			want:   ErrUnsafePath,
+			entry:  "../x",
+			remain: []string{"ok.txt"},
+		},
+
+		// bombs: the limits hold on what comes out, whatever the headers say
+		{
+			name:   "total size",
+			files:  []file{{name: "a", body: incompressible(40)}, {name: "b", body: incompressible(40)}, {name: "c", body: incompressible(40)}},
+			opts:   Options{Limits: Limits{MaxTotalSize: 100}},
+			want:   ErrTotalTooLarge,
+			entry:  "c",
+			remain: []string{"a", "b"},
+		},
+		{
+			name:  "file size",
+			files: []file{{name: "big", body: incompressible(200)}},
+			opts:  Options{Limits: Limits{MaxFileSize: 100}},
+			want:  ErrFileTooLarge,
+			entry: "big",
+		},
+		{
+			name:  "ratio",
+			files: []file{{name: "zeros", body: strings.Repeat("\x00", 1<<20)}},
+			opts:  Options{Limits: DefaultLimits},
+			want:  ErrRatio,
+			entry: "zeros",
+		},
+		{
+			name:  "too many files",
+			files: []file{{name: "a", body: hello}, {name: "b", body: hello}},
+			opts:  Options{Limits: Limits{MaxFiles: 1}},
+			want:  ErrTooManyFiles,
+		},
+
+		// damaged archives
+		{name: "crc mismatch", files: []file{stored("bad", hello, crc32.ChecksumIEEE([]byte(hello))+1)}, want: ErrChecksum, entry: "bad"},
+		{
+			name: "corrupt deflate",
+			files: []file{{name: "bad", body: "this was never deflated", raw: &zip.FileHeader{
+				Name: "bad", Method: zip.Deflate, CompressedSize64: 23, UncompressedSize64: 100,
+			}}},
+			want:  ErrCorrupt,
+			entry: "bad",
+		},
+		{
+			name:   "same name twice",
+			files:  []file{{name: "a", body: hello}, {name: "a", body: "overwritten"}},
+			want:   ErrExists,
+			entry:  "a",
+			remain: []string{"a"},
+		},
+
+		// symlinks
+		{name: "symlinks rejected", files: []file{symlink("link", "a")}, want: ErrSymlink, entry: "link"},
+		{
+			name:  "symlink out",
+			files: []file{symlink("link", "../outside")},
+			opts:  Options{Symlinks: AllowContainedSymlinks},
+			want:  ErrSymlink,
+			entry: "link",
+		},
+		{
+			name:   "symlink out from a subdirectory",
+			files:  []file{{name: "sub/", mode: fs.ModeDir | 0o755}, symlink("sub/link", "../../outside")},
+			opts:   Options{Symlinks: AllowContainedSymlinks},
+			want:   ErrSymlink,
+			entry:  "sub/link",
+			remain: []string{"sub/"},
+		},
+		{
+			name:  "absolute symlink",
+			files: []file{symlink("link", "/etc")},
+			opts:  Options{Symlinks: AllowContainedSymlinks},
+			want:  ErrSymlink,
+			entry: "link",
+		},
+		{
+			// the link is harmless on its own, writing the file through it would overwrite real.conf
+			name:   "file written through a symlink",
+			files:  []file{{name: "real.conf", body: hello}, symlink("app.conf", "real.conf"), {name: "app.conf", body: "evil"}},
+			opts:   Options{Symlinks: AllowContainedSymlinks},
+			want:   ErrExists,
+			entry:  "app.conf",
+			remain: []string{"app.conf -> real.conf", "real.conf"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dest, parent := destination(t)
+			err := Extract(archive(t, tc.files...), dest, tc.opts)
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("Extract error = %v, want %v", err, tc.want)
+			}
+			var ee *EntryError
+			if got := errors.As(err, &ee); got != (tc.entry != "") || (got && ee.Name != tc.entry) {
+				t.Errorf("EntryError = %v, want one for %q", ee, tc.entry)
+			}
+			if got := tree(t, dest); !slices.Equal(got, tc.remain) {
+				t.Errorf("destination has %q, want %q", got, tc.remain)
+			}
+			if got := tree(t, parent); !slices.Equal(got, append([]string{"dest/"}, prefixed("dest/", tc.remain)...)) {
+				t.Errorf("something was written outside the destination: %q", got)
+			}
+		})
+	}
+}
+
+func prefixed(prefix string, names []string) []string {
+	var out []string
+	for _, n := range names {
+		out = append(out, prefix+n)
+	}
+	return out
+}
+
+func TestExtractSymlinkPolicies(t *testing.T) {
+	files := []file{
+		{name: "a.txt", body: "a"},
+		{name: "sub/", mode: fs.ModeDir | 0o755},
+		symlink("sub/link", "../a.txt"),
+		{name: "sub/b.txt", body: "b"},
+	}
+	for _, tc := range []struct {
+		policy SymlinkPolicy
+		want   []string
+		err    error
+	}{
+		{RejectSymlinks, []string{"a.txt", "sub/"}, ErrSymlink},
+		{SkipSymlinks, []string{"a.txt", "sub/", "sub/b.txt"}, nil},
+		{AllowContainedSymlinks, []string{"a.txt", "sub/", "sub/b.txt", "sub/link -> ../a.txt"}, nil},
+	} {
+		dest, _ := destination(t)
+		if err := Extract(archive(t, files...), dest, Options{Symlinks: tc.policy}); !errors.Is(err, tc.err) {
+			t.Errorf("policy %d: Extract error = %v, want %v", tc.policy, err, tc.err)
+		}
+		if got := tree(t, dest); !slices.Equal(got, tc.want) {
+			t.Errorf("policy %d: extracted %q, want %q", tc.policy, got, tc.want)
+		}
+	}
+}
+
+// A skipped symlink leaves nothing behind for the next entry to go through
+func TestSkippedSymlinkIsNotFollowed(t *testing.T) {
+	dest, parent := destination(t)
+	zr := archive(t, symlink("evil", ".."), file{name: "evil/planted", body: "gotcha"})
+	if err := Extract(zr, dest, Options{Symlinks: SkipSymlinks}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tree(t, parent), []string{"dest/", "dest/evil/", "dest/evil/planted"}; !slices.Equal(got, want) {
+		t.Errorf("extracted %q, want %q", got, want)
+	}
+}
+
+// A symlink already in the destination, from an earlier extraction or planted some other way, doesn't
+// get followed out either. os.Root refuses to leave the destination whatever the path looks like
+func TestExistingSymlinkIsNotFollowedOut(t *testing.T) {
+	dest, parent := destination(t)
+	if err := os.Symlink(parent, filepath.Join(dest, "evil")); err != nil {
+		t.Fatal(err)
+	}
+	err := Extract(archive(t, file{name: "evil/planted", body: "gotcha"}), dest, Options{})
+	var ee *EntryError
+	if !errors.As(err, &ee) || ee.Name != "evil/planted" {
+		t.Fatalf("Extract error = %v, want an EntryError for evil/planted", err)
+	}
+	if _, err := os.Stat(filepath.Join(parent, "planted")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("the file was written through the symlink: %v", err)
+	}
+}
+
+func TestExtractIntoExisting(t *testing.T) {
+	dest, _ := destination(t)
+	if err := os.WriteFile(filepath.Join(dest, "config.json"), []byte("mine"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := Extract(archive(t, file{name: "config.json", body: "theirs"}), dest, Options{})
+	if !errors.Is(err, ErrExists) {
+		t.Fatalf("Extract error = %v, want ErrExists", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dest, "config.json")); string(data) != "mine" {
+		t.Errorf("the existing file now holds %q", data)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	hello := "hello, world\n"
+	zr := archive(t,
+		file{name: "good", body: hello},
+		stored("bad crc", hello, 1),
+		file{name: "dir/", mode: fs.ModeDir | 0o755},
+		file{name: "corrupt", body: "this was never deflated", raw: &zip.FileHeader{
+			Name: "corrupt", Method: zip.Deflate, CompressedSize64: 23, UncompressedSize64: 100,
+		}},
+		// the header's size is a lie, the data and CRC are fine
+		file{name: "short", body: hello, raw: &zip.FileHeader{
+			Name: "short", Method: zip.Store, CRC32: crc32.ChecksumIEEE([]byte(hello)),
+			CompressedSize64: uint64(len(hello)), UncompressedSize64: 1000,
+		}},
+	)
+	err := Verify(zr, DefaultLimits)
+	if !errors.Is(err, ErrChecksum) || !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("Verify error = %v, want ErrChecksum and ErrCorrupt", err)
+	}
+	// every bad entry is reported, not just the first
+	var names []string
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var ee *EntryError
+		if errors.As(e, &ee) {
+			names = append(names, ee.Name)
+		}
+	}
+	if want := []string{"bad crc", "corrupt", "short"}; !slices.Equal(names, want) {
+		t.Errorf("Verify reported %q, want %q", names, want)
+	}
+
+	if err := Verify(archive(t, file{name: "good", body: hello}), DefaultLimits); err != nil {
+		t.Errorf("Verify of a good archive = %v", err)
+	}
+}
+
+func TestCreateExtractRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	for name, body := range map[string]string{
+		"a.txt":           "first\n",
+		"sub/b.txt":       strings.Repeat("second\n", 100),
+		"sub/deep/c.json": `{"third": true}`,
+	} {
+		p := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(src, "a.txt"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(src, "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../a.txt", filepath.Join(src, "sub", "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := Create(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(zr, DefaultLimits); err != nil {
+		t.Fatalf("Verify of our own archive: %v", err)
+	}
+	dest, _ := destination(t)
+	if err := Extract(zr, dest, Options{Limits: DefaultLimits, Symlinks: AllowContainedSymlinks}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := tree(t, dest), tree(t, src); !slices.Equal(got, want) {
+		t.Errorf("extracted %q, want %q", got, want)
+	}
+	for _, name := range []string{"a.txt", "sub/b.txt", "sub/deep/c.json"} {
+		want, _ := os.ReadFile(filepath.Join(src, name))
+		got, err := os.ReadFile(filepath.Join(dest, name))
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("%s = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(dest, "a.txt")); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("a.txt lost its permissions: %v %v", info.Mode(), err)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defining error states
+
+// Status gets its String, ParseStatus and JSON/text methods from enumgen, see status_enum.go
+// The trailing comments are the display names. New statuses go at the end, the numbers are exit codes'
+// and logs' business too
+
+//go:generate go run -C ../enumgen . -type=Status -dir=$PWD
+type Status int
+
+const (
+	InvalidKey        Status = iota // invalid key
+	UserNotFound                    // user not found
+	AccountLocked                   // account locked
+	TokenExpired                    // token expired
+	TokenMalformed                  // token malformed
+	TokenBadSignature               // bad token signature
+	InvalidRequest                  // invalid request
+	Forbidden                       // forbidden
+	RateLimited                     // rate limited
+	Unavailable                     // unavailable
+	Internal                        // internal error
+)
+
+// HTTPStatus is the response code a handler should answer a Status with
+func (s Status) HTTPStatus() int {
+	switch s {
+	case InvalidKey, TokenExpired, TokenMalformed, TokenBadSignature:
+		return http.StatusUnauthorized
+	case UserNotFound:
+		return http.StatusNotFound
+	case AccountLocked:
+		return http.StatusLocked
+	case InvalidRequest:
+		return http.StatusBadRequest
+	case Forbidden:
+		return http.StatusForbidden
+	case RateLimited:
+		return http.StatusTooManyRequests
+	case Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// ExitCode is the code a command line tool should exit with, from BSD's sysexits.h
+// Scripts can tell "try again later" (75) from "you're not allowed" (77) without parsing our output
+func (s Status) ExitCode() int {
+	switch s {
+	case InvalidKey, AccountLocked, TokenExpired, TokenMalformed, TokenBadSignature, Forbidden:
+		return 77 // EX_NOPERM
+	case UserNotFound:
+		return 67 // EX_NOUSER
+	case InvalidRequest:
+		return 65 // EX_DATAERR
+	case RateLimited:
+		return 75 // EX_TEMPFAIL
+	case Unavailable:
+		return 69 // EX_UNAVAILABLE
+	}
+	return 70 // EX_SOFTWARE
+}
+
+type StatusErr struct {
+	message string
+	status  Status
+	// cause is what actually went wrong underneath, if anything. It's for logs, not for the user,
+	// so it's left out of problem details
+	cause error
+	// retryAfter is how long the caller should wait before trying again, for RateLimited and Unavailable
+	retryAfter time.Duration
+}
+
+// To define a struct as an error.
+// It needs to implement the Error interface by implementing an Error() function.
+func (s StatusErr) Error() string {
+	if s.cause == nil {
+		return s.message
+	}
+	return s.message + ": " + s.cause.Error()
+}
+
+func (s StatusErr) Status() Status {
+	return s.status
+}
+
+// RetryAfter is 0 when there's no point waiting, or no telling how long to wait
+func (s StatusErr) RetryAfter() time.Duration {
+	return s.retryAfter
+}
+
+// Unwrap lets errors.Is and errors.As look through to the cause, so a StatusErr wrapping
+// os.ErrNotExist still matches os.ErrNotExist
+func (s StatusErr) Unwrap() error {
+	return s.cause
+}
+
+// We need to implement this method to be able to compare errors using errors.Is(a, b)
+// Two StatusErrs are the same error if they have the same status, the messages are only details
+// errors.Is hands us whatever it's looking for, which needn't be a StatusErr at all, so check before using it
+func (s StatusErr) Is(target error) bool {
+	switch t := target.(type) {
+	case StatusErr:
+		return t.status == s.status
+	case *StatusErr:
+		return t != nil && t.status == s.status
+	}
+	return false
+}
+
+// As lets errors.As fill in a *StatusErr as well as a StatusErr, whichever the caller reached for
+//
+//	var se *StatusErr
+//	if errors.As(err, &se) { ... }
+func (s StatusErr) As(target any) bool {
+	if p, ok := target.(**StatusErr); ok {
+		*p = &s
+		return true
+	}
+	return false
+}
+
+// StatusOf finds the Status anywhere in err's chain. An error nobody gave a status is Internal
+func StatusOf(err error) Status {
+	var se StatusErr
+	if errors.As(err, &se) {
+		return se.status
+	}
+	return Internal
+}
+
+// ExitCode is 0 for no error, otherwise whatever err's status says
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	return StatusOf(err).ExitCode()
+}
+
+//////////////////////////////////////////////////////////////////////
+//                     Problem details                              //
+//////////////////////////////////////////////////////////////////////
+
+// Problem is an error as RFC 7807 problem details, the JSON error body most HTTP clients already understand
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// RetryAfter is an extension member, in whole seconds like the Retry-After header
+	RetryAfter int `json:"retryAfter,omitempty"`
+}
+
+// ProblemFor describes err for a client. Only a StatusErr's own message makes it out, never its cause,
+// and an error without a status gets no detail at all. Either could be a file path or a SQL query
+func ProblemFor(err error, instance string) Problem {
+	status := StatusOf(err)
+	p := Problem{
+		Type:     "urn:problem-type:learninggo:" + strings.ReplaceAll(status.String(), " ", "-"),
+		Title:    status.String(),
+		Status:   status.HTTPStatus(),
+		Instance: instance,
+	}
+	var se StatusErr
+	if errors.As(err, &se) {
+		p.Detail = se.message
+		p.RetryAfter = retrySeconds(se.retryAfter)
+	}
+	return p
+}
+
+// WriteProblem answers the request with err as application/problem+json
+func WriteProblem(w http.ResponseWriter, r *http.Request, err error) {
+	p := ProblemFor(err, r.URL.Path)
+	w.Header().Set("Content-Type", "application/problem+json")
+	if p.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(p.RetryAfter))
+	}
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// retrySeconds rounds up, a client told 0 seconds would come straight back and be refused again
+func retrySeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,82 @@
+// Code generated by enumgen; DO NOT EDIT.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+var _StatusNames = map[Status]string{
+	InvalidKey:        "invalid key",
+	UserNotFound:      "user not found",
+	AccountLocked:     "account locked",
+	TokenExpired:      "token expired",
+	TokenMalformed:    "token malformed",
+	TokenBadSignature: "bad token signature",
+	InvalidRequest:    "invalid request",
+	Forbidden:         "forbidden",
+	RateLimited:       "rate limited",
+	Unavailable:       "unavailable",
+	Internal:          "internal error",
+}
+
+func (v Status) String() string {
+	if name, ok := _StatusNames[v]; ok {
+		return name
+	}
+	return fmt.Sprintf("Status(%d)", int(v))
+}
+
+// StatusValues returns every Status in declaration order
+func StatusValues() []Status {
+	return []Status{InvalidKey, UserNotFound, AccountLocked, TokenExpired, TokenMalformed, TokenBadSignature, InvalidRequest, Forbidden, RateLimited, Unavailable, Internal}
+}
+
+func (v Status) IsValid() bool {
+	_, ok := _StatusNames[v]
+	return ok
+}
+
+// ParseStatus matches a display name, ignoring case
+func ParseStatus(s string) (Status, error) {
+	for _, v := range StatusValues() {
+		if strings.EqualFold(s, v.String()) {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("%q is not a valid Status", s)
+}
+
+func (v Status) MarshalText() ([]byte, error) {
+	if !v.IsValid() {
+		return nil, fmt.Errorf("cannot marshal invalid Status %d", int(v))
+	}
+	return []byte(v.String()), nil
+}
+
+func (v *Status) UnmarshalText(text []byte) error {
+	parsed, err := ParseStatus(string(text))
+	if err != nil {
+		return err
+	}
+	*v = parsed
+	return nil
+}
+
+func (v Status) MarshalJSON() ([]byte, error) {
+	text, err := v.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(string(text))
+}
+
+func (v *Status) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("Status should be a string: %w", err)
+	}
+	return v.UnmarshalText([]byte(s))
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// errors.Is hands Is whatever it's looking for. Sentinels like io.EOF aren't StatusErrs, and comparing
+// against them has to answer false, not panic on a bad type assertion
+func TestStatusErrIs(t *testing.T) {
+	locked := StatusErr{message: "locked", status: AccountLocked}
+	wrapped := fmt.Errorf("login: %w", locked)
+
+	for _, tc := range []struct {
+		name   string
+		err    error
+		target error
+		want   bool
+	}{
+		{"same status", locked, StatusErr{status: AccountLocked}, true},
+		{"same status through a wrap", wrapped, StatusErr{status: AccountLocked}, true},
+		{"pointer target", wrapped, &StatusErr{status: AccountLocked}, true},
+		{"nil pointer target", wrapped, (*StatusErr)(nil), false},
+		{"other status", wrapped, StatusErr{status: UserNotFound}, false},
+		{"sentinel", locked, io.EOF, false},
+		{"sentinel through a wrap", wrapped, io.EOF, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := errors.Is(tc.err, tc.target); got != tc.want {
+				t.Errorf("errors.Is = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStatusErrAsAndUnwrap(t *testing.T) {
+	err := fmt.Errorf("load users: %w", StatusErr{message: "users unavailable", status: Unavailable, cause: os.ErrNotExist})
+
+	var se StatusErr
+	if !errors.As(err, &se) || se.Status() != Unavailable {
+		t.Errorf("errors.As to StatusErr = %v %v", se, se.Status())
+	}
+	var p *StatusErr
+	if !errors.As(err, &p) || p == nil || p.Status() != Unavailable {
+		t.Fatalf("errors.As to *StatusErr = %v", p)
+	}
+	// the cause is still reachable underneath the status
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Error("errors.Is didn't reach the cause")
+	}
+	if errors.Unwrap(se) != os.ErrNotExist {
+		t.Errorf("Unwrap = %v, want os.ErrNotExist", errors.Unwrap(se))
+	}
+	if want := "load users: users unavailable: file does not exist"; err.Error() != want {
+		t.Errorf("Error = %q, want %q", err.Error(), want)
+	}
+	if errors.Unwrap(StatusErr{status: Internal}) != nil {
+		t.Error("a StatusErr without a cause unwrapped to something")
+	}
+}
+
+func TestStatusMappings(t *testing.T) {
+	for _, tc := range []struct {
+		status Status
+		http   int
+		exit   int
+	}{
+		{InvalidKey, http.StatusUnauthorized, 77},
+		{UserNotFound, http.StatusNotFound, 67},
+		{AccountLocked, http.StatusLocked, 77},
+		{TokenExpired, http.StatusUnauthorized, 77},
+		{TokenMalformed, http.StatusUnauthorized, 77},
+		{TokenBadSignature, http.StatusUnauthorized, 77},
+		{InvalidRequest, http.StatusBadRequest, 65},
+		{Forbidden, http.StatusForbidden, 77},
+		{RateLimited, http.StatusTooManyRequests, 75},
+		{Unavailable, http.StatusServiceUnavailable, 69},
+		{Internal, http.StatusInternalServerError, 70},
+	} {
+		if got := tc.status.HTTPStatus(); got != tc.http {
+			t.Errorf("%s HTTPStatus = %d, want %d", tc.status, got, tc.http)
+		}
+		if got := tc.status.ExitCode(); got != tc.exit {
+			t.Errorf("%s ExitCode = %d, want %d", tc.status, got, tc.exit)
+		}
+	}
+	// every status is in the table above
+	if got := len(StatusValues()); got != 11 {
+		t.Errorf("%d statuses, the table covers 11", got)
+	}
+
+	if ExitCode(nil) != 0 {
+		t.Error("no error should exit 0")
+	}
+	if got := ExitCode(fmt.Errorf("wrapped: %w", StatusErr{status: RateLimited})); got != 75 {
+		t.Errorf("ExitCode of a wrapped RateLimited = %d, want 75", got)
+	}
+	if got := ExitCode(errors.New("plain")); got != 70 {
+		t.Errorf("ExitCode of an error without a status = %d, want 70", got)
+	}
+}
+
+func TestProblemFor(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+		want Problem
+	}{
+		{
+			name: "status error",
+			err:  fmt.Errorf("login: %w", StatusErr{message: "account locked", status: AccountLocked}),
+			want: Problem{Type: "urn:problem-type:learninggo:account-locked", Title: "account locked",
+				Status: http.StatusLocked, Detail: "account locked", Instance: "/login"},
+		},
+		{
+			// the cause has a path in it, only the message makes it out
+			name: "cause is kept back",
+			err:  StatusErr{message: "users unavailable", status: Unavailable, cause: errors.New("open /etc/users.json")},
+			want: Problem{Type: "urn:problem-type:learninggo:unavailable", Title: "unavailable",
+				Status: http.StatusServiceUnavailable, Detail: "users unavailable", Instance: "/login"},
+		},
+		{
+			name: "retry after rounds up",
+			err:  StatusErr{message: "slow down", status: RateLimited, retryAfter: 1500 * time.Millisecond},
+			want: Problem{Type: "urn:problem-type:learninggo:rate-limited", Title: "rate limited",
+				Status: http.StatusTooManyRequests, Detail: "slow down", Instance: "/login", RetryAfter: 2},
+		},
+		{
+			name: "no status, no detail",
+			err:  errors.New("pq: relation users does not exist"),
+			want: Problem{Type: "urn:problem-type:learninggo:internal-error", Title: "internal error",
+				Status: http.StatusInternalServerError, Instance: "/login"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ProblemFor(tc.err, "/login"); got != tc.want {
+				t.Errorf("ProblemFor = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWriteProblem(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/login", nil)
+	WriteProblem(w, r, StatusErr{message: "slow down", status: RateLimited, retryAfter: 30 * time.Second})
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("status %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/problem+json" {
+		t.Errorf("Content-Type %q", got)
+	}
+	if got := w.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Retry-After %q, want 30", got)
+	}
+	var p Problem
+	if err := json.NewDecoder(w.Body).Decode(&p); err != nil || p.RetryAfter != 30 || p.Detail != "slow down" {
+		t.Errorf("body %+v %v", p, err)
+	}
+}
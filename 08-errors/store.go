@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// User is what a UserStore keeps. It never holds the password itself, only its hash
+type User struct {
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"passwordHash"`
+	Scopes       []string  `json:"scopes,omitempty"`
+	FailedLogins int       `json:"failedLogins"`
+	LockedUntil  time.Time `json:"lockedUntil"`
+}
+
+// The store's own errors are plain sentinels. Turning them into a StatusErr is the login flow's job,
+// a store shouldn't have to know how a failed lookup gets reported to a user
+var (
+	ErrNoSuchUser = errors.New("no such user")
+	ErrUserExists = errors.New("user already exists")
+)
+
+type UserStore interface {
+	FindUser(username string) (User, error)
+	// CreateUser fails with ErrUserExists rather than overwriting someone
+	CreateUser(u User) error
+	UpdateUser(u User) error
+}
+
+type MemoryUserStore struct {
+	mu    sync.Mutex
+	users map[string]User
+}
+
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{users: map[string]User{}}
+}
+
+func (s *MemoryUserStore) FindUser(username string) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[username]
+	if !ok {
+		return User{}, ErrNoSuchUser
+	}
+	return u, nil
+}
+
+func (s *MemoryUserStore) CreateUser(u User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[u.Username]; ok {
+		return ErrUserExists
+	}
+	s.users[u.Username] = u
+	return nil
+}
+
+func (s *MemoryUserStore) UpdateUser(u User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[u.Username]; !ok {
+		return ErrNoSuchUser
+	}
+	s.users[u.Username] = u
+	return nil
+}
+
+// FileUserStore keeps users in a JSON file. It reads the file on every call so edits made by hand,
+// or by another process, are picked up. A missing file is an empty store
+type FileUserStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileUserStore(path string) *FileUserStore {
+	return &FileUserStore{path: path}
+}
+
+func (s *FileUserStore) load() (map[string]User, error) {
+	users := map[string]User{}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return users, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return users, json.Unmarshal(data, &users)
+}
+
+// save writes to a temporary file first and renames it over the old one. A crash halfway through
+// leaves the old file whole, instead of a truncated one that no longer parses
+func (s *FileUserStore) save(users map[string]User) error {
+	data, err := json.MarshalIndent(users, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".users-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once the rename has happened
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	// holds password hashes, nobody else needs to read it
+	if err := os.Chmod(tmp.Name(), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func (s *FileUserStore) FindUser(username string) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	users, err := s.load()
+	if err != nil {
+		return User{}, err
+	}
+	u, ok := users[username]
+	if !ok {
+		return User{}, ErrNoSuchUser
+	}
+	return u, nil
+}
+
+func (s *FileUserStore) CreateUser(u User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	users, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := users[u.Username]; ok {
+		return ErrUserExists
+	}
+	users[u.Username] = u
+	return s.save(users)
+}
+
+func (s *FileUserStore) UpdateUser(u User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	users, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := users[u.Username]; !ok {
+		return ErrNoSuchUser
+	}
+	users[u.Username] = u
+	return s.save(users)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tokens are JWTs signed with HS256, so any JWT library can read them given the key
+// A token is three base64url parts joined by dots: header.claims.signature
+// The signature is an HMAC over the first two, so changing a single character of either breaks it
+
+type Claims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	IssuedAt  int64    `json:"iat"`
+	ExpiresAt int64    `json:"exp"`
+	Scopes    []string `json:"scopes,omitempty"`
+}
+
+func (c Claims) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+var (
+	ErrUnknownKey    = errors.New("unknown signing key")
+	ErrRetireSigning = errors.New("cannot retire the key tokens are signed with")
+)
+
+// TokenService signs with one key but verifies with any it knows, told apart by the kid in the header
+// Rotating is AddKey with the new key, waiting at least ttl for tokens signed with the old one to expire,
+// then RetireKey on the old one. Nobody gets logged out along the way
+type TokenService struct {
+	mu      sync.RWMutex
+	issuer  string
+	ttl     time.Duration
+	keys    map[string][]byte
+	signing string
+	now     func() time.Time
+}
+
+// NewTokenService issues tokens that last ttl. A ttl of 0 or less would issue tokens that are already
+// expired, which nothing can use
+func NewTokenService(issuer string, ttl time.Duration) (*TokenService, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("token ttl has to be positive, got %v", ttl)
+	}
+	return &TokenService{issuer: issuer, ttl: ttl, keys: map[string][]byte{}, now: time.Now}, nil
+}
+
+// NewKey makes a random key the size of SHA-256's output, anything shorter weakens the HMAC
+func NewKey() []byte {
+	key := make([]byte, sha256.Size)
+	rand.Read(key)
+	return key
+}
+
+// AddKey adds a key and signs everything from now on with it
+func (t *TokenService) AddKey(kid string, key []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.keys[kid] = key
+	t.signing = kid
+}
+
+// RetireKey stops accepting tokens signed with kid
+func (t *TokenService) RetireKey(kid string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if kid == t.signing {
+		return ErrRetireSigning
+	}
+	if _, ok := t.keys[kid]; !ok {
+		return ErrUnknownKey
+	}
+	delete(t.keys, kid)
+	return nil
+}
+
+var b64 = base64.RawURLEncoding
+
+func sign(key []byte, signed string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)
+}
+
+func (t *TokenService) Issue(subject string, scopes ...string) (string, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	key, ok := t.keys[t.signing]
+	if !ok {
+		return "", ErrUnknownKey
+	}
+	header, err := json.Marshal(tokenHeader{Alg: "HS256", Typ: "JWT", Kid: t.signing})
+	if err != nil {
+		return "", err
+	}
+	now := t.now()
+	claims, err := json.Marshal(Claims{
+		Issuer:    t.issuer,
+		Subject:   subject,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(t.ttl).Unix(),
+		Scopes:    scopes,
+	})
+	if err != nil {
+		return "", err
+	}
+	signed := b64.EncodeToString(header) + "." + b64.EncodeToString(claims)
+	return signed + "." + b64.EncodeToString(sign(key, signed)), nil
+}
+
+func malformed(why string) StatusErr {
+	return StatusErr{message: "malformed token: " + why, status: TokenMalformed}
+}
+
+// VerifyToken checks the signature before it trusts anything in the claims. Until then the claims
+// are just whatever the sender typed, expiry included. On any error the claims are empty, so a caller
+// that forgets to check err still has nobody to trust
+func (t *TokenService) VerifyToken(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, malformed("want three parts")
+	}
+	rawHeader, err := b64.DecodeString(parts[0])
+	if err != nil {
+		return Claims{}, malformed("header is not base64url")
+	}
+	var header tokenHeader
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return Claims{}, malformed("header is not JSON")
+	}
+	// the header is attacker controlled too. Only HS256 is accepted, whatever else it asks for,
+	// "none" in particular would mean no signature at all
+	if header.Alg != "HS256" {
+		return Claims{}, malformed("unsupported alg " + header.Alg)
+	}
+	signature, err := b64.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, malformed("signature is not base64url")
+	}
+
+	t.mu.RLock()
+	key, ok := t.keys[header.Kid]
+	issuer := t.issuer
+	now := t.now()
+	t.mu.RUnlock()
+	// a retired or made up kid can't be checked, which as far as we're concerned is a bad signature
+	if !ok || !hmac.Equal(signature, sign(key, parts[0]+"."+parts[1])) {
+		return Claims{}, StatusErr{message: "token signature invalid", status: TokenBadSignature}
+	}
+
+	var claims Claims
+	rawClaims, err := b64.DecodeString(parts[1])
+	if err != nil {
+		return Claims{}, malformed("claims are not base64url")
+	}
+	if err := json.Unmarshal(rawClaims, &claims); err != nil {
+		return Claims{}, malformed("claims are not JSON")
+	}
+	if claims.Issuer != issuer {
+		return Claims{}, malformed("issued by " + claims.Issuer)
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return Claims{}, StatusErr{
+			message: "token expired at " + time.Unix(claims.ExpiresAt, 0).Format(time.Kitchen),
+			status:  TokenExpired,
+		}
+	}
+	return claims, nil
+}
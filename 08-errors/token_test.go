@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+var tokenEpoch = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestTokens is a service on a clock that only moves when the test moves it
+func newTestTokens(t *testing.T) (*TokenService, []byte) {
+	t.Helper()
+	tokens, err := NewTokenService("test", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens.now = func() time.Time { return tokenEpoch }
+	key := NewKey()
+	tokens.AddKey("k1", key)
+	return tokens, key
+}
+
+// forge signs whatever parts it's given with key, the way someone holding the key, or guessing at it,
+// could put a token together by hand
+func forge(key []byte, header, claims string) string {
+	signed := b64.EncodeToString([]byte(header)) + "." + b64.EncodeToString([]byte(claims))
+	return signed + "." + b64.EncodeToString(sign(key, signed))
+}
+
+func claimsJSON(c Claims) string {
+	data, _ := json.Marshal(c)
+	return string(data)
+}
+
+func TestNewTokenServiceRejectsTTL(t *testing.T) {
+	for _, ttl := range []time.Duration{0, -time.Second} {
+		if _, err := NewTokenService("test", ttl); err == nil {
+			t.Errorf("NewTokenService accepted a ttl of %v", ttl)
+		}
+	}
+}
+
+func TestIssueAndVerify(t *testing.T) {
+	tokens, _ := newTestTokens(t)
+	token, err := tokens.Issue("Mary", "fleet:read")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := tokens.VerifyToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Claims{Issuer: "test", Subject: "Mary", IssuedAt: tokenEpoch.Unix(),
+		ExpiresAt: tokenEpoch.Add(time.Hour).Unix(), Scopes: []string{"fleet:read"}}
+	if claims.Subject != want.Subject || claims.ExpiresAt != want.ExpiresAt || !slices.Equal(claims.Scopes, want.Scopes) {
+		t.Errorf("claims %+v, want %+v", claims, want)
+	}
+	if !claims.HasScope("fleet:read") || claims.HasScope("fleet:write") {
+		t.Error("HasScope got fleet:read or fleet:write wrong")
+	}
+
+	noKeys, _ := NewTokenService("test", time.Hour)
+	if _, err := noKeys.Issue("Mary"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Issue with no keys error = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestVerifyTokenRejects(t *testing.T) {
+	tokens, key := newTestTokens(t)
+	good, _ := tokens.Issue("Mary")
+	header := `{"alg":"HS256","typ":"JWT","kid":"k1"}`
+	valid := claimsJSON(Claims{Issuer: "test", Subject: "Mary", ExpiresAt: tokenEpoch.Add(time.Hour).Unix()})
+	parts := strings.Split(good, ".")
+
+	for _, tc := range []struct {
+		name  string
+		token string
+		want  Status
+	}{
+		{"two parts", parts[0] + "." + parts[1], TokenMalformed},
+		{"header not base64", "!!!." + parts[1] + "." + parts[2], TokenMalformed},
+		{"header not JSON", forge(key, "not json", valid), TokenMalformed},
+		{"signature not base64", parts[0] + "." + parts[1] + ".!!!", TokenMalformed},
+		{"claims not JSON", forge(key, header, "not json"), TokenMalformed},
+		{"someone else's issuer", forge(key, header, claimsJSON(Claims{Issuer: "evil", Subject: "Mary",
+			ExpiresAt: tokenEpoch.Add(time.Hour).Unix()})), TokenMalformed},
+
+		// an attacker picking the algorithm gets nowhere, none least of all
+		{"alg none", b64.EncodeToString([]byte(`{"alg":"none","kid":"k1"}`)) + "." + parts[1] + ".", TokenMalformed},
+		{"alg none signed anyway", forge(key, `{"alg":"none","kid":"k1"}`, valid), TokenMalformed},
+		{"alg HS512", forge(key, `{"alg":"HS512","kid":"k1"}`, valid), TokenMalformed},
+		{"alg RS256", forge(key, `{"alg":"RS256","kid":"k1"}`, valid), TokenMalformed},
+
+		{"tampered claims", parts[0] + "." + b64.EncodeToString([]byte(strings.Replace(valid, "Mary", "Root", 1))) +
+			"." + parts[2], TokenBadSignature},
+		{"tampered signature", good[:len(good)-2] + "xx", TokenBadSignature},
+		{"signed with another key", forge(NewKey(), header, valid), TokenBadSignature},
+		{"unknown kid", forge(key, `{"alg":"HS256","kid":"k9"}`, valid), TokenBadSignature},
+		{"no kid", forge(key, `{"alg":"HS256"}`, valid), TokenBadSignature},
+
+		{"expired", forge(key, header, claimsJSON(Claims{Issuer: "test", Subject: "Mary",
+			ExpiresAt: tokenEpoch.Unix()})), TokenExpired},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			claims, err := tokens.VerifyToken(tc.token)
+			if !errors.Is(err, StatusErr{status: tc.want}) {
+				t.Errorf("error = %v, want status %s", err, tc.want)
+			}
+			if claims.Subject != "" || claims.Issuer != "" || claims.ExpiresAt != 0 || claims.Scopes != nil {
+				t.Errorf("claims %+v came back with the error, want none", claims)
+			}
+		})
+	}
+
+	// the issued token itself runs out on time
+	tokens.now = func() time.Time { return tokenEpoch.Add(time.Hour) }
+	if _, err := tokens.VerifyToken(good); !errors.Is(err, StatusErr{status: TokenExpired}) {
+		t.Errorf("an hour later error = %v, want TokenExpired", err)
+	}
+}
+
+// Tokens signed before a rotation keep working until the old key is retired
+func TestKeyRotation(t *testing.T) {
+	tokens, _ := newTestTokens(t)
+	before, _ := tokens.Issue("Mary")
+
+	tokens.AddKey("k2", NewKey())
+	after, _ := tokens.Issue("Mary")
+	for name, token := range map[string]string{"before": before, "after": after} {
+		if _, err := tokens.VerifyToken(token); err != nil {
+			t.Errorf("token signed %s the rotation: %v", name, err)
+		}
+	}
+
+	if err := tokens.RetireKey("k2"); !errors.Is(err, ErrRetireSigning) {
+		t.Errorf("retiring the signing key error = %v, want ErrRetireSigning", err)
+	}
+	if err := tokens.RetireKey("k9"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("retiring an unknown key error = %v, want ErrUnknownKey", err)
+	}
+	if err := tokens.RetireKey("k1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tokens.VerifyToken(before); !errors.Is(err, StatusErr{status: TokenBadSignature}) {
+		t.Errorf("token signed with a retired key error = %v, want TokenBadSignature", err)
+	}
+	if _, err := tokens.VerifyToken(after); err != nil {
+		t.Errorf("retiring the old key broke the new one: %v", err)
+	}
+}
@@ -10,4 +10,31 @@ func main() {
 	p2 := math.NewPoint(4, 5)
 	p1.Attach(&p2)
 	fmt.Println("What's the difference between p1 and p2?", p1.Distance())
+	fmt.Println("Same thing without attaching:", p1.DistanceTo(math.NewPoint(4, 5)))
+
+	v := math.Vec{X: 3, Y: 4}
+	unit, _ := v.Normalize()
+	fmt.Println("Length of", v, "is", v.Len(), "pointing", unit)
+	fmt.Println("At right angles?", v.Dot(math.Vec{X: -4, Y: 3}) == 0, "which way round?", v.Cross(math.Vec{X: -4, Y: 3}) > 0)
+
+	// Two roads on a map, where do they cross?
+	road := math.Segment{A: math.Vec{X: 0, Y: 0}, B: math.Vec{X: 10, Y: 10}}
+	river := math.Segment{A: math.Vec{X: 0, Y: 8}, B: math.Vec{X: 8, Y: 0}}
+	if at, ok := road.Intersection(river); ok {
+		fmt.Println("The road crosses the river at", at)
+	}
+	path := math.Segment{A: math.Vec{X: 20, Y: 0}, B: math.Vec{X: 20, Y: 5}}
+	fmt.Println("Does the path cross the road?", road.Intersects(path))
+
+	// An L shaped park, concave on purpose
+	park := math.Polygon{{X: 0, Y: 0}, {X: 6, Y: 0}, {X: 6, Y: 2}, {X: 2, Y: 2}, {X: 2, Y: 6}, {X: 0, Y: 6}}
+	fmt.Println("Park area", park.Area(), "perimeter", park.Perimeter(), "bounds", park.Bounds())
+	for _, p := range []math.Vec{{X: 1, Y: 1}, {X: 4, Y: 4}, {X: 6, Y: 1}} {
+		fmt.Println(p, "in the park?", park.Contains(p))
+	}
+
+	// The fence that fits around every tree
+	trees := []math.Vec{{X: 1, Y: 1}, {X: 4, Y: 0}, {X: 2, Y: 2}, {X: 5, Y: 3}, {X: 3, Y: 5}, {X: 0, Y: 4}, {X: 3, Y: 3}, {X: 2, Y: 0}}
+	fence := math.ConvexHull(trees)
+	fmt.Println("Fence posts", fence, "fenced area", fence.Area())
 }
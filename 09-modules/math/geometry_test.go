@@ -0,0 +1,130 @@
+package math
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// The same shapes at very different sizes and places. Every answer has to come out the same whatever
+// the scale: a park measured in degrees of latitude, in metres, or in metres from a far away origin
+var scales = []struct {
+	size   float64
+	origin Vec
+}{
+	{1e-5, Vec{}},
+	{1e-5, Vec{36.8219, -1.2921}}, // a few metres square in Nairobi, in degrees
+	{1, Vec{}},
+	{1e6, Vec{}},
+	{1e3, Vec{5e6, 5e6}},
+}
+
+// at places a point given in units of the square's side
+func at(size float64, origin Vec, x, y float64) Vec {
+	return origin.Add(Vec{x, y}.Scale(size))
+}
+
+func forEachScale(t *testing.T, test func(t *testing.T, size float64, p func(x, y float64) Vec)) {
+	for _, sc := range scales {
+		t.Run(fmt.Sprintf("%g at %v", sc.size, sc.origin), func(t *testing.T) {
+			test(t, sc.size, func(x, y float64) Vec { return at(sc.size, sc.origin, x, y) })
+		})
+	}
+}
+
+func closeTo(got, want Vec, size float64) bool {
+	return got.Dist(want) <= 1e-9*size
+}
+
+func TestConvexHullAtScale(t *testing.T) {
+	forEachScale(t, func(t *testing.T, size float64, p func(x, y float64) Vec) {
+		points := []Vec{p(0, 0), p(1, 0), p(1, 1), p(0, 1), p(0.5, 0.5), p(0.5, 0), p(0.25, 0.75)}
+		hull := ConvexHull(points)
+		if len(hull) != 4 {
+			t.Fatalf("hull of a square with points inside and on an edge is %v, want its 4 corners", hull)
+		}
+		if area := hull.Area(); math.Abs(area-size*size) > 1e-6*size*size {
+			t.Errorf("hull area %g, want %g", area, size*size)
+		}
+		if hull.SignedArea() <= 0 {
+			t.Errorf("hull %v isn't counter-clockwise", hull)
+		}
+	})
+}
+
+func TestIntersectionAtScale(t *testing.T) {
+	forEachScale(t, func(t *testing.T, size float64, p func(x, y float64) Vec) {
+		diag := Segment{p(0, 0), p(1, 1)}
+		anti := Segment{p(1, 0), p(0, 1)}
+		got, ok := diag.Intersection(anti)
+		if want := p(0.5, 0.5); !ok || !closeTo(got, want, size) {
+			t.Errorf("diagonals meet at %v %v, want %v", got, ok, want)
+		}
+
+		// parallel, a fifth of the side apart
+		low := Segment{p(0, 0), p(1, 0)}
+		high := Segment{p(0, 0.2), p(1, 0.2)}
+		if low.Intersects(high) {
+			t.Error("parallel segments intersect")
+		}
+		if got, ok := low.Intersection(high); ok {
+			t.Errorf("parallel segments meet at %v", got)
+		}
+
+		// a whisker of a turn is still a turn
+		tilted := Segment{p(0, 1e-3), p(1, 2e-3)}
+		if low.Intersects(tilted) {
+			t.Error("segments that never reach each other intersect")
+		}
+
+		// collinear and overlapping, the overlap runs from 0.5 to 1
+		overlap := Segment{p(0.5, 0), p(2, 0)}
+		if got, ok := low.Intersection(overlap); !ok || !closeTo(got, p(0.5, 0), size) {
+			t.Errorf("overlap starts at %v %v, want %v", got, ok, p(0.5, 0))
+		}
+		// collinear, but with a gap between them
+		if got, ok := low.Intersection(Segment{p(1.5, 0), p(2, 0)}); ok {
+			t.Errorf("segments on one line with a gap meet at %v", got)
+		}
+
+		// meeting end to end
+		up := Segment{p(1, 0), p(1, 1)}
+		if got, ok := low.Intersection(up); !ok || !closeTo(got, p(1, 0), size) {
+			t.Errorf("corner at %v %v, want %v", got, ok, p(1, 0))
+		}
+	})
+}
+
+func TestContainsAtScale(t *testing.T) {
+	forEachScale(t, func(t *testing.T, size float64, p func(x, y float64) Vec) {
+		// L shaped, so the notch is inside the bounds but outside the polygon
+		park := Polygon{p(0, 0), p(3, 0), p(3, 1), p(1, 1), p(1, 3), p(0, 3)}
+		for _, tc := range []struct {
+			x, y float64
+			want bool
+		}{
+			{0.5, 0.5, true},
+			{2, 0.5, true},
+			{2, 2, false},
+			{3, 0.5, true}, // on an edge
+			{4, 0.5, false},
+		} {
+			if got := park.Contains(p(tc.x, tc.y)); got != tc.want {
+				t.Errorf("Contains(%v, %v) = %v, want %v", tc.x, tc.y, got, tc.want)
+			}
+		}
+		if area := park.Area(); math.Abs(area-5*size*size) > 1e-6*size*size {
+			t.Errorf("area %g, want %g", area, 5*size*size)
+		}
+	})
+}
+
+func TestNormalizeTinyVector(t *testing.T) {
+	unit, ok := Vec{3e-12, 4e-12}.Normalize()
+	if !ok || !closeTo(unit, Vec{0.6, 0.8}, 1) {
+		t.Errorf("Normalize of a tiny vector = %v %v, want (0.6, 0.8)", unit, ok)
+	}
+	if _, ok := (Vec{}).Normalize(); ok {
+		t.Error("the zero vector has no direction")
+	}
+}
@@ -2,23 +2,41 @@ package math
 
 import "math"
 
+// Point is a position that can be chained to another one. The geometry in vector.go, segment.go and
+// polygon.go works on plain Vecs, p.Vec() hands a Point over to it
 type Point struct {
-	x, y   int
+	x, y   float64
 	parent *Point
 	child  *Point
 }
 
-func NewPoint(x, y int) Point {
+func NewPoint(x, y float64) Point {
 	return Point{x: x, y: y}
 }
 
+func (p Point) X() float64 { return p.x }
+func (p Point) Y() float64 { return p.y }
+
+func (p Point) Vec() Vec {
+	return Vec{p.x, p.y}
+}
+
 func (p *Point) Attach(to *Point) {
 	p.child = to
 	to.parent = p
 }
 
+// DistanceTo is the straight line distance to any point, attached or not
+func (p Point) DistanceTo(q Point) float64 {
+	return p.Vec().Dist(q.Vec())
+}
+
+// Distance is how far it is to the attached child, 0 if nothing is attached
 func (p *Point) Distance() float32 {
-	xDiff := math.Pow(float64(p.x) - float64(p.child.x), 2)
-	yDiff := math.Pow(float64(p.y) - float64(p.child.y) , 2)
+	if p.child == nil {
+		return 0
+	}
+	xDiff := math.Pow(p.x-p.child.x, 2)
+	yDiff := math.Pow(p.y-p.child.y, 2)
 	return float32(math.Sqrt(xDiff + yDiff))
-}
\ No newline at end of file
+}
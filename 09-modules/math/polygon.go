@@ -0,0 +1,144 @@
+package math
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// Rect is an axis aligned box, the cheap first check before any exact geometry. If two shapes' boxes
+// don't overlap, the shapes can't either
+type Rect struct {
+	Min, Max Vec
+}
+
+// BoundsOf is the smallest Rect holding every point. No points gives the zero Rect
+func BoundsOf(points ...Vec) Rect {
+	if len(points) == 0 {
+		return Rect{}
+	}
+	r := Rect{points[0], points[0]}
+	for _, p := range points[1:] {
+		r.Min = Vec{min(r.Min.X, p.X), min(r.Min.Y, p.Y)}
+		r.Max = Vec{max(r.Max.X, p.X), max(r.Max.Y, p.Y)}
+	}
+	return r
+}
+
+func (r Rect) Width() float64  { return r.Max.X - r.Min.X }
+func (r Rect) Height() float64 { return r.Max.Y - r.Min.Y }
+
+func (r Rect) Contains(p Vec) bool {
+	return r.Min.X <= p.X && p.X <= r.Max.X && r.Min.Y <= p.Y && p.Y <= r.Max.Y
+}
+
+func (r Rect) Intersects(o Rect) bool {
+	return r.Min.X <= o.Max.X && o.Min.X <= r.Max.X && r.Min.Y <= o.Max.Y && o.Min.Y <= r.Max.Y
+}
+
+func (r Rect) Union(o Rect) Rect {
+	return BoundsOf(r.Min, r.Max, o.Min, o.Max)
+}
+
+// Polygon is its corners in order, either way round. The last corner joins back to the first,
+// so there's no need to repeat the first at the end
+type Polygon []Vec
+
+// Edges walks the sides, the closing one from the last corner back to the first included
+func (poly Polygon) Edges() []Segment {
+	edges := make([]Segment, len(poly))
+	for i, p := range poly {
+		edges[i] = Segment{p, poly[(i+1)%len(poly)]}
+	}
+	return edges
+}
+
+// SignedArea is positive for corners listed counter-clockwise, negative for clockwise
+// It's the shoelace formula: every edge and the first corner make a triangle, and adding up their signed
+// areas cancels out everything outside the polygon. Measuring from the first corner rather than the
+// origin matters far from the origin, the triangles to the origin would be huge and nearly cancel out,
+// leaving mostly rounding error
+func (poly Polygon) SignedArea() float64 {
+	if len(poly) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, e := range poly.Edges() {
+		sum += e.A.Sub(poly[0]).Cross(e.B.Sub(poly[0]))
+	}
+	return sum / 2
+}
+
+func (poly Polygon) Area() float64 {
+	return math.Abs(poly.SignedArea())
+}
+
+func (poly Polygon) Perimeter() float64 {
+	var sum float64
+	for _, e := range poly.Edges() {
+		sum += e.Len()
+	}
+	return sum
+}
+
+func (poly Polygon) Bounds() Rect {
+	return BoundsOf(poly...)
+}
+
+// Contains says whether p is inside the polygon or on its edge. It casts a ray from p to the right and
+// counts the edges it crosses, an odd count means inside. That works for concave polygons too
+func (poly Polygon) Contains(p Vec) bool {
+	if len(poly) < 3 || !poly.Bounds().Contains(p) {
+		return false
+	}
+	inside := false
+	for _, e := range poly.Edges() {
+		if e.Contains(p) {
+			return true
+		}
+		// the edge counts if it straddles the ray's height, half open so a corner
+		// right at that height is counted once, not once for each edge meeting there
+		if (e.A.Y > p.Y) != (e.B.Y > p.Y) {
+			x := e.A.X + (p.Y-e.A.Y)*(e.B.X-e.A.X)/(e.B.Y-e.A.Y)
+			if x > p.X {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+// ConvexHull is the smallest convex polygon around the points, like a rubber band let go around them,
+// counter-clockwise and without collinear corners
+// It's Andrew's monotone chain: sort left to right, then build the lower and upper halves, dropping
+// any corner that would turn the wrong way
+func ConvexHull(points []Vec) Polygon {
+	sorted := slices.Clone(points)
+	slices.SortFunc(sorted, func(a, b Vec) int {
+		if c := cmp.Compare(a.X, b.X); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Y, b.Y)
+	})
+	sorted = slices.CompactFunc(sorted, Vec.Equal)
+	if len(sorted) < 3 {
+		return Polygon(sorted)
+	}
+
+	var hull Polygon
+	half := func(points []Vec) {
+		start := len(hull)
+		for _, p := range points {
+			for len(hull)-start >= 2 && orientation(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+				hull = hull[:len(hull)-1]
+			}
+			hull = append(hull, p)
+		}
+		// the last corner of each half is the first of the other
+		hull = hull[:len(hull)-1]
+	}
+	half(sorted)
+	slices.Reverse(sorted)
+	half(sorted)
+	return hull
+}
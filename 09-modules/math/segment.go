@@ -0,0 +1,66 @@
+package math
+
+type Segment struct {
+	A, B Vec
+}
+
+func (s Segment) Len() float64 {
+	return s.A.Dist(s.B)
+}
+
+// onSegment says whether p, already known to be on the line through s, is between its ends
+func (s Segment) onSegment(p Vec) bool {
+	slack := Epsilon * s.Len()
+	return min(s.A.X, s.B.X)-slack <= p.X && p.X <= max(s.A.X, s.B.X)+slack &&
+		min(s.A.Y, s.B.Y)-slack <= p.Y && p.Y <= max(s.A.Y, s.B.Y)+slack
+}
+
+// Contains says whether p lies on the segment
+func (s Segment) Contains(p Vec) bool {
+	return orientation(s.A, s.B, p) == 0 && s.onSegment(p)
+}
+
+// Intersects says whether the segments touch at all, an end resting on the other segment included
+// They cross when each one's ends are on opposite sides of the other. Otherwise the only way to touch
+// is for an end to lie on the other segment
+func (s Segment) Intersects(t Segment) bool {
+	o1 := orientation(s.A, s.B, t.A)
+	o2 := orientation(s.A, s.B, t.B)
+	o3 := orientation(t.A, t.B, s.A)
+	o4 := orientation(t.A, t.B, s.B)
+	if o1 != o2 && o3 != o4 && o1 != 0 && o2 != 0 && o3 != 0 && o4 != 0 {
+		return true
+	}
+	return (o1 == 0 && s.onSegment(t.A)) || (o2 == 0 && s.onSegment(t.B)) ||
+		(o3 == 0 && t.onSegment(s.A)) || (o4 == 0 && t.onSegment(s.B))
+}
+
+// Intersection is where the segments meet. Overlapping collinear segments meet all along the overlap,
+// for those it returns the overlap's end nearest s.A
+func (s Segment) Intersection(t Segment) (Vec, bool) {
+	if !s.Intersects(t) {
+		return Vec{}, false
+	}
+	r := s.B.Sub(s.A)
+	q := t.B.Sub(t.A)
+	denom := r.Cross(q)
+	if !nearlyZero(denom, r.Len()*q.Len()) {
+		// solve s.A + r*u = t.A + q*v for u
+		u := t.A.Sub(s.A).Cross(q) / denom
+		return s.A.Add(r.Scale(u)), true
+	}
+
+	// parallel but not on the same line never meet. Intersects should have said so already, but
+	// the overlap below only checks bounding boxes, so don't take that on trust
+	if orientation(s.A, s.B, t.A) != 0 {
+		return Vec{}, false
+	}
+	// collinear. Of the four ends, the ones on both segments bound the overlap
+	best, found := Vec{}, false
+	for _, p := range []Vec{s.A, s.B, t.A, t.B} {
+		if s.onSegment(p) && t.onSegment(p) && (!found || s.A.Dist(p) < s.A.Dist(best)) {
+			best, found = p, true
+		}
+	}
+	return best, found
+}
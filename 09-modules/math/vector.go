@@ -0,0 +1,87 @@
+package math
+
+import (
+	"fmt"
+	"math"
+)
+
+// Epsilon is how close two floats have to be to count as equal, as a fraction of their size. Floating
+// point arithmetic rounds, so a point computed to lie on a line is usually a hair off it, never exactly on
+//
+// It has to be relative. Rounding errors grow and shrink with the numbers, and a fixed cut off that's fine
+// for metres swallows whole shapes drawn in degrees of latitude, and sees nothing but noise at millions
+const Epsilon = 1e-9
+
+// nearlyZero says whether f is just rounding error next to scale, the size of what it was worked out from
+func nearlyZero(f, scale float64) bool {
+	return math.Abs(f) <= Epsilon*scale
+}
+
+// Vec is both a position and a direction, the arithmetic is the same either way
+type Vec struct {
+	X, Y float64
+}
+
+func (v Vec) Add(w Vec) Vec {
+	return Vec{v.X + w.X, v.Y + w.Y}
+}
+
+func (v Vec) Sub(w Vec) Vec {
+	return Vec{v.X - w.X, v.Y - w.Y}
+}
+
+func (v Vec) Scale(k float64) Vec {
+	return Vec{v.X * k, v.Y * k}
+}
+
+// Dot is positive when v and w point roughly the same way, 0 when they're at right angles
+func (v Vec) Dot(w Vec) float64 {
+	return v.X*w.X + v.Y*w.Y
+}
+
+// Cross is the z of the 3D cross product. It's positive when w is counter-clockwise of v,
+// negative when clockwise and 0 when they're parallel. Most of the geometry here comes down to that sign
+func (v Vec) Cross(w Vec) float64 {
+	return v.X*w.Y - v.Y*w.X
+}
+
+func (v Vec) Len() float64 {
+	return math.Hypot(v.X, v.Y)
+}
+
+// Normalize returns v scaled to length 1. The zero vector has no direction to keep, so it's returned
+// as is with false
+func (v Vec) Normalize() (Vec, bool) {
+	l := v.Len()
+	if l == 0 {
+		return v, false
+	}
+	return v.Scale(1 / l), true
+}
+
+func (v Vec) Dist(w Vec) float64 {
+	return v.Sub(w).Len()
+}
+
+func (v Vec) Equal(w Vec) bool {
+	return nearlyZero(v.Dist(w), max(v.Len(), w.Len()))
+}
+
+func (v Vec) String() string {
+	return fmt.Sprintf("(%g, %g)", v.X, v.Y)
+}
+
+// orientation is the sign of the turn a -> b -> c: 1 counter-clockwise, -1 clockwise, 0 straight on
+// The cross product is the two lengths times the sine of the angle between them, so dividing the lengths
+// back out leaves how sharp the turn is, whatever size the triangle
+func orientation(a, b, c Vec) int {
+	ab, ac := b.Sub(a), c.Sub(a)
+	cross := ab.Cross(ac)
+	switch {
+	case nearlyZero(cross, ab.Len()*ac.Len()):
+		return 0
+	case cross > 0:
+		return 1
+	}
+	return -1
+}
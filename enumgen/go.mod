@@ -0,0 +1,3 @@
+module learninggo/enumgen
+
+go 1.22.2
@@ -0,0 +1,242 @@
+// enumgen writes the boring half of an iota enum so nobody has to keep a switch in step with the constants.
+// Point it at a type and it finds the const block declaring it. Each constant's trailing comment is its
+// display name, a constant without one is displayed as its identifier
+//
+//	type DriveTrain int
+//
+//	const (
+//		aWD DriveTrain = iota // All
+//		fourWD                // Four wheel drive
+//	)
+//
+// From that it generates String, Parse<Type>, <Type>Values, IsValid, MarshalJSON/UnmarshalJSON and
+// MarshalText/UnmarshalText into <type>_enum.go next to the source. It lives in its own module, so a package
+// uses it with
+//
+//	//go:generate go run -C ../enumgen . -type=DriveTrain -dir=$PWD
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+type constant struct {
+	Name    string
+	Display string
+}
+
+type enum struct {
+	Package   string
+	Type      string
+	Constants []constant
+}
+
+func main() {
+	typeName := flag.String("type", "", "name of the enum type")
+	dir := flag.String("dir", ".", "directory of the package declaring the type")
+	output := flag.String("output", "", "output file, defaults to <type>_enum.go in dir")
+	flag.Parse()
+
+	if *typeName == "" {
+		fmt.Fprintln(os.Stderr, "enumgen: -type is required")
+		os.Exit(2)
+	}
+	if *output == "" {
+		*output = filepath.Join(*dir, strings.ToLower(*typeName)+"_enum.go")
+	}
+
+	e, err := findEnum(*dir, *typeName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "enumgen:", err)
+		os.Exit(1)
+	}
+	src, err := generate(e)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "enumgen:", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(*output, src, 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, "enumgen:", err)
+		os.Exit(1)
+	}
+}
+
+func findEnum(dir, typeName string) (enum, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return enum{}, err
+	}
+	fset := token.NewFileSet()
+	for _, entry := range entries {
+		name := entry.Name()
+		// skip what we generated last time, and tests
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_enum.go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return enum{}, err
+		}
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.CONST {
+				continue
+			}
+			constants, found, err := readBlock(gen, typeName)
+			if err != nil {
+				return enum{}, err
+			}
+			if found {
+				return enum{Package: file.Name.Name, Type: typeName, Constants: constants}, nil
+			}
+		}
+	}
+	return enum{}, fmt.Errorf("no const block declares type %s in %s", typeName, dir)
+}
+
+// readBlock walks a const block that starts with `Name Type = iota` (or iota + n)
+// Every following spec has to leave its value off so it keeps counting from the first
+func readBlock(gen *ast.GenDecl, typeName string) ([]constant, bool, error) {
+	if len(gen.Specs) == 0 {
+		return nil, false, nil
+	}
+	first := gen.Specs[0].(*ast.ValueSpec)
+	if ident, ok := first.Type.(*ast.Ident); !ok || ident.Name != typeName {
+		return nil, false, nil
+	}
+	if len(first.Values) != 1 {
+		return nil, true, fmt.Errorf("%s block must start with = iota", typeName)
+	}
+	if !isIota(first.Values[0]) {
+		return nil, true, fmt.Errorf("%s block must start with = iota or = iota + n", typeName)
+	}
+
+	var constants []constant
+	for i, spec := range gen.Specs {
+		vs := spec.(*ast.ValueSpec)
+		if i > 0 && (vs.Type != nil || len(vs.Values) > 0) {
+			return nil, true, fmt.Errorf("%s: %s restarts the block, enumgen only handles a single iota run",
+				typeName, vs.Names[0].Name)
+		}
+		for _, name := range vs.Names {
+			if name.Name == "_" {
+				continue
+			}
+			display := name.Name
+			if vs.Comment != nil {
+				display = strings.TrimSpace(vs.Comment.Text())
+			}
+			constants = append(constants, constant{Name: name.Name, Display: display})
+		}
+	}
+	return constants, true, nil
+}
+
+// The constants are referenced by name in the generated code, so we never need their values,
+// just to know that the compiler is counting them for us
+func isIota(expr ast.Expr) bool {
+	if bin, ok := expr.(*ast.BinaryExpr); ok && bin.Op == token.ADD {
+		lit, isLit := bin.Y.(*ast.BasicLit)
+		return isLit && lit.Kind == token.INT && isIota(bin.X)
+	}
+	ident, ok := expr.(*ast.Ident)
+	return ok && ident.Name == "iota"
+}
+
+func generate(e enum) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := enumTemplate.Execute(&buf, e); err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated code does not compile: %w", err)
+	}
+	return src, nil
+}
+
+var enumTemplate = template.Must(template.New("enum").Parse(`// Code generated by enumgen; DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+var _{{.Type}}Names = map[{{.Type}}]string{
+{{- range .Constants}}
+	{{.Name}}: {{printf "%q" .Display}},
+{{- end}}
+}
+
+func (v {{.Type}}) String() string {
+	if name, ok := _{{.Type}}Names[v]; ok {
+		return name
+	}
+	return fmt.Sprintf("{{.Type}}(%d)", int(v))
+}
+
+// {{.Type}}Values returns every {{.Type}} in declaration order
+func {{.Type}}Values() []{{.Type}} {
+	return []{{.Type}}{ {{- range .Constants}}{{.Name}}, {{end -}} }
+}
+
+func (v {{.Type}}) IsValid() bool {
+	_, ok := _{{.Type}}Names[v]
+	return ok
+}
+
+// Parse{{.Type}} matches a display name, ignoring case
+func Parse{{.Type}}(s string) ({{.Type}}, error) {
+	for _, v := range {{.Type}}Values() {
+		if strings.EqualFold(s, v.String()) {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("%q is not a valid {{.Type}}", s)
+}
+
+func (v {{.Type}}) MarshalText() ([]byte, error) {
+	if !v.IsValid() {
+		return nil, fmt.Errorf("cannot marshal invalid {{.Type}} %d", int(v))
+	}
+	return []byte(v.String()), nil
+}
+
+func (v *{{.Type}}) UnmarshalText(text []byte) error {
+	parsed, err := Parse{{.Type}}(string(text))
+	if err != nil {
+		return err
+	}
+	*v = parsed
+	return nil
+}
+
+func (v {{.Type}}) MarshalJSON() ([]byte, error) {
+	text, err := v.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(string(text))
+}
+
+func (v *{{.Type}}) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("{{.Type}} should be a string: %w", err)
+	}
+	return v.UnmarshalText([]byte(s))
+}
+`))
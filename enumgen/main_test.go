@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// go test -update rewrites the golden files from the current generator. Read the diff before committing it
+var update = flag.Bool("update", false, "rewrite golden files")
+
+func TestGenerateGolden(t *testing.T) {
+	e, err := findEnum("testdata/color", "Color")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []constant{
+		{"Red", "red"},
+		{"Green", "Forest green"},
+		{"Blue", `navy "blue"`},
+		{"Plain", "Plain"}, // no comment, so its identifier
+	}
+	if len(e.Constants) != len(want) {
+		t.Fatalf("found constants %v, want %v", e.Constants, want)
+	}
+	for i, c := range e.Constants {
+		if c != want[i] {
+			t.Errorf("constant %d = %+v, want %+v", i, c, want[i])
+		}
+	}
+
+	got, err := generate(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join("testdata", "color", "color_enum.golden")
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wantSrc, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, wantSrc) {
+		t.Errorf("generated code differs from %s, run go test -update if that's intended\n%s", golden, got)
+	}
+}
+
+// The generated code has to compile and round trip every value through String, Parse and JSON.
+// That takes a real build, so it copies the test package out with a program that checks it
+func TestGeneratedRoundTrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a program")
+	}
+	dir := t.TempDir()
+	src, err := os.ReadFile("testdata/color/color.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := findEnum("testdata/color", "Color")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Package = "main"
+	gen, err := generate(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"go.mod":        "module roundtrip\n\ngo 1.22\n",
+		"color.go":      strings.Replace(string(src), "package color", "package main", 1),
+		"color_enum.go": string(gen),
+		"main.go":       roundTripProgram,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("round trip failed: %v\n%s", err, out)
+	}
+}
+
+const roundTripProgram = `package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func check(ok bool, format string, args ...any) {
+	if !ok {
+		fmt.Printf(format+"\n", args...)
+		os.Exit(1)
+	}
+}
+
+func main() {
+	check(len(ColorValues()) == 4, "values %v", ColorValues())
+	for _, c := range ColorValues() {
+		parsed, err := ParseColor(strings.ToUpper(c.String()))
+		check(err == nil && parsed == c, "parse %q: %v %v", c, parsed, err)
+		data, err := json.Marshal(c)
+		check(err == nil, "marshal %v: %v", c, err)
+		var back Color
+		check(json.Unmarshal(data, &back) == nil && back == c, "json %s came back as %v", data, back)
+	}
+	check(Red == 1 && Blue == 4, "iota + 1 and the skipped _ moved the values: %d %d", Red, Blue)
+	check(Color(0).String() == "Color(0)" && !Color(0).IsValid(), "the zero value is not a Color")
+	_, err := ParseColor("purple")
+	check(err != nil, "parsed purple")
+	_, err = json.Marshal(Color(0))
+	check(err != nil, "marshalled an invalid Color")
+}
+`
+
+func TestReadBlockErrors(t *testing.T) {
+	for name, block := range map[string]string{
+		"no value":      "const (\n\tA T\n\tB\n)",
+		"not iota":      "const (\n\tA T = 1\n\tB\n)",
+		"iota times":    "const (\n\tA T = iota * 2\n\tB\n)",
+		"iota plus var": "const (\n\tA T = iota + n\n\tB\n)",
+		"restarted":     "const (\n\tA T = iota\n\tB T = iota\n)",
+		"new value":     "const (\n\tA T = iota\n\tB = 5\n)",
+	} {
+		t.Run(name, func(t *testing.T) {
+			gen := parseConst(t, block)
+			_, found, err := readBlock(gen, "T")
+			if !found || err == nil {
+				t.Errorf("readBlock = found %v, error %v, want the block found and refused", found, err)
+			}
+		})
+	}
+
+	// a block for some other type is none of our business
+	if _, found, err := readBlock(parseConst(t, "const (\n\tA U = iota\n)"), "T"); found || err != nil {
+		t.Errorf("another type's block: found %v, error %v", found, err)
+	}
+}
+
+func parseConst(t *testing.T, block string) *ast.GenDecl {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "block.go", "package p\n\n"+block+"\n", parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file.Decls[0].(*ast.GenDecl)
+}
+
+func TestFindEnumMissingType(t *testing.T) {
+	if _, err := findEnum("testdata/color", "Shade"); err == nil {
+		t.Error("found an enum for a type nobody declared")
+	}
+}
@@ -0,0 +1,15 @@
+package color
+
+type Color int
+
+// Counting from 1 leaves the zero value meaning "not set"
+const (
+	Red   Color = iota + 1 // red
+	Green                  // Forest green
+	_
+	Blue // navy "blue"
+	Plain
+)
+
+// Not part of the enum, only the block declaring the type counts
+const unrelated = 7